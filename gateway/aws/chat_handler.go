@@ -103,6 +103,25 @@ func GetChatsByUserID(ctx context.Context, client *dynamodb.Client, userID strin
 	return chats, nil
 }
 
+// CountChatsByUserID returns how many chats a user has, using a COUNT query
+// on the GSI so the items are never transferred
+func CountChatsByUserID(ctx context.Context, client *dynamodb.Client, userID string) (int, error) {
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(ChatsTableName),
+		IndexName:              aws.String(ChatsUserIDGSI),
+		KeyConditionExpression: aws.String("user_id = :user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":user_id": &types.AttributeValueMemberS{Value: userID},
+		},
+		Select: types.SelectCount,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count chats by user_id: %w", err)
+	}
+
+	return int(result.Count), nil
+}
+
 // GetPublicChats retrieves all non-private chats
 func GetPublicChats(ctx context.Context, client *dynamodb.Client) ([]Chat, error) {
 	result, err := client.Scan(ctx, &dynamodb.ScanInput{
@@ -210,6 +229,69 @@ func UpdateChat(ctx context.Context, client *dynamodb.Client, chat Chat) (*Chat,
 }
 
 // DeleteChat deletes a chat by ID
+// chatPreviewMaxLen caps the denormalized last-message preview length
+const chatPreviewMaxLen = 100
+
+// RecordChatMessageActivity updates the denormalized last-message metadata on
+// a chat item. countDelta adjusts message_count (negative on deletes, in which
+// case the preview is left as-is).
+func RecordChatMessageActivity(ctx context.Context, client *dynamodb.Client, chatID string, preview string, messageAt time.Time, countDelta int) error {
+	// Step 1: Query to get `created_at` for the given `id`
+	result, err := client.Query(ctx, &dynamodb.QueryInput{
+		TableName: aws.String(ChatsTableName),
+		KeyConditions: map[string]types.Condition{
+			"id": {
+				ComparisonOperator: types.ComparisonOperatorEq,
+				AttributeValueList: []types.AttributeValue{
+					&types.AttributeValueMemberS{Value: chatID},
+				},
+			},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	if len(result.Items) == 0 {
+		return fmt.Errorf("no item found with id: %s", chatID)
+	}
+
+	// Extract created_at from the result
+	createdAt := result.Items[0]["created_at"].(*types.AttributeValueMemberS).Value
+
+	// Step 2: Update the denormalized metadata with both id and created_at
+	updateExpression := "ADD message_count :delta"
+	expressionAttributeValues := map[string]types.AttributeValue{
+		":delta": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", countDelta)},
+	}
+
+	if preview != "" {
+		if len(preview) > chatPreviewMaxLen {
+			preview = preview[:chatPreviewMaxLen]
+		}
+		updateExpression += " SET last_message_preview = :preview, last_message_at = :message_at"
+		expressionAttributeValues[":preview"] = &types.AttributeValueMemberS{Value: preview}
+		expressionAttributeValues[":message_at"] = &types.AttributeValueMemberS{Value: messageAt.Format(time.RFC3339Nano)}
+	}
+
+	_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(ChatsTableName),
+		Key: map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: chatID},
+			"created_at": &types.AttributeValueMemberS{Value: createdAt},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeValues: expressionAttributeValues,
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update chat message metadata: %w", err)
+	}
+
+	return nil
+}
+
 func DeleteChat(ctx context.Context, client *dynamodb.Client, id string) error {
 	// Step 1: Query to get `created_at` for the given `id`
 	result, err := client.Query(ctx, &dynamodb.QueryInput{
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Automatic retention for old chats. Long-running deployments accumulate
+// chats indefinitely; this optional background job prunes chats that haven't
+// been touched within a per-tier retention period. It defaults to dry-run
+// (logging what would be removed) and only deletes once
+// CHAT_RETENTION_DESTRUCTIVE=true is set, so operators can verify the cutoffs
+// before enabling it for real.
+
+// Default retention periods in days; Pro (and Plus) users are retained longer
+const (
+	defaultFreeRetentionDays      = 180
+	defaultProRetentionDays       = 365
+	defaultRetentionIntervalHours = 24
+)
+
+func retentionEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// ArchiveOldChats finds chats not updated within olderThan whose owner is on
+// the given tier and deletes them; with dryRun it only logs what would be
+// deleted. Chat records don't carry a tier, so owners are resolved through
+// the subscriptions table (users without a record count as free). Returns how
+// many chats were deleted (or would have been).
+func ArchiveOldChats(ctx context.Context, client *dynamodb.Client, olderThan time.Duration, tier string, dryRun bool) (int, error) {
+	cutoff := time.Now().Add(-olderThan).Format(time.RFC3339Nano)
+	tierByUser := map[string]string{}
+	archived := 0
+
+	var startKey map[string]types.AttributeValue
+	for {
+		result, err := client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(ChatsTableName),
+			FilterExpression: aws.String("updated_at < :cutoff"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":cutoff": &types.AttributeValueMemberS{Value: cutoff},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return archived, fmt.Errorf("failed to scan old chats: %w", err)
+		}
+
+		for _, item := range result.Items {
+			var chat Chat
+			if err := attributevalue.UnmarshalMap(item, &chat); err != nil {
+				continue
+			}
+
+			ownerTier, ok := tierByUser[chat.UserID]
+			if !ok {
+				if subscription, err := GetSubscription(ctx, client, chat.UserID); err == nil {
+					ownerTier = subscription.Tier
+				} else {
+					ownerTier = TierFree
+				}
+				tierByUser[chat.UserID] = ownerTier
+			}
+			if ownerTier != tier {
+				continue
+			}
+
+			if dryRun {
+				logger.GetDailyLogger().Info("Chat retention dry-run: would delete chat %s (user %s, tier %s, updated %s)", chat.ID, chat.UserID, tier, chat.UpdatedAt.Format(time.RFC3339))
+				archived++
+				continue
+			}
+
+			if err := DeleteChat(ctx, client, chat.ID); err != nil {
+				logger.GetDailyLogger().Warn("Chat retention: failed to delete chat %s: %v", chat.ID, err)
+				continue
+			}
+			archived++
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = result.LastEvaluatedKey
+	}
+
+	return archived, nil
+}
+
+// StartChatRetentionJob starts the periodic retention sweep when
+// CHAT_RETENTION_ENABLED=true. Retention periods come from
+// CHAT_RETENTION_FREE_DAYS and CHAT_RETENTION_PRO_DAYS (the latter covers
+// Plus too), with the sweep interval from CHAT_RETENTION_INTERVAL_HOURS.
+func StartChatRetentionJob(ctx context.Context) {
+	if os.Getenv("CHAT_RETENTION_ENABLED") != "true" {
+		return
+	}
+
+	interval := time.Duration(retentionEnvInt("CHAT_RETENTION_INTERVAL_HOURS", defaultRetentionIntervalHours)) * time.Hour
+	dryRun := os.Getenv("CHAT_RETENTION_DESTRUCTIVE") != "true"
+	logger.GetDailyLogger().Info("Chat retention job enabled: interval %s, dry_run %t", interval, dryRun)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		runChatRetention(ctx, dryRun)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runChatRetention(ctx, dryRun)
+			}
+		}
+	}()
+}
+
+func runChatRetention(ctx context.Context, dryRun bool) {
+	client := GetDynamoDBClient(ctx)
+	proDays := retentionEnvInt("CHAT_RETENTION_PRO_DAYS", defaultProRetentionDays)
+
+	retentionByTier := map[string]int{
+		TierFree: retentionEnvInt("CHAT_RETENTION_FREE_DAYS", defaultFreeRetentionDays),
+		TierPlus: proDays,
+		TierPro:  proDays,
+	}
+
+	for tier, days := range retentionByTier {
+		archived, err := ArchiveOldChats(ctx, client, time.Duration(days)*24*time.Hour, tier, dryRun)
+		if err != nil {
+			logger.GetDailyLogger().Warn("Chat retention sweep failed for tier %s: %v", tier, err)
+			continue
+		}
+		if archived > 0 {
+			logger.GetDailyLogger().Info("Chat retention sweep: tier %s, %d chats older than %d days (dry_run %t)", tier, archived, days, dryRun)
+		}
+	}
+}
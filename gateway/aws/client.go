@@ -28,5 +28,13 @@ func GetDynamoDBClient(ctx context.Context) *dynamodb.Client {
 		panic("failed to load AWS config: " + err.Error())
 	}
 
+	// DYNAMODB_ENDPOINT points the client at a local DynamoDB
+	// (e.g. http://localhost:8000) for integration testing without AWS
+	if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+		return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.BaseEndpoint = &endpoint
+		})
+	}
+
 	return dynamodb.NewFromConfig(cfg)
 }
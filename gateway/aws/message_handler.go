@@ -91,6 +91,39 @@ func GetMessagesByChatID(ctx context.Context, client *dynamodb.Client, chatID st
 	return messages, nil
 }
 
+// ForEachMessageByChatID streams messages for a chat page by page, invoking fn
+// for each message as it is read instead of buffering the full result set
+func ForEachMessageByChatID(ctx context.Context, client *dynamodb.Client, chatID string, fn func(Message) error) error {
+	paginator := dynamodb.NewQueryPaginator(client, &dynamodb.QueryInput{
+		TableName:              aws.String(MessagesTableName),
+		IndexName:              aws.String(MessagesChatIDGSI),
+		KeyConditionExpression: aws.String("chat_id = :chat_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":chat_id": &types.AttributeValueMemberS{Value: chatID},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to query messages by chat_id: %w", err)
+		}
+
+		for _, item := range page.Items {
+			var message Message
+			err = attributevalue.UnmarshalMap(item, &message)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal message: %w", err)
+			}
+			if err := fn(message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetMessagesByUserID retrieves all messages for a user using GSI
 func GetMessagesByUserID(ctx context.Context, client *dynamodb.Client, userID string) ([]Message, error) {
 	result, err := client.Query(ctx, &dynamodb.QueryInput{
@@ -193,6 +226,13 @@ func DeleteMessagesIncludingAndAfter(ctx context.Context, client *dynamodb.Clien
 		}
 	}
 
+	// Decrement the chat's denormalized message count by the number deleted
+	if len(messagesToDelete) > 0 {
+		if err := RecordChatMessageActivity(ctx, client, chatID, "", time.Time{}, -len(messagesToDelete)); err != nil {
+			return fmt.Errorf("failed to update chat message metadata: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// MigrateUserData reassigns all chats, messages and the profile from one user
+// ID to another. It is idempotent: re-running after a partial failure only
+// touches items still owned by fromUserID.
+func MigrateUserData(ctx context.Context, client *dynamodb.Client, fromUserID, toUserID string) (chatsMoved, messagesMoved int, err error) {
+	// Reassign chats
+	chats, err := GetChatsByUserID(ctx, client, fromUserID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get chats for migration: %w", err)
+	}
+	for _, chat := range chats {
+		chat.UserID = toUserID
+		if _, err := UpdateChat(ctx, client, chat); err != nil {
+			return chatsMoved, messagesMoved, fmt.Errorf("failed to migrate chat %s: %w", chat.ID, err)
+		}
+		chatsMoved++
+	}
+
+	// Reassign messages
+	messages, err := GetMessagesByUserID(ctx, client, fromUserID)
+	if err != nil {
+		return chatsMoved, messagesMoved, fmt.Errorf("failed to get messages for migration: %w", err)
+	}
+	for _, message := range messages {
+		message.UserID = toUserID
+		if _, err := UpdateMessage(ctx, client, message); err != nil {
+			return chatsMoved, messagesMoved, fmt.Errorf("failed to migrate message %s: %w", message.ID, err)
+		}
+		messagesMoved++
+	}
+
+	// Carry the profile over unless the new user already has one
+	fromProfile, err := GetProfileByUserID(ctx, client, fromUserID)
+	if err == nil && fromProfile != nil {
+		toProfile, _ := GetProfileByUserID(ctx, client, toUserID)
+		if toProfile == nil {
+			newProfile := *fromProfile
+			newProfile.UserID = toUserID
+			if _, err := CreateProfile(ctx, client, newProfile); err != nil {
+				return chatsMoved, messagesMoved, fmt.Errorf("failed to migrate profile: %w", err)
+			}
+		}
+		if err := DeleteProfile(ctx, client, fromUserID); err != nil {
+			return chatsMoved, messagesMoved, fmt.Errorf("failed to delete old profile: %w", err)
+		}
+	}
+
+	return chatsMoved, messagesMoved, nil
+}
@@ -0,0 +1,190 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gateway/pkg/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tableCreateTimeout bounds how long startup waits for a new table to
+// become active before giving up
+const tableCreateTimeout = 2 * time.Minute
+
+// tableDefinition describes one required table so it can be created when
+// missing. Definitions must stay in sync with the key and index usage in
+// the handlers in this package.
+type tableDefinition struct {
+	name       string
+	attributes []types.AttributeDefinition
+	keySchema  []types.KeySchemaElement
+	gsis       []types.GlobalSecondaryIndex
+}
+
+// requiredTables lists every table the gateway reads or writes, with the
+// key schemas and GSIs the handlers query
+func requiredTables() []tableDefinition {
+	return []tableDefinition{
+		{
+			name: ProfilesTableName,
+			attributes: []types.AttributeDefinition{
+				{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("username"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			keySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+			},
+			gsis: []types.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String(ProfilesUsernameGSI),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("username"), KeyType: types.KeyTypeHash},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+		},
+		{
+			name: ChatsTableName,
+			attributes: []types.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("created_at"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			keySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("created_at"), KeyType: types.KeyTypeRange},
+			},
+			gsis: []types.GlobalSecondaryIndex{
+				{
+					IndexName: aws.String(ChatsUserIDGSI),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+		},
+		{
+			name: MessagesTableName,
+			attributes: []types.AttributeDefinition{
+				{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("chat_id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("sequence_number"), AttributeType: types.ScalarAttributeTypeN},
+			},
+			keySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			},
+			gsis: []types.GlobalSecondaryIndex{
+				{
+					// sequence_number as sort key: GetNextSequenceNumber
+					// relies on descending order within a chat
+					IndexName: aws.String(MessagesChatIDGSI),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("chat_id"), KeyType: types.KeyTypeHash},
+						{AttributeName: aws.String("sequence_number"), KeyType: types.KeyTypeRange},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+				{
+					IndexName: aws.String(MessagesUserIDGSI),
+					KeySchema: []types.KeySchemaElement{
+						{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+					},
+					Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+				},
+			},
+		},
+		{
+			name: SubscriptionsTableName,
+			attributes: []types.AttributeDefinition{
+				{AttributeName: aws.String("user_id"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			keySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("user_id"), KeyType: types.KeyTypeHash},
+			},
+		},
+		{
+			name: TableName, // kv-store
+			attributes: []types.AttributeDefinition{
+				{AttributeName: aws.String("Key"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			keySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("Key"), KeyType: types.KeyTypeHash},
+			},
+		},
+	}
+}
+
+// EnsureTables creates any missing DynamoDB tables before the server starts
+// serving. It is gated by AUTO_CREATE_TABLES=true and is a no-op otherwise,
+// so production deployments with pre-provisioned tables are unaffected.
+// Existing tables are never modified or deleted — a table that already
+// exists is left exactly as it is, even if its schema differs.
+func EnsureTables(ctx context.Context) error {
+	if os.Getenv("AUTO_CREATE_TABLES") != "true" {
+		return nil
+	}
+
+	logger.GetDailyLogger().Info("AUTO_CREATE_TABLES enabled, checking required DynamoDB tables")
+	client := GetDynamoDBClient(ctx)
+
+	for _, table := range requiredTables() {
+		if err := ensureTable(ctx, client, table); err != nil {
+			return fmt.Errorf("failed to ensure table %s: %w", table.name, err)
+		}
+	}
+
+	logger.GetDailyLogger().Info("All required DynamoDB tables are active")
+	return nil
+}
+
+// ensureTable creates one table if it does not exist and waits until it is
+// active. An existing table is left untouched.
+func ensureTable(ctx context.Context, client *dynamodb.Client, table tableDefinition) error {
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table.name),
+	})
+	if err == nil {
+		logger.GetDailyLogger().Info("Table %s already exists, leaving it untouched", table.name)
+		return nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("describe failed: %w", err)
+	}
+
+	logger.GetDailyLogger().Info("Table %s not found, creating it", table.name)
+	input := &dynamodb.CreateTableInput{
+		TableName:            aws.String(table.name),
+		AttributeDefinitions: table.attributes,
+		KeySchema:            table.keySchema,
+		BillingMode:          types.BillingModePayPerRequest,
+	}
+	if len(table.gsis) > 0 {
+		input.GlobalSecondaryIndexes = table.gsis
+	}
+
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return fmt.Errorf("create failed: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table.name),
+	}, tableCreateTimeout); err != nil {
+		return fmt.Errorf("table did not become active: %w", err)
+	}
+
+	logger.GetDailyLogger().Info("Table %s created and active", table.name)
+	return nil
+}
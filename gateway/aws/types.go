@@ -23,6 +23,12 @@ type Chat struct {
 	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
 	Sharing   string    `json:"sharing" dynamodbav:"sharing"` // 'private', 'public', etc.
 	Name      string    `json:"name" dynamodbav:"name"`
+
+	// Denormalized last-message metadata, maintained on message writes so the
+	// chat list doesn't need a per-chat message query
+	LastMessagePreview string     `json:"last_message_preview,omitempty" dynamodbav:"last_message_preview,omitempty"`
+	LastMessageAt      *time.Time `json:"last_message_at,omitempty" dynamodbav:"last_message_at,omitempty"`
+	MessageCount       int        `json:"message_count,omitempty" dynamodbav:"message_count,omitempty"`
 }
 
 // Message represents the messages table
@@ -36,6 +42,13 @@ type Message struct {
 	ModelName      string    `json:"model_name" dynamodbav:"model_name"`
 	Role           string    `json:"role" dynamodbav:"role"`
 	SequenceNumber int       `json:"sequence_number" dynamodbav:"sequence_number"`
+	Truncated      bool      `json:"truncated,omitempty" dynamodbav:"truncated,omitempty"`
+	Seed           *int      `json:"seed,omitempty" dynamodbav:"seed,omitempty"` // Generation seed, when supplied
+
+	// Estimated token split for assistant messages from thinking models:
+	// tokens spent on reasoning vs the visible answer
+	ThinkingTokens int `json:"thinking_tokens,omitempty" dynamodbav:"thinking_tokens,omitempty"`
+	AnswerTokens   int `json:"answer_tokens,omitempty" dynamodbav:"answer_tokens,omitempty"`
 }
 
 // Subscription represents the subscriptions table (matches payment service structure)
@@ -9,10 +9,14 @@ import (
 	"syscall"
 	"time"
 
+	"gateway/aws"
+	"gateway/config"
 	"gateway/handlers"
 	"gateway/middleware"
+	"gateway/models"
 	"gateway/pkg/logger"
 	"gateway/pkg/redis"
+	"gateway/services"
 
 	"github.com/joho/godotenv"
 )
@@ -77,6 +81,20 @@ func setupRoutes() http.Handler {
 		).ServeHTTP(w, r)
 	})
 
+	// Resume a disconnected stream's persisted partial content - requires
+	// authentication but not rate limiting (the original request was charged)
+	mux.HandleFunc("/v1/complete/resume", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		middleware.CORSMiddleware(
+			middleware.FirebaseAuthMiddleware(
+				http.HandlerFunc(handlers.ResumeStreamHandler),
+			),
+		).ServeHTTP(w, r)
+	})
+
 	// Rate limit status endpoint - requires authentication
 	mux.HandleFunc("/v1/rate-limit-status", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet && r.Method != http.MethodOptions {
@@ -108,6 +126,35 @@ func main() {
 	maxProcs := runtime.GOMAXPROCS(0)
 	logger.GetDailyLogger().Info("Gateway server initializing with %d CPU cores", maxProcs)
 
+	// Validate classifier configuration early so bad values surface at startup
+	services.InitClassifierService()
+
+	// Validate rate limit configuration early so bad values surface at startup
+	if _, err := config.LoadSubscriptionConfig(); err != nil {
+		logger.GetDailyLogger().Error("Failed to load rate limit configuration: %v", err)
+		os.Exit(1)
+	}
+
+	// Load per-provider system prompt overrides from SYSTEM_PROMPT_DIR, if set
+	if loaded, err := models.Config.LoadSystemPromptsFromDir(); err != nil {
+		logger.GetDailyLogger().Error("Failed to load system prompts: %v", err)
+		os.Exit(1)
+	} else if len(loaded) > 0 {
+		logger.GetDailyLogger().Info("Loaded system prompt overrides for: %v", loaded)
+	}
+
+	// Optionally warm up provider connection pools (PROVIDER_WARMUP=true)
+	services.WarmUpProviders()
+
+	// Optionally create missing DynamoDB tables before serving (AUTO_CREATE_TABLES=true)
+	if err := aws.EnsureTables(context.Background()); err != nil {
+		logger.GetDailyLogger().Error("Failed to create DynamoDB tables: %v", err)
+		os.Exit(1)
+	}
+
+	// Optionally prune stale chats in the background (CHAT_RETENTION_ENABLED=true)
+	aws.StartChatRetentionJob(context.Background())
+
 	// Initialize Redis for rate limiting
 	redisURL := getEnvWithDefault("REDIS_URL", "redis://localhost:6379")
 	if err := redis.InitRedis(redisURL); err != nil {
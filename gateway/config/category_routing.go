@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CategoryRoutingConfig maps classifier categories to preferred providers
+type CategoryRoutingConfig struct {
+	MinConfidence float64             `json:"min_confidence" yaml:"min_confidence"` // Confidence required before overriding classifier ordering
+	Categories    map[string][]string `json:"categories" yaml:"categories"`         // Category -> preferred providers, highest priority first
+}
+
+// Default configuration: no overrides, routing follows the classifier
+var defaultCategoryRoutingConfig = CategoryRoutingConfig{
+	MinConfidence: 0.8,
+	Categories:    map[string][]string{},
+}
+
+// Global config instance
+var categoryRoutingConfig *CategoryRoutingConfig
+
+// LoadCategoryRoutingConfig loads category routing from file or uses defaults
+func LoadCategoryRoutingConfig() (*CategoryRoutingConfig, error) {
+	if categoryRoutingConfig != nil {
+		return categoryRoutingConfig, nil
+	}
+
+	configFile := os.Getenv("CATEGORY_ROUTING_CONFIG_FILE")
+	if configFile == "" {
+		// Default to config file in same directory
+		configFile = filepath.Join("config", "category_routing.yaml")
+	}
+
+	if _, err := os.Stat(configFile); err == nil {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read category routing config file: %w", err)
+		}
+
+		var config CategoryRoutingConfig
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse category routing config file: %w", err)
+		}
+
+		if config.MinConfidence <= 0 {
+			config.MinConfidence = defaultCategoryRoutingConfig.MinConfidence
+		}
+
+		categoryRoutingConfig = &config
+		return categoryRoutingConfig, nil
+	}
+
+	// Use default configuration
+	categoryRoutingConfig = &defaultCategoryRoutingConfig
+	return categoryRoutingConfig, nil
+}
+
+// GetPreferredProviders returns the preferred provider ordering for a category,
+// or nil when no override applies (unknown category or low confidence)
+func GetPreferredProviders(category string, confidence float64) []string {
+	config, err := LoadCategoryRoutingConfig()
+	if err != nil {
+		return nil
+	}
+
+	if confidence < config.MinConfidence {
+		return nil
+	}
+
+	return config.Categories[category]
+}
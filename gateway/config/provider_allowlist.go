@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ProviderAllowlistConfig maps model names to the provider they must be
+// served by. It guards against classifier data bugs that pair a model with
+// the wrong provider, which would otherwise send the request to an endpoint
+// that cannot serve it.
+type ProviderAllowlistConfig struct {
+	Models map[string]string `json:"models" yaml:"models"` // Model name -> expected provider
+}
+
+// Default configuration: no mappings, the classifier's provider is trusted
+var defaultProviderAllowlistConfig = ProviderAllowlistConfig{
+	Models: map[string]string{},
+}
+
+// Global config instance
+var providerAllowlistConfig *ProviderAllowlistConfig
+
+// LoadProviderAllowlistConfig loads the model->provider allow-list from file
+// or uses defaults
+func LoadProviderAllowlistConfig() (*ProviderAllowlistConfig, error) {
+	if providerAllowlistConfig != nil {
+		return providerAllowlistConfig, nil
+	}
+
+	configFile := os.Getenv("PROVIDER_ALLOWLIST_CONFIG_FILE")
+	if configFile == "" {
+		// Default to config file in same directory
+		configFile = filepath.Join("config", "provider_allowlist.yaml")
+	}
+
+	if _, err := os.Stat(configFile); err == nil {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read provider allowlist config file: %w", err)
+		}
+
+		var config ProviderAllowlistConfig
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse provider allowlist config file: %w", err)
+		}
+
+		if config.Models == nil {
+			config.Models = map[string]string{}
+		}
+
+		providerAllowlistConfig = &config
+		return providerAllowlistConfig, nil
+	}
+
+	// Use default configuration
+	providerAllowlistConfig = &defaultProviderAllowlistConfig
+	return providerAllowlistConfig, nil
+}
+
+// ExpectedProvider returns the provider a model must be served by, or ""
+// when the model has no allow-list entry
+func ExpectedProvider(model string) string {
+	config, err := LoadProviderAllowlistConfig()
+	if err != nil {
+		return ""
+	}
+
+	return config.Models[model]
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -28,6 +31,110 @@ type RateLimitConfig struct {
 	DailyReset        bool `json:"daily_reset" yaml:"daily_reset"`                 // Whether to reset daily
 	RequestsPerMinute int  `json:"requests_per_minute" yaml:"requests_per_minute"` // Per-minute rate limit
 	LifetimeLimit     bool `json:"lifetime_limit" yaml:"lifetime_limit"`           // Whether this is a lifetime limit (for anonymous)
+	LifetimeRequests  int  `json:"lifetime_requests" yaml:"lifetime_requests"`     // Total requests ever allowed when LifetimeLimit is set (0 falls back to RequestsPerDay)
+
+	MaxDailyCostUSD    float64 `json:"max_daily_cost_usd" yaml:"max_daily_cost_usd"`     // Estimated daily spend cap in USD (0 means unlimited)
+	MaxChats           int     `json:"max_chats" yaml:"max_chats"`                       // Max chats a user may accumulate (0 means unlimited)
+	MaxContextMessages int     `json:"max_context_messages" yaml:"max_context_messages"` // History messages included in provider context (0 uses the default window)
+
+	FallbackModel            string `json:"fallback_model" yaml:"fallback_model"`                           // Model used when the classifier returns no usable scores (empty uses the global default)
+	FallbackProvider         string `json:"fallback_provider" yaml:"fallback_provider"`                     // Provider for the fallback model
+	FallbackModelDisplayName string `json:"fallback_model_display_name" yaml:"fallback_model_display_name"` // Display name shown to the user for the fallback model
+}
+
+// EffectiveLifetimeRequests returns the total request cap for lifetime-limited
+// tiers. Config files written before LifetimeRequests existed reused
+// RequestsPerDay for this, so fall back to it when the field is unset.
+func (c RateLimitConfig) EffectiveLifetimeRequests() int {
+	if c.LifetimeRequests > 0 {
+		return c.LifetimeRequests
+	}
+	return c.RequestsPerDay
+}
+
+// EffectiveMaxDailyCostUSD returns the daily spend cap for a tier, falling
+// back to the global MAX_DAILY_COST_USD when the tier has none. Zero means
+// unlimited, which is the default.
+func (c RateLimitConfig) EffectiveMaxDailyCostUSD() float64 {
+	if c.MaxDailyCostUSD > 0 {
+		return c.MaxDailyCostUSD
+	}
+	if value := os.Getenv("MAX_DAILY_COST_USD"); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// EffectiveMaxChats returns the chat count cap for a tier, falling back to
+// the global MAX_CHATS_PER_USER when the tier has none. Zero means unlimited,
+// which is the default.
+func (c RateLimitConfig) EffectiveMaxChats() int {
+	if c.MaxChats > 0 {
+		return c.MaxChats
+	}
+	if value := os.Getenv("MAX_CHATS_PER_USER"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// DefaultMaxContextMessages matches the providers' long-standing last-4
+// history window
+const DefaultMaxContextMessages = 4
+
+// EffectiveMaxContextMessages returns how many history messages the tier may
+// include in provider context, falling back to the global
+// MAX_CONTEXT_MESSAGES and then the built-in default
+func (c RateLimitConfig) EffectiveMaxContextMessages() int {
+	if c.MaxContextMessages > 0 {
+		return c.MaxContextMessages
+	}
+	if value := os.Getenv("MAX_CONTEXT_MESSAGES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return DefaultMaxContextMessages
+}
+
+// Built-in fallback model used when neither the tier config nor the global
+// env vars name one; matches the Gemini provider's own default
+const (
+	defaultFallbackModel            = "gemini-2.0-flash-exp"
+	defaultFallbackProvider         = "gemini"
+	defaultFallbackModelDisplayName = "Gemini 2.0 Flash"
+)
+
+// EffectiveFallbackModel returns the model, provider and display name to use
+// when the classifier supplies no usable scores. Tier config wins, then the
+// global FALLBACK_MODEL/FALLBACK_PROVIDER/FALLBACK_MODEL_DISPLAY_NAME env
+// vars, then the built-in Gemini default, so outages degrade to a model
+// appropriate for the tier rather than one global choice.
+func (c RateLimitConfig) EffectiveFallbackModel() (model, provider, displayName string) {
+	model, provider, displayName = c.FallbackModel, c.FallbackProvider, c.FallbackModelDisplayName
+	if model == "" {
+		model = os.Getenv("FALLBACK_MODEL")
+	}
+	if provider == "" {
+		provider = os.Getenv("FALLBACK_PROVIDER")
+	}
+	if displayName == "" {
+		displayName = os.Getenv("FALLBACK_MODEL_DISPLAY_NAME")
+	}
+	if model == "" || provider == "" {
+		model, provider = defaultFallbackModel, defaultFallbackProvider
+		if displayName == "" {
+			displayName = defaultFallbackModelDisplayName
+		}
+	}
+	if displayName == "" {
+		displayName = model
+	}
+	return model, provider, displayName
 }
 
 // SuspiciousActivityConfig represents suspicious activity detection settings
@@ -63,6 +170,9 @@ var defaultSubscriptionConfig = SubscriptionConfig{
 		DailyReset:        false, // No daily reset for anonymous (lifetime limit)
 		RequestsPerMinute: 5,     // Rate limit per minute
 		LifetimeLimit:     true,  // Anonymous users have lifetime limits
+		LifetimeRequests:  5,     // 5 requests total, ever
+
+		MaxContextMessages: 2, // Last 2 history messages sent as context
 	},
 	Free: RateLimitConfig{
 		FreeRequests:      100,   // 100 free requests per day
@@ -71,6 +181,8 @@ var defaultSubscriptionConfig = SubscriptionConfig{
 		DailyReset:        true,  // Reset daily at midnight
 		RequestsPerMinute: 10,    // Rate limit per minute
 		LifetimeLimit:     false, // Daily limit, not lifetime
+
+		MaxContextMessages: 2, // Last 2 history messages sent as context
 	},
 	Plus: RateLimitConfig{
 		FreeRequests:      -1,    // Unlimited free requests
@@ -79,6 +191,8 @@ var defaultSubscriptionConfig = SubscriptionConfig{
 		DailyReset:        true,  // Reset daily at midnight
 		RequestsPerMinute: 15,    // Rate limit per minute
 		LifetimeLimit:     false, // Daily limit, not lifetime
+
+		MaxContextMessages: 6, // Last 6 history messages sent as context
 	},
 	Pro: RateLimitConfig{
 		FreeRequests:      -1,    // Unlimited free requests
@@ -87,6 +201,8 @@ var defaultSubscriptionConfig = SubscriptionConfig{
 		DailyReset:        true,  // Reset daily at midnight
 		RequestsPerMinute: 20,    // Rate limit per minute
 		LifetimeLimit:     false, // Daily limit, not lifetime
+
+		MaxContextMessages: 8, // Last 8 history messages sent as context
 	},
 	SuspiciousActivity: SuspiciousActivityConfig{
 		Threshold:      21,  // Max requests in window before blocking
@@ -100,14 +216,70 @@ var defaultSubscriptionConfig = SubscriptionConfig{
 	},
 }
 
-// Global config instance
-var subscriptionConfig *SubscriptionConfig
+// Global config instance, guarded so reloads can swap it atomically
+var (
+	subscriptionConfig   *SubscriptionConfig
+	subscriptionConfigMu sync.RWMutex
+)
 
-// LoadSubscriptionConfig loads configuration from environment or uses defaults
+// LoadSubscriptionConfig loads configuration from environment or uses
+// defaults, applies per-field env overrides and validates the result
 func LoadSubscriptionConfig() (*SubscriptionConfig, error) {
+	subscriptionConfigMu.RLock()
 	if subscriptionConfig != nil {
+		defer subscriptionConfigMu.RUnlock()
 		return subscriptionConfig, nil
 	}
+	subscriptionConfigMu.RUnlock()
+
+	return reloadSubscriptionConfig()
+}
+
+// ReloadSubscriptionConfig re-reads the configured source and swaps the
+// active config on success, returning the previous and new values so the
+// caller can log the diff. On error the old config stays live.
+func ReloadSubscriptionConfig() (*SubscriptionConfig, *SubscriptionConfig, error) {
+	subscriptionConfigMu.RLock()
+	old := subscriptionConfig
+	subscriptionConfigMu.RUnlock()
+
+	updated, err := reloadSubscriptionConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return old, updated, nil
+}
+
+func reloadSubscriptionConfig() (*SubscriptionConfig, error) {
+	config, err := resolveSubscriptionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	applyEnvOverrides(config)
+
+	if err := validateSubscriptionConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid rate limit configuration: %w", err)
+	}
+
+	subscriptionConfigMu.Lock()
+	subscriptionConfig = config
+	subscriptionConfigMu.Unlock()
+	return config, nil
+}
+
+// resolveSubscriptionConfig picks the config source: an explicit
+// RATE_LIMIT_CONFIG_PATH, the legacy YAML/JSON locations, or the built-in
+// defaults
+func resolveSubscriptionConfig() (*SubscriptionConfig, error) {
+	// An explicitly configured path must load; a typo silently falling back
+	// to defaults would change limits in production
+	if path := os.Getenv("RATE_LIMIT_CONFIG_PATH"); path != "" {
+		if strings.HasSuffix(path, ".json") {
+			return loadFromJSONFile(path)
+		}
+		return loadFromYAMLFile(path)
+	}
 
 	// Try to load from YAML config file first
 	configFile := os.Getenv("SUBSCRIPTION_CONFIG_FILE")
@@ -118,8 +290,7 @@ func LoadSubscriptionConfig() (*SubscriptionConfig, error) {
 
 	if _, err := os.Stat(configFile); err == nil {
 		if config, err := loadFromYAMLFile(configFile); err == nil {
-			subscriptionConfig = config
-			return subscriptionConfig, nil
+			return config, nil
 		}
 	}
 
@@ -127,14 +298,124 @@ func LoadSubscriptionConfig() (*SubscriptionConfig, error) {
 	jsonConfigFile := os.Getenv("SUBSCRIPTION_JSON_CONFIG_FILE")
 	if jsonConfigFile != "" {
 		if config, err := loadFromJSONFile(jsonConfigFile); err == nil {
-			subscriptionConfig = config
-			return subscriptionConfig, nil
+			return config, nil
+		}
+	}
+
+	// Use default configuration (copied so overrides don't mutate it)
+	defaults := defaultSubscriptionConfig
+	return &defaults, nil
+}
+
+// applyEnvOverrides lets individual fields be overridden without editing the
+// config file, using RATE_LIMIT_<TIER>_<FIELD> keys (e.g.
+// RATE_LIMIT_FREE_REQUESTS_PER_DAY=200)
+func applyEnvOverrides(config *SubscriptionConfig) {
+	tiers := map[string]*RateLimitConfig{
+		"ANONYMOUS": &config.Anonymous,
+		"FREE":      &config.Free,
+		"PLUS":      &config.Plus,
+		"PRO":       &config.Pro,
+	}
+
+	for name, tier := range tiers {
+		overrideInt("RATE_LIMIT_"+name+"_FREE_REQUESTS", &tier.FreeRequests)
+		overrideInt("RATE_LIMIT_"+name+"_MAX_REQUESTS", &tier.MaxRequests)
+		overrideInt("RATE_LIMIT_"+name+"_REQUESTS_PER_DAY", &tier.RequestsPerDay)
+		overrideInt("RATE_LIMIT_"+name+"_REQUESTS_PER_MINUTE", &tier.RequestsPerMinute)
+		overrideInt("RATE_LIMIT_"+name+"_LIFETIME_REQUESTS", &tier.LifetimeRequests)
+		overrideBool("RATE_LIMIT_"+name+"_DAILY_RESET", &tier.DailyReset)
+		overrideBool("RATE_LIMIT_"+name+"_LIFETIME_LIMIT", &tier.LifetimeLimit)
+		overrideFloat("RATE_LIMIT_"+name+"_MAX_DAILY_COST_USD", &tier.MaxDailyCostUSD)
+		overrideInt("RATE_LIMIT_"+name+"_MAX_CHATS", &tier.MaxChats)
+		overrideInt("RATE_LIMIT_"+name+"_MAX_CONTEXT_MESSAGES", &tier.MaxContextMessages)
+		overrideString("RATE_LIMIT_"+name+"_FALLBACK_MODEL", &tier.FallbackModel)
+		overrideString("RATE_LIMIT_"+name+"_FALLBACK_PROVIDER", &tier.FallbackProvider)
+		overrideString("RATE_LIMIT_"+name+"_FALLBACK_MODEL_DISPLAY_NAME", &tier.FallbackModelDisplayName)
+	}
+}
+
+func overrideInt(key string, target *int) {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			*target = parsed
 		}
 	}
+}
+
+func overrideString(key string, target *string) {
+	if value := os.Getenv(key); value != "" {
+		*target = value
+	}
+}
 
-	// Use default configuration
-	subscriptionConfig = &defaultSubscriptionConfig
-	return subscriptionConfig, nil
+func overrideBool(key string, target *bool) {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			*target = parsed
+		}
+	}
+}
+
+func overrideFloat(key string, target *float64) {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			*target = parsed
+		}
+	}
+}
+
+// validateSubscriptionConfig rejects tiers with missing or contradictory
+// settings so misconfiguration fails at startup rather than at request time
+func validateSubscriptionConfig(config *SubscriptionConfig) error {
+	tiers := []struct {
+		name string
+		cfg  RateLimitConfig
+	}{
+		{"anonymous", config.Anonymous},
+		{"free", config.Free},
+		{"plus", config.Plus},
+		{"pro", config.Pro},
+	}
+
+	for _, tier := range tiers {
+		if tier.cfg.RequestsPerMinute <= 0 {
+			return fmt.Errorf("tier %s: requests_per_minute must be positive", tier.name)
+		}
+		if tier.cfg.RequestsPerDay == 0 {
+			return fmt.Errorf("tier %s: requests_per_day is required", tier.name)
+		}
+		if tier.cfg.FreeRequests == 0 && tier.cfg.MaxRequests == 0 {
+			return fmt.Errorf("tier %s: free_requests and max_requests cannot both be zero", tier.name)
+		}
+		if tier.cfg.LifetimeLimit && tier.cfg.DailyReset {
+			return fmt.Errorf("tier %s: lifetime_limit and daily_reset are contradictory", tier.name)
+		}
+		if tier.cfg.LifetimeRequests < 0 {
+			return fmt.Errorf("tier %s: lifetime_requests cannot be negative", tier.name)
+		}
+		if tier.cfg.MaxDailyCostUSD < 0 {
+			return fmt.Errorf("tier %s: max_daily_cost_usd cannot be negative", tier.name)
+		}
+		if tier.cfg.MaxChats < 0 {
+			return fmt.Errorf("tier %s: max_chats cannot be negative", tier.name)
+		}
+		switch tier.cfg.FallbackProvider {
+		case "", "gemini", "groq", "openrouter":
+		default:
+			return fmt.Errorf("tier %s: unknown fallback_provider %q", tier.name, tier.cfg.FallbackProvider)
+		}
+	}
+
+	if config.SuspiciousActivity.Threshold <= 0 || config.SuspiciousActivity.Window <= 0 ||
+		config.SuspiciousActivity.BlockDuration <= 0 || config.SuspiciousActivity.TrackingWindow <= 0 {
+		return fmt.Errorf("suspicious_activity: threshold, window, block_duration and tracking_window must be positive")
+	}
+	if config.Cleanup.Interval <= 0 || config.Cleanup.TTL <= 0 {
+		return fmt.Errorf("cleanup: interval and ttl must be positive")
+	}
+
+	return nil
 }
 
 // loadFromYAMLFile loads configuration from a YAML file
@@ -206,9 +487,16 @@ func GetCleanupConfig() (CleanupConfig, error) {
 	return config.Cleanup, nil
 }
 
-// IsUnlimited checks if a limit is unlimited (-1)
+// Unlimited is the sentinel value used throughout the config for "no limit"
+const Unlimited = -1
+
+// UnlimitedHeaderValue is what rate limit headers report for unlimited tiers
+// instead of a fake large number
+const UnlimitedHeaderValue = "unlimited"
+
+// IsUnlimited checks if a limit is unlimited
 func IsUnlimited(limit int) bool {
-	return limit == -1
+	return limit == Unlimited
 }
 
 // ValidateSubscriptionTier validates if a tier is valid
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSubscriptionConfigDefaults(t *testing.T) {
+	defaults := defaultSubscriptionConfig
+	if err := validateSubscriptionConfig(&defaults); err != nil {
+		t.Errorf("default configuration should validate, got: %v", err)
+	}
+}
+
+func TestValidateSubscriptionConfigRejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *SubscriptionConfig)
+		wantErr string
+	}{
+		{
+			name:    "zero requests_per_minute",
+			mutate:  func(c *SubscriptionConfig) { c.Free.RequestsPerMinute = 0 },
+			wantErr: "requests_per_minute must be positive",
+		},
+		{
+			name:    "missing requests_per_day",
+			mutate:  func(c *SubscriptionConfig) { c.Pro.RequestsPerDay = 0 },
+			wantErr: "requests_per_day is required",
+		},
+		{
+			name: "both request budgets zero",
+			mutate: func(c *SubscriptionConfig) {
+				c.Plus.FreeRequests = 0
+				c.Plus.MaxRequests = 0
+			},
+			wantErr: "cannot both be zero",
+		},
+		{
+			name: "lifetime limit with daily reset",
+			mutate: func(c *SubscriptionConfig) {
+				c.Anonymous.LifetimeLimit = true
+				c.Anonymous.DailyReset = true
+			},
+			wantErr: "contradictory",
+		},
+		{
+			name:    "negative lifetime_requests",
+			mutate:  func(c *SubscriptionConfig) { c.Anonymous.LifetimeRequests = -2 },
+			wantErr: "lifetime_requests cannot be negative",
+		},
+		{
+			name:    "negative max_daily_cost_usd",
+			mutate:  func(c *SubscriptionConfig) { c.Free.MaxDailyCostUSD = -0.5 },
+			wantErr: "max_daily_cost_usd cannot be negative",
+		},
+		{
+			name:    "negative max_chats",
+			mutate:  func(c *SubscriptionConfig) { c.Pro.MaxChats = -1 },
+			wantErr: "max_chats cannot be negative",
+		},
+		{
+			name:    "unknown fallback_provider",
+			mutate:  func(c *SubscriptionConfig) { c.Free.FallbackProvider = "bedrock" },
+			wantErr: "unknown fallback_provider",
+		},
+		{
+			name:    "non-positive suspicious activity window",
+			mutate:  func(c *SubscriptionConfig) { c.SuspiciousActivity.Window = 0 },
+			wantErr: "suspicious_activity",
+		},
+		{
+			name:    "non-positive cleanup interval",
+			mutate:  func(c *SubscriptionConfig) { c.Cleanup.Interval = 0 },
+			wantErr: "cleanup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultSubscriptionConfig
+			tt.mutate(&config)
+			err := validateSubscriptionConfig(&config)
+			if err == nil {
+				t.Fatal("validateSubscriptionConfig accepted an invalid config")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEffectiveMaxChats(t *testing.T) {
+	t.Setenv("MAX_CHATS_PER_USER", "")
+
+	// Default: no cap
+	if got := (RateLimitConfig{}).EffectiveMaxChats(); got != 0 {
+		t.Errorf("EffectiveMaxChats with no config = %d, want 0 (unlimited)", got)
+	}
+
+	// Tier value wins
+	if got := (RateLimitConfig{MaxChats: 50}).EffectiveMaxChats(); got != 50 {
+		t.Errorf("EffectiveMaxChats with tier cap = %d, want 50", got)
+	}
+
+	// Global env fallback when the tier has none
+	t.Setenv("MAX_CHATS_PER_USER", "200")
+	if got := (RateLimitConfig{}).EffectiveMaxChats(); got != 200 {
+		t.Errorf("EffectiveMaxChats with env fallback = %d, want 200", got)
+	}
+
+	// Tier value still wins over the env fallback
+	if got := (RateLimitConfig{MaxChats: 50}).EffectiveMaxChats(); got != 50 {
+		t.Errorf("EffectiveMaxChats with both = %d, want the tier's 50", got)
+	}
+
+	// Invalid env values are ignored
+	t.Setenv("MAX_CHATS_PER_USER", "lots")
+	if got := (RateLimitConfig{}).EffectiveMaxChats(); got != 0 {
+		t.Errorf("EffectiveMaxChats with invalid env = %d, want 0", got)
+	}
+}
+
+func TestEffectiveMaxContextMessages(t *testing.T) {
+	t.Setenv("MAX_CONTEXT_MESSAGES", "")
+
+	// Default: the providers' long-standing window
+	if got := (RateLimitConfig{}).EffectiveMaxContextMessages(); got != DefaultMaxContextMessages {
+		t.Errorf("EffectiveMaxContextMessages with no config = %d, want %d", got, DefaultMaxContextMessages)
+	}
+
+	// Tier value wins
+	if got := (RateLimitConfig{MaxContextMessages: 8}).EffectiveMaxContextMessages(); got != 8 {
+		t.Errorf("EffectiveMaxContextMessages with tier value = %d, want 8", got)
+	}
+
+	// Global env fallback when the tier has none
+	t.Setenv("MAX_CONTEXT_MESSAGES", "12")
+	if got := (RateLimitConfig{}).EffectiveMaxContextMessages(); got != 12 {
+		t.Errorf("EffectiveMaxContextMessages with env fallback = %d, want 12", got)
+	}
+
+	// Tier value still wins over the env fallback
+	if got := (RateLimitConfig{MaxContextMessages: 8}).EffectiveMaxContextMessages(); got != 8 {
+		t.Errorf("EffectiveMaxContextMessages with both = %d, want the tier's 8", got)
+	}
+
+	// Invalid env values are ignored
+	t.Setenv("MAX_CONTEXT_MESSAGES", "many")
+	if got := (RateLimitConfig{}).EffectiveMaxContextMessages(); got != DefaultMaxContextMessages {
+		t.Errorf("EffectiveMaxContextMessages with invalid env = %d, want %d", got, DefaultMaxContextMessages)
+	}
+
+	// The default tier configs scale the window with the tier
+	if defaultSubscriptionConfig.Pro.EffectiveMaxContextMessages() <= defaultSubscriptionConfig.Free.EffectiveMaxContextMessages() {
+		t.Error("pro should get a larger history window than free by default")
+	}
+}
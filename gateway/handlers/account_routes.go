@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"gateway/aws"
+	"gateway/config"
+	"gateway/middleware"
+	"gateway/pkg/logger"
+)
+
+// SetupAccountRoutes sets up account lifecycle routes with Firebase authentication
+func SetupAccountRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/account/migrate", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.FirebaseAuthMiddleware(http.HandlerFunc(MigrateAccountHandler)),
+		).ServeHTTP(w, r)
+	})
+}
+
+// MigrateAccountRequest is the body for POST /v1/account/migrate
+type MigrateAccountRequest struct {
+	AnonymousUID   string `json:"anonymous_uid"`
+	AnonymousToken string `json:"anonymous_token"`
+}
+
+// MigrateAccountResponse reports what was carried over to the new account
+type MigrateAccountResponse struct {
+	ChatsMigrated    int    `json:"chats_migrated"`
+	MessagesMigrated int    `json:"messages_migrated"`
+	UserID           string `json:"user_id"`
+}
+
+// MigrateAccountHandler reassigns an anonymous user's chats, messages, profile
+// and usage to the newly authenticated account. The caller authenticates with
+// the new account's token and proves ownership of the anonymous account by
+// supplying its still-valid ID token.
+func MigrateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log := logger.GetLogger("account_migration")
+
+	// The authenticated (new) user comes from the auth middleware
+	user, ok := middleware.GetFirebaseUserFromContext(r.Context())
+	if !ok || user == nil {
+		sendAPIErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	if middleware.IsAnonymousUser(user) {
+		sendAPIErrorResponse(w, "Migration target must be a signed-up account", http.StatusBadRequest)
+		return
+	}
+
+	var reqBody MigrateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		sendAPIErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if reqBody.AnonymousUID == "" || reqBody.AnonymousToken == "" {
+		sendAPIErrorResponse(w, "anonymous_uid and anonymous_token are required", http.StatusBadRequest)
+		return
+	}
+	if reqBody.AnonymousUID == user.UID {
+		sendAPIErrorResponse(w, "Cannot migrate an account to itself", http.StatusBadRequest)
+		return
+	}
+
+	// Prove ownership of the anonymous account via its token
+	anonUser, err := middleware.VerifyIDToken(r.Context(), reqBody.AnonymousToken)
+	if err != nil {
+		log.WarnWithFields("Anonymous token verification failed", map[string]interface{}{
+			"error":   err.Error(),
+			"new_uid": user.UID,
+		})
+		sendAPIErrorResponse(w, "Invalid anonymous token", http.StatusUnauthorized)
+		return
+	}
+	if anonUser.UID != reqBody.AnonymousUID {
+		sendAPIErrorResponse(w, "Anonymous token does not match anonymous_uid", http.StatusForbidden)
+		return
+	}
+	if !middleware.IsAnonymousUser(anonUser) {
+		sendAPIErrorResponse(w, "Source account is not anonymous", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	client := aws.GetDynamoDBClient(ctx)
+
+	chatsMoved, messagesMoved, err := aws.MigrateUserData(ctx, client, anonUser.UID, user.UID)
+	if err != nil {
+		log.Error("Failed to migrate user data", err)
+		sendAPIErrorResponse(w, "Failed to migrate account data", http.StatusInternalServerError)
+		return
+	}
+
+	// Carry the anonymous usage over so sign-up doesn't reset consumption
+	if err := middleware.MigrateUsage(ctx, "anonymous:"+anonUser.UID, "user:"+user.UID, config.TierFree); err != nil {
+		// Data migration succeeded; a usage-merge failure shouldn't fail the request
+		log.WarnWithFields("Failed to migrate usage key", map[string]interface{}{
+			"error":    err.Error(),
+			"anon_uid": anonUser.UID,
+			"new_uid":  user.UID,
+		})
+	}
+
+	log.InfoWithFields("Account migration completed", map[string]interface{}{
+		"anon_uid":          anonUser.UID,
+		"new_uid":           user.UID,
+		"chats_migrated":    chatsMoved,
+		"messages_migrated": messagesMoved,
+	})
+
+	sendJSONResponse(w, MigrateAccountResponse{
+		ChatsMigrated:    chatsMoved,
+		MessagesMigrated: messagesMoved,
+		UserID:           user.UID,
+	}, http.StatusOK)
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gateway/config"
+	"gateway/middleware"
+	"gateway/models"
+	"gateway/pkg/iputil"
+	"gateway/pkg/logger"
+	"gateway/services"
+)
+
+// SetupAdminRoutes sets up operational admin routes protected by the admin key
+// or a Firebase token with the admin role
+func SetupAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/admin/circuit/classifier", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(ClassifierCircuitHandler)),
+		).ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/v1/admin/providers/disabled", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(DisabledProvidersHandler)),
+		).ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/v1/admin/config", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(EffectiveConfigHandler)),
+		).ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/v1/admin/config/reload", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(ReloadRateLimitConfigHandler)),
+		).ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/v1/admin/usage-stats", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(UsageStatsHandler)),
+		).ServeHTTP(w, r)
+	})
+
+	mux.HandleFunc("/v1/admin/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		middleware.CORSMiddleware(
+			middleware.AdminAccessMiddleware(http.HandlerFunc(BlocklistHandler)),
+		).ServeHTTP(w, r)
+	})
+}
+
+// BlocklistHandler handles POST/DELETE /v1/admin/blocklist for manually
+// banning and unbanning users and IPs
+func BlocklistHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind       string `json:"kind"` // "user" or "ip"
+		ID         string `json:"id"`
+		Reason     string `json:"reason,omitempty"`
+		TTLSeconds int    `json:"ttl_seconds,omitempty"` // 0 = no expiry
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := middleware.ValidateBlocklistKind(req.Kind); err != nil {
+		sendAPIErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		sendAPIErrorResponse(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	log := logger.GetLogger("blocklist_admin")
+
+	switch r.Method {
+	case http.MethodPost:
+		ttl := time.Duration(req.TTLSeconds) * time.Second
+		if err := middleware.AddBlocklistEntry(r.Context(), req.Kind, req.ID, req.Reason, ttl); err != nil {
+			sendAPIErrorResponse(w, "Failed to add blocklist entry", http.StatusInternalServerError)
+			return
+		}
+
+		// Record every manual block and who triggered it
+		log.InfoWithFields("Blocklist entry added", map[string]interface{}{
+			"kind":        req.Kind,
+			"id":          req.ID,
+			"reason":      req.Reason,
+			"ttl_seconds": req.TTLSeconds,
+			"client_ip":   iputil.ClientIP(r),
+		})
+
+		sendJSONResponse(w, map[string]string{"status": "blocked"}, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := middleware.RemoveBlocklistEntry(r.Context(), req.Kind, req.ID); err != nil {
+			sendAPIErrorResponse(w, "Failed to remove blocklist entry", http.StatusInternalServerError)
+			return
+		}
+
+		log.InfoWithFields("Blocklist entry removed", map[string]interface{}{
+			"kind":      req.Kind,
+			"id":        req.ID,
+			"client_ip": iputil.ClientIP(r),
+		})
+
+		sendJSONResponse(w, map[string]string{"status": "unblocked"}, http.StatusOK)
+
+	default:
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UsageStatsHandler handles GET /v1/admin/usage-stats, returning aggregate
+// rate limit usage (active/blocked users, recent requests, per-tier counts)
+// for ops dashboards
+func UsageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := middleware.GetUsageStats(r.Context())
+	if err != nil {
+		logger.GetDailyLogger().Error("Error collecting usage stats: %v", err)
+		sendAPIErrorResponse(w, "Failed to collect usage stats", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSONResponse(w, stats, http.StatusOK)
+}
+
+// EffectiveConfigHandler handles GET /v1/admin/config, returning the resolved
+// configuration the runtime is actually using: rate-limit tiers (with env
+// overrides applied), suspicious-activity and cleanup settings, provider
+// enablement, and timeouts. Read-only and secret-free — values come from the
+// same config and services state the request path reads, so what it shows is
+// what's enforced.
+func EffectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, err := config.LoadSubscriptionConfig()
+	if err != nil {
+		logger.GetDailyLogger().Error("Error loading rate limit configuration: %v", err)
+		sendAPIErrorResponse(w, "Failed to load configuration", http.StatusInternalServerError)
+		return
+	}
+
+	timeouts := services.GetTimeoutSettings()
+	timeouts["first_chunk_ms"] = firstChunkTimeout().Milliseconds()
+
+	sendJSONResponse(w, map[string]interface{}{
+		"rate_limits": map[string]interface{}{
+			string(config.TierAnonymous): cfg.Anonymous,
+			string(config.TierFree):      cfg.Free,
+			string(config.TierPlus):      cfg.Plus,
+			string(config.TierPro):       cfg.Pro,
+		},
+		"suspicious_activity": cfg.SuspiciousActivity,
+		"cleanup":             cfg.Cleanup,
+		"providers": map[string]interface{}{
+			"disabled":          services.GetDisabledProviders(),
+			"adaptive_fallback": services.AdaptiveFallbackEnabled(),
+			"mock_override":     services.MockProviderEnabled(),
+		},
+		"timeouts": timeouts,
+	}, http.StatusOK)
+}
+
+// ReloadRateLimitConfigHandler handles POST /v1/admin/config/reload for
+// re-reading the tier configuration file without a restart. The new config is
+// validated before being swapped in; on error the old config stays live.
+func ReloadRateLimitConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	oldConfig, newConfig, err := config.ReloadSubscriptionConfig()
+	if err != nil {
+		sendAPIErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Re-read system prompt overrides too; an invalid file leaves the
+	// previous prompts live
+	promptsLoaded, err := models.Config.LoadSystemPromptsFromDir()
+	if err != nil {
+		sendAPIErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changes := map[string]interface{}{}
+	if oldConfig != nil {
+		tierPairs := map[string][2]config.RateLimitConfig{
+			string(config.TierAnonymous): {oldConfig.Anonymous, newConfig.Anonymous},
+			string(config.TierFree):      {oldConfig.Free, newConfig.Free},
+			string(config.TierPlus):      {oldConfig.Plus, newConfig.Plus},
+			string(config.TierPro):       {oldConfig.Pro, newConfig.Pro},
+		}
+		for tier, pair := range tierPairs {
+			if pair[0] != pair[1] {
+				changes[tier] = map[string]interface{}{"old": pair[0], "new": pair[1]}
+			}
+		}
+		if oldConfig.SuspiciousActivity != newConfig.SuspiciousActivity {
+			changes["suspicious_activity"] = map[string]interface{}{"old": oldConfig.SuspiciousActivity, "new": newConfig.SuspiciousActivity}
+		}
+		if oldConfig.Cleanup != newConfig.Cleanup {
+			changes["cleanup"] = map[string]interface{}{"old": oldConfig.Cleanup, "new": newConfig.Cleanup}
+		}
+	}
+
+	logger.GetLogger("config_admin").InfoWithFields("Rate limit configuration reloaded", map[string]interface{}{
+		"changes":   changes,
+		"client_ip": iputil.ClientIP(r),
+	})
+
+	sendJSONResponse(w, map[string]interface{}{
+		"status":                "reloaded",
+		"changes":               changes,
+		"system_prompts_loaded": promptsLoaded,
+	}, http.StatusOK)
+}
+
+// DisabledProvidersHandler handles GET/POST /v1/admin/providers/disabled for
+// inspecting and hot-reloading the disabled provider set
+func DisabledProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSONResponse(w, map[string]interface{}{"disabled_providers": services.GetDisabledProviders()}, http.StatusOK)
+
+	case http.MethodPost:
+		var req struct {
+			Providers []string `json:"providers"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		services.SetDisabledProviders(req.Providers)
+
+		logger.GetLogger("provider_admin").InfoWithFields("Disabled provider set updated", map[string]interface{}{
+			"disabled_providers": services.GetDisabledProviders(),
+			"client_ip":          iputil.ClientIP(r),
+		})
+
+		sendJSONResponse(w, map[string]interface{}{"disabled_providers": services.GetDisabledProviders()}, http.StatusOK)
+
+	default:
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ClassifierCircuitHandler handles GET/POST /v1/admin/circuit/classifier for
+// inspecting and manually forcing the classifier circuit breaker state
+func ClassifierCircuitHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSONResponse(w, services.GetCircuitBreakerStats(), http.StatusOK)
+
+	case http.MethodPost:
+		var req struct {
+			State string `json:"state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if err := services.SetCircuitBreakerState(req.State); err != nil {
+			sendAPIErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Record every manual transition and who triggered it
+		logger.GetLogger("circuit_admin").InfoWithFields("Classifier circuit breaker manually transitioned", map[string]interface{}{
+			"state":      req.State,
+			"client_ip":  iputil.ClientIP(r),
+			"user_agent": r.UserAgent(),
+		})
+
+		sendJSONResponse(w, services.GetCircuitBreakerStats(), http.StatusOK)
+
+	default:
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
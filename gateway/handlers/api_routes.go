@@ -22,6 +22,15 @@ func SetupAPIRoutes(mux *http.ServeMux) {
 
 	// Setup subscription routes
 	SetupSubscriptionRoutesWithAuth(mux, APIVersion)
+
+	// Setup account lifecycle routes (anonymous sign-up migration)
+	SetupAccountRoutes(mux)
+
+	// Setup admin routes for operations
+	SetupAdminRoutes(mux)
+
+	// Setup service-to-service routes (classifier webhooks)
+	SetupInternalRoutes(mux)
 }
 
 // SetupSubscriptionRoutesWithAuth sets up subscription routes with Firebase authentication
@@ -86,7 +95,9 @@ func SetupProfileRoutesWithAuth(mux *http.ServeMux, apiVersion string) {
 	// Username availability check endpoint (no user authorization needed)
 	mux.HandleFunc("/v1/profiles/username-availability-check", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(handleUsernameCheckCombined)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("username_check", http.HandlerFunc(handleUsernameCheckCombined)),
+			),
 		).ServeHTTP(w, r)
 	})
 
@@ -102,7 +113,9 @@ func SetupProfileRoutesWithAuth(mux *http.ServeMux, apiVersion string) {
 	// Combined profile handler for both collection and individual operations
 	mux.HandleFunc("/v1/profiles/", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(handleProfileCombined)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("profile_write", http.HandlerFunc(handleProfileCombined)),
+			),
 		).ServeHTTP(w, r)
 	})
 }
@@ -120,14 +133,23 @@ func SetupChatRoutesWithAuth(mux *http.ServeMux, apiVersion string) {
 
 	mux.HandleFunc("/v1/chats/batch-operations", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(BatchChatsHandler)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("batch_chats", http.HandlerFunc(BatchChatsHandler)),
+			),
 		).ServeHTTP(w, r)
 	})
 
-	// Combined chat handler - authorization is handled in the handler itself
+	// Combined chat handler; GET /v1/chats/{chatId} returns the chat item
+	// alone for share/deep links. Ownership is enforced by the middleware
+	// (404 for both not-found and not-owned) with the handler's own checks
+	// as a second layer; collection operations pass through untouched.
 	mux.HandleFunc("/v1/chats/", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(handleChatCombined)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("chat_write",
+					middleware.RequireChatOwnership(http.HandlerFunc(handleChatCombined)),
+				),
+			),
 		).ServeHTTP(w, r)
 	})
 }
@@ -145,7 +167,9 @@ func SetupMessageRoutesWithAuth(mux *http.ServeMux, apiVersion string) {
 
 	mux.HandleFunc("/v1/messages/batch-operations", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(BatchMessagesHandler)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("batch_messages", http.HandlerFunc(BatchMessagesHandler)),
+			),
 		).ServeHTTP(w, r)
 	})
 
@@ -157,14 +181,18 @@ func SetupMessageRoutesWithAuth(mux *http.ServeMux, apiVersion string) {
 
 	mux.HandleFunc("/v1/messages/delete-from-sequence", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(DeleteFromSequenceHandler)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("delete_messages", http.HandlerFunc(DeleteFromSequenceHandler)),
+			),
 		).ServeHTTP(w, r)
 	})
 
 	// Combined message handler - authorization handled in handler itself
 	mux.HandleFunc("/v1/messages/", func(w http.ResponseWriter, r *http.Request) {
 		middleware.CORSMiddleware(
-			middleware.FirebaseAuthMiddleware(http.HandlerFunc(handleMessageCombined)),
+			middleware.FirebaseAuthMiddleware(
+				middleware.EndpointRateLimitMiddleware("create_message", http.HandlerFunc(handleMessageCombined)),
+			),
 		).ServeHTTP(w, r)
 	})
 }
@@ -189,3 +217,47 @@ func sendJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 func sendAPIErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	sendJSONResponse(w, map[string]string{"error": message}, statusCode)
 }
+
+// paginationEnvelopeAccept is the media type a client sends in Accept to opt
+// into the paginated list envelope instead of the legacy bare array
+const paginationEnvelopeAccept = "application/vnd.mayura.v2+json"
+
+// PaginationInfo is the metadata block of the standard list envelope.
+// NextCursor is the opaque cursor for the next page (empty on the last page);
+// HasMore reports whether another page exists; Count is the number of items
+// in this page.
+type PaginationInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Count      int    `json:"count"`
+}
+
+// ListEnvelope is the standard shape for list responses:
+//
+//	{ "data": [...], "pagination": { "next_cursor": "...", "has_more": false, "count": 2 } }
+//
+// Endpoints that don't paginate yet still use it so pagination metadata can
+// be added later without breaking clients.
+type ListEnvelope struct {
+	Data       interface{}    `json:"data"`
+	Pagination PaginationInfo `json:"pagination"`
+}
+
+// listEnvelopeRequested reports whether the caller opted into the list
+// envelope via the versioned Accept media type
+func listEnvelopeRequested(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), paginationEnvelopeAccept)
+}
+
+// sendListResponse writes a list response: the legacy bare array by default,
+// or the standard envelope when the caller opted in via the Accept header
+func sendListResponse(w http.ResponseWriter, r *http.Request, items interface{}, count int, statusCode int) {
+	if !listEnvelopeRequested(r) {
+		sendJSONResponse(w, items, statusCode)
+		return
+	}
+	sendJSONResponse(w, ListEnvelope{
+		Data:       items,
+		Pagination: PaginationInfo{Count: count},
+	}, statusCode)
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"gateway/aws"
+	"gateway/config"
 	"gateway/middleware"
 	"gateway/pkg/logger"
 )
@@ -17,6 +19,12 @@ func handleChatCombined(w http.ResponseWriter, r *http.Request) {
 	// Extract potential chat ID from path
 	chatID := extractPathParam(r.URL.Path, fmt.Sprintf("/%s/chats/", APIVersion))
 
+	// Fork requests carry a trailing action segment: /v1/chats/{chatId}/fork
+	if strings.HasSuffix(chatID, "/fork") {
+		ForkChatHandler(w, r, strings.TrimSuffix(chatID, "/fork"))
+		return
+	}
+
 	// If no chat ID, this is a collection operation
 	if chatID == "" {
 		// Handle collection operations (POST to create)
@@ -64,7 +72,7 @@ func ChatsByUserIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendJSONResponse(w, chats, http.StatusOK)
+	sendListResponse(w, r, chats, len(chats), http.StatusOK)
 }
 
 // BatchChatsHandler handles POST /v1/chats/batch
@@ -227,6 +235,31 @@ func ChatOperationsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// chatLimitMessage tells users what to do when they hit their chat cap
+const chatLimitMessage = "You've reached your chat limit. Please delete old chats to start a new one."
+
+// chatLimitReached reports whether creating one more chat would push the
+// user past their tier's chat cap. Fail-open: count errors allow creation.
+func chatLimitReached(ctx context.Context, r *http.Request, userID string) bool {
+	tier, _ := middleware.GetUserTierFromContext(ctx, r)
+	tierConfig, err := config.GetRateLimitConfig(tier)
+	if err != nil {
+		return false
+	}
+	maxChats := tierConfig.EffectiveMaxChats()
+	if maxChats <= 0 {
+		return false
+	}
+
+	client := aws.GetDynamoDBClient(ctx)
+	count, err := aws.CountChatsByUserID(ctx, client, userID)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Failed to count chats for user %s: %v", userID, err)
+		return false
+	}
+	return count >= maxChats
+}
+
 // CreateChatHandler handles POST /v1/chats
 func CreateChatHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -241,6 +274,11 @@ func CreateChatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if chatLimitReached(r.Context(), r, user.UID) {
+		sendAPIErrorResponse(w, chatLimitMessage, http.StatusTooManyRequests)
+		return
+	}
+
 	var chat aws.Chat
 	if err := json.NewDecoder(r.Body).Decode(&chat); err != nil {
 		sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
@@ -265,6 +303,133 @@ func CreateChatHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONResponse(w, createdChat, http.StatusCreated)
 }
 
+// ForkChatHandler handles POST /v1/chats/{chatId}/fork. It duplicates the
+// chat's messages up to (but not including) the given sequence number into a
+// new chat, appends the edited user message at that sequence, and returns the
+// new chat ID ready for a fresh completion. This gives users non-destructive
+// branching: the original chat keeps its old messages, unlike
+// DeleteMessagesIncludingAndAfter.
+func ForkChatHandler(w http.ResponseWriter, r *http.Request, chatID string) {
+	if r.Method != http.MethodPost {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := middleware.GetFirebaseUserFromContext(r.Context())
+	if !ok || user == nil {
+		sendAPIErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if chatID == "" {
+		sendAPIErrorResponse(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		SequenceNumber int    `json:"sequence_number"`
+		Content        string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.SequenceNumber < 0 {
+		sendAPIErrorResponse(w, "sequence_number cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Content == "" {
+		sendAPIErrorResponse(w, "content is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	client := aws.GetDynamoDBClient(ctx)
+
+	// Verify the chat exists and belongs to the user (404 for both, so chat
+	// IDs can't be probed)
+	sourceChat, err := aws.GetChat(ctx, client, chatID)
+	if err != nil || sourceChat.UserID != user.UID {
+		sendAPIErrorResponse(w, "Chat not found", http.StatusNotFound)
+		return
+	}
+
+	// A fork creates a chat, so the per-user chat cap applies here too
+	if chatLimitReached(r.Context(), r, user.UID) {
+		sendAPIErrorResponse(w, chatLimitMessage, http.StatusTooManyRequests)
+		return
+	}
+
+	// Copy messages strictly before the edited sequence
+	var sourceMessages []aws.Message
+	if req.SequenceNumber > 0 {
+		sourceMessages, err = aws.GetMessagesByChatIDAndSequenceRange(ctx, client, chatID, 0, req.SequenceNumber-1)
+		if err != nil {
+			logger.GetDailyLogger().Error("Error getting messages for fork of chat %s: %v", chatID, err)
+			sendAPIErrorResponse(w, "Failed to get source messages", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	forkedChat, err := aws.CreateChat(ctx, client, aws.Chat{
+		UserID:  user.UID,
+		Name:    sourceChat.Name + " (fork)",
+		Sharing: "private",
+	})
+	if err != nil {
+		logger.GetDailyLogger().Error("Error creating fork of chat %s: %v", chatID, err)
+		sendAPIErrorResponse(w, "Failed to create forked chat", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	for _, msg := range sourceMessages {
+		duplicateMsg := aws.Message{
+			ChatID:         forkedChat.ID,
+			UserID:         user.UID,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			Content:        msg.Content,
+			ModelName:      msg.ModelName,
+			Role:           msg.Role,
+			SequenceNumber: msg.SequenceNumber,
+		}
+		if _, err := aws.CreateMessage(ctx, client, duplicateMsg); err != nil {
+			logger.GetDailyLogger().Error("Error copying message to forked chat %s: %v", forkedChat.ID, err)
+			sendAPIErrorResponse(w, "Failed to copy messages into forked chat", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Apply the edit as the user message the fork continues from
+	editedMsg := aws.Message{
+		ChatID:         forkedChat.ID,
+		UserID:         user.UID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Content:        req.Content,
+		Role:           "user",
+		SequenceNumber: req.SequenceNumber,
+	}
+	if _, err := aws.CreateMessage(ctx, client, editedMsg); err != nil {
+		logger.GetDailyLogger().Error("Error writing edited message to forked chat %s: %v", forkedChat.ID, err)
+		sendAPIErrorResponse(w, "Failed to write edited message", http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the fork's denormalized last-message metadata current; a fork with
+	// a stale zero message count would look like an empty chat and get reused
+	if err := aws.RecordChatMessageActivity(ctx, client, forkedChat.ID, req.Content, now, len(sourceMessages)+1); err != nil {
+		logger.GetDailyLogger().Warn("Error updating metadata for forked chat %s: %v", forkedChat.ID, err)
+	}
+
+	sendJSONResponse(w, map[string]interface{}{
+		"chat_id":         forkedChat.ID,
+		"source_chat_id":  chatID,
+		"sequence_number": req.SequenceNumber,
+	}, http.StatusCreated)
+}
+
 // handleCurrentUserChats handles GET /v1/chats/current
 func handleCurrentUserChats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -289,5 +454,5 @@ func handleCurrentUserChats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendJSONResponse(w, chats, http.StatusOK)
+	sendListResponse(w, r, chats, len(chats), http.StatusOK)
 }
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gateway/aws"
+)
+
+func TestCleanupOrphanedChatBestEffort(t *testing.T) {
+	// Point the client at a closed port: both deletes fail fast, and the
+	// cleanup must swallow the failures instead of panicking or returning
+	t.Setenv("DYNAMODB_ENDPOINT", "http://127.0.0.1:1")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := aws.GetDynamoDBClient(ctx)
+	cleanupOrphanedChat(ctx, client, "chat-orphan", "msg-orphan", 1)
+}
@@ -1,24 +1,42 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"mime"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 
 	"gateway/aws"
 	"gateway/config"
 	"gateway/middleware"
 	"gateway/models"
+	"gateway/pkg/iputil"
 	"gateway/pkg/logger"
 	"gateway/services"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
+// noopFlusher lets the streaming pipeline run against a ResponseWriter that
+// isn't an http.Flusher: frames accumulate in the net/http buffer and are
+// delivered as a single body when the handler returns
+type noopFlusher struct{}
+
+func (noopFlusher) Flush() {}
+
 // Helper function to get max of two integers
 func max(a, b int) int {
 	if a > b {
@@ -47,7 +65,8 @@ type RequestBody struct {
 type RateLimitStatus struct {
 	DailyLimit        int                    `json:"daily_limit"`
 	RequestsUsed      int                    `json:"requests_used"`
-	RequestsRemaining int                    `json:"requests_remaining"`
+	RequestsRemaining *int                   `json:"requests_remaining"` // null when unlimited
+	Unlimited         bool                   `json:"unlimited"`
 	CurrentMode       middleware.RequestType `json:"current_mode"` // "pro" or "free"
 	ResetTime         time.Time              `json:"reset_time"`
 	ResetTimeUnix     int64                  `json:"reset_time_unix"`
@@ -55,6 +74,10 @@ type RateLimitStatus struct {
 	UserEmail         string                 `json:"user_email,omitempty"`
 	Message           string                 `json:"message"`
 
+	// Daily spend tracking; the cap is omitted when unlimited
+	DailySpendUSD   float64 `json:"daily_spend_usd"`
+	DailyCostCapUSD float64 `json:"daily_cost_cap_usd,omitempty"`
+
 	// Suspicious activity tracking
 	IsBlocked        bool      `json:"is_blocked"`
 	BlockedUntil     time.Time `json:"blocked_until,omitempty"`
@@ -79,7 +102,7 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	clientID := rand.Intn(1000000)
 
-	logger.GetDailyLogger().Info("Client %d: New request started from %s", clientID, r.RemoteAddr)
+	logger.GetDailyLogger().Info("Client %d: New request started from %s", clientID, iputil.ClientIP(r))
 
 	// Create request context with ID
 	ctx := r.Context()
@@ -87,19 +110,30 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated user from context
 	user, userOk := middleware.GetFirebaseUserFromContext(ctx)
 	if !userOk || user == nil {
-		sendErrorResponse(w, nil, "Authentication required", clientID)
+		sendErrorResponseWithCode(w, nil, "Authentication required", errCodeAuth, clientID)
 		atomic.AddInt64(&totalErrors, 1)
 		return
 	}
 
 	logger.GetDailyLogger().Info("Processing request for user: %s (%s)", user.Email, user.UID)
 
+	// Record the token's expiry so streams can be cut when it passes
+	// (only enforced when ENFORCE_TOKEN_EXPIRY_DURING_STREAM is set)
+	if token, tokenOk := middleware.GetDecodedTokenFromContext(ctx); tokenOk && token != nil && token.Expires > 0 {
+		ctx = services.WithTokenExpiry(ctx, time.Unix(token.Expires, 0))
+	}
+
 	// Get request type from context (set by rate limiter)
 	requestType, hasRequestType := middleware.GetRequestTypeFromContext(ctx)
 	if hasRequestType {
 		logger.GetDailyLogger().Info("Request type: %s", string(requestType))
 	}
 
+	// Carry the downgrade signal so providers can flag it in the start frame
+	if middleware.GetModeDowngradedFromContext(ctx) {
+		ctx = services.WithModeDowngraded(ctx, true)
+	}
+
 	// Increment metrics
 	atomic.AddInt64(&totalRequests, 1)
 	atomic.AddInt64(&activeConnections, 1)
@@ -121,10 +155,20 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate flusher capability
+	// Validate flusher capability. Some middlewares/proxies wrap the writer
+	// and hide the Flusher even though the response would still get through;
+	// degrade to a buffered, non-streamed response instead of failing.
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		logger.GetDailyLogger().Warn("Client %d: ResponseWriter is not a Flusher, sending buffered response (check proxy/middleware configuration)", clientID)
+		flusher = noopFlusher{}
+	}
+
+	// Reject bodies declared with a non-JSON content type up front so clients
+	// get an actionable error instead of a confusing empty-prompt one
+	if !isAllowedContentType(r) {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		sendErrorResponseWithCode(w, flusher, fmt.Sprintf("Unsupported Content-Type %q: expected application/json", r.Header.Get("Content-Type")), errCodeBadRequest, clientID)
 		atomic.AddInt64(&totalErrors, 1)
 		return
 	}
@@ -132,18 +176,27 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 	// Read and validate request body with size limit
 	var reqBody models.RequestBody
 	if r.Body != nil {
-		// Limit request body size to 1MB
-		limitedReader := io.LimitReader(r.Body, 1024*1024)
+		// Read one byte past the limit so an oversized body is detectable
+		// rather than silently truncated into a JSON parse error
+		maxBytes := maxRequestBodyBytes()
+		limitedReader := io.LimitReader(r.Body, maxBytes+1)
 		body, err := io.ReadAll(limitedReader)
 		if err != nil {
-			sendErrorResponse(w, flusher, "Error reading request body", clientID)
+			sendErrorResponseWithCode(w, flusher, "Error reading request body", errCodeBadRequest, clientID)
+			atomic.AddInt64(&totalErrors, 1)
+			return
+		}
+
+		if int64(len(body)) > maxBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			sendErrorResponseWithCode(w, flusher, fmt.Sprintf("Request body exceeds the %d byte limit", maxBytes), errCodeBadRequest, clientID)
 			atomic.AddInt64(&totalErrors, 1)
 			return
 		}
 
 		if len(body) > 0 {
 			if err := json.Unmarshal(body, &reqBody); err != nil {
-				sendErrorResponse(w, flusher, "Invalid request body", clientID)
+				sendErrorResponseWithCode(w, flusher, "Invalid request body", errCodeBadRequest, clientID)
 				atomic.AddInt64(&totalErrors, 1)
 				return
 			}
@@ -155,13 +208,117 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Validate prompt content
 	if strings.TrimSpace(prompt) == "" {
-		sendErrorResponse(w, flusher, "Prompt cannot be empty", clientID)
+		sendErrorResponseWithCode(w, flusher, "Prompt cannot be empty", errCodeBadRequest, clientID)
 		atomic.AddInt64(&totalErrors, 1)
 		return
 	}
 
 	logger.GetDailyLogger().Info("Client %d: Processing prompt request (%d chars)", clientID, len(prompt))
 
+	// Bound client-supplied history before it reaches the providers
+	reqBody.PreviousMessages = boundPreviousMessages(reqBody.PreviousMessages, clientID)
+
+	// Carry prompt template variables so providers can personalize the system prompt
+	promptVars := &models.PromptTemplateVars{
+		UserDisplayName: user.DisplayName,
+		CurrentDate:     time.Now().Format("Monday, January 2, 2006"),
+	}
+	// Only hit the subscription service when a prompt actually uses {{tier}}
+	if models.Config.AnyPromptContains("{{tier}}") {
+		if tier, _ := middleware.GetUserTierFromContext(ctx, r); tier != "" {
+			promptVars.Tier = string(tier)
+		}
+	}
+	ctx = services.WithPromptVars(ctx, promptVars)
+
+	// Validate the requested response format before carrying it to providers
+	if reqBody.ResponseFormat != nil && reqBody.ResponseFormat.Type != "json_object" {
+		sendErrorResponseWithCode(w, flusher, fmt.Sprintf("Unsupported response_format type %q: only \"json_object\" is supported", reqBody.ResponseFormat.Type), errCodeBadRequest, clientID)
+		atomic.AddInt64(&totalErrors, 1)
+		return
+	}
+
+	// Claim a global provider stream slot before doing any per-request work;
+	// beyond the cap the request is shed with a retryable busy error rather
+	// than degrading every in-flight stream
+	if !services.AcquireProviderStream() {
+		logger.GetDailyLogger().Warn("Client %d: global provider stream cap reached, shedding request", clientID)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		sendErrorResponseWithCode(w, flusher, "Server is busy, please retry in a moment.", errCodeRateLimited, clientID)
+		atomic.AddInt64(&totalErrors, 1)
+		return
+	}
+	defer services.ReleaseProviderStream()
+
+	// Reject a concurrent identical completion (same user, chat and prompt)
+	// while the first is still running, so a double-clicked send doesn't
+	// create duplicate messages and pay for two provider streams
+	acquired, releaseInflight := tryAcquireInflightRequest(ctx, user.UID, reqBody.ChatID, prompt)
+	if !acquired {
+		logger.GetDailyLogger().Warn("Client %d: duplicate in-flight request for user %s", clientID, user.UID)
+		sendErrorResponseWithCode(w, flusher, "An identical request is already in progress.", errCodeDuplicate, clientID)
+		atomic.AddInt64(&totalErrors, 1)
+		return
+	}
+	defer releaseInflight()
+
+	// Validate the resume request ID before using it as a Redis key segment
+	if reqBody.RequestID != "" && !resumeRequestIDPattern.MatchString(reqBody.RequestID) {
+		sendErrorResponseWithCode(w, flusher, "Invalid request_id: up to 64 letters, digits, hyphens and underscores", errCodeBadRequest, clientID)
+		atomic.AddInt64(&totalErrors, 1)
+		return
+	}
+
+	// Carry the seed and response format to the provider calls
+	if reqBody.Seed != nil || reqBody.ResponseFormat != nil {
+		ctx = services.WithGenerationOptions(ctx, &services.GenerationOptions{
+			Seed:           reqBody.Seed,
+			ResponseFormat: reqBody.ResponseFormat,
+		})
+		if reqBody.Seed != nil {
+			logger.GetDailyLogger().Info("Client %d: Request uses seed %d", clientID, *reqBody.Seed)
+		}
+		if reqBody.ResponseFormat != nil {
+			logger.GetDailyLogger().Info("Client %d: Request uses response format %s", clientID, reqBody.ResponseFormat.Type)
+		}
+	}
+
+	// Suppress reasoning output when the caller asked for it or the user's
+	// tier is listed in THINKING_DISABLED_TIERS
+	if reqBody.DisableThinking {
+		ctx = services.WithThinkingDisabled(ctx)
+		logger.GetDailyLogger().Info("Client %d: Request disables thinking output", clientID)
+	} else if tier, tierOk := middleware.GetTierFromRequestContext(ctx); tierOk && services.ThinkingDisabledForTier(string(tier)) {
+		ctx = services.WithThinkingDisabled(ctx)
+		logger.GetDailyLogger().Info("Client %d: Thinking output disabled for tier %s", clientID, tier)
+	}
+
+	// Resolve the history window from the tier: a request may narrow it, but
+	// never widen it past the tier's maximum
+	historyWindow := config.DefaultMaxContextMessages
+	if tier, tierOk := middleware.GetTierFromRequestContext(ctx); tierOk {
+		if tierConfig, cfgErr := config.GetRateLimitConfig(tier); cfgErr == nil {
+			historyWindow = tierConfig.EffectiveMaxContextMessages()
+		}
+	}
+	if reqBody.ContextWindow > 0 && reqBody.ContextWindow < historyWindow {
+		historyWindow = reqBody.ContextWindow
+	}
+	ctx = services.WithHistoryWindow(ctx, historyWindow)
+
+	// Carry tool declarations to the provider calls when supplied
+	if len(reqBody.Tools) > 0 {
+		ctx = services.WithToolConfig(ctx, &services.ToolConfig{
+			Tools:      reqBody.Tools,
+			ToolChoice: reqBody.ToolChoice,
+		})
+		logger.GetDailyLogger().Info("Client %d: Request includes %d tool declarations", clientID, len(reqBody.Tools))
+	}
+
+	// Tracks whether this request minted the chat, so failure paths below can
+	// clean it up; reused or pre-existing chats are never deleted
+	chatCreatedThisRequest := false
+
 	// STEP 1: Determine chat_id - create new chat if needed
 	chatID := reqBody.ChatID
 
@@ -176,33 +333,72 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// When enabled, ignore client-sent history for existing chats and load the
+	// real messages server-side so clients can't fabricate or spoof history
+	if chatID != "" && serverSideHistoryEnabled() {
+		history, err := loadServerSideHistory(ctx, chatID, user.UID)
+		if err != nil {
+			logger.GetDailyLogger().Error("Error loading server-side history for client %d: %v", clientID, err)
+			sendErrorResponse(w, flusher, "Failed to load chat history", clientID)
+			atomic.AddInt64(&totalErrors, 1)
+			return
+		}
+		logger.GetDailyLogger().Info("Client %d: Loaded %d messages server-side for chat %s", clientID, len(history), chatID)
+		reqBody.PreviousMessages = history
+	}
+
 	// If we still don't have a chat_id, create a new chat
 	if chatID == "" {
+		// Refuse to spawn another chat past the tier's cap; a buggy client
+		// retrying without a chat_id would otherwise create chats unbounded
+		if chatLimitReached(ctx, r, user.UID) {
+			logger.GetDailyLogger().Warn("Client %d: chat limit reached for user %s", clientID, user.UID)
+			sendErrorResponseWithCode(w, flusher, chatLimitMessage, errCodeRateLimited, clientID)
+			atomic.AddInt64(&totalErrors, 1)
+			return
+		}
+
 		dbCtx := context.Background()
 		dbClient := aws.GetDynamoDBClient(dbCtx)
 
-		// Generate a simple chat name from the prompt (first 50 chars)
-		chatName := strings.TrimSpace(prompt)
-		if len(chatName) > 50 {
-			chatName = chatName[:50] + "..."
-		}
+		chatName := newChatName(prompt)
 
-		newChat := aws.Chat{
-			UserID:  user.UID,
-			Name:    chatName,
-			Sharing: "private",
-		}
+		// Reuse the most recent empty chat instead of minting another one:
+		// repeated failed first completions otherwise leave a trail of orphans
+		if emptyChat := findRecentEmptyChat(dbCtx, dbClient, user.UID); emptyChat != nil {
+			chatID = emptyChat.ID
+			emptyChat.Name = chatName
+			if _, err := aws.UpdateChat(dbCtx, dbClient, *emptyChat); err != nil {
+				logger.GetDailyLogger().Warn("Client %d: Failed to rename reused chat %s: %v", clientID, chatID, err)
+			}
+			logger.GetDailyLogger().Info("Client %d: Reusing empty chat %s", clientID, chatID)
+		} else {
+			// Short-window creation limiter, separate from completion limits
+			if !middleware.CheckChatCreationLimit(ctx, user.UID) {
+				logger.GetDailyLogger().Warn("Client %d: chat creation limit reached for user %s", clientID, user.UID)
+				sendErrorResponseWithCode(w, flusher, "You're creating new chats too quickly. Please wait a moment and try again.", errCodeRateLimited, clientID)
+				atomic.AddInt64(&totalErrors, 1)
+				return
+			}
 
-		createdChat, err := aws.CreateChat(dbCtx, dbClient, newChat)
-		if err != nil {
-			logger.GetDailyLogger().Error("Error creating chat for client %d: %v", clientID, err)
-			sendErrorResponse(w, flusher, "Failed to create chat", clientID)
-			atomic.AddInt64(&totalErrors, 1)
-			return
-		}
+			newChat := aws.Chat{
+				UserID:  user.UID,
+				Name:    chatName,
+				Sharing: "private",
+			}
 
-		chatID = createdChat.ID
-		logger.GetDailyLogger().Info("Client %d: Created new chat %s", clientID, chatID)
+			createdChat, err := aws.CreateChat(dbCtx, dbClient, newChat)
+			if err != nil {
+				logger.GetDailyLogger().Error("Error creating chat for client %d: %v", clientID, err)
+				sendErrorResponse(w, flusher, "Failed to create chat", clientID)
+				atomic.AddInt64(&totalErrors, 1)
+				return
+			}
+
+			chatID = createdChat.ID
+			chatCreatedThisRequest = true
+			logger.GetDailyLogger().Info("Client %d: Created new chat %s", clientID, chatID)
+		}
 	} else {
 		logger.GetDailyLogger().Info("Client %d: Using existing chat %s", clientID, chatID)
 	}
@@ -229,10 +425,27 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 	dbCtx := context.Background()
 	dbClient := aws.GetDynamoDBClient(dbCtx)
 
+	// Reject sequence numbers wildly ahead of the server-known max: nextSeq
+	// comes from client-supplied history, and a buggy client could otherwise
+	// punch a huge gap into the chat's ordering. Only chats already past the
+	// allowed gap are worth the extra query.
+	if gap := maxSequenceGap(); gap > 0 && nextSeq > gap {
+		serverNext, err := aws.GetNextSequenceNumber(dbCtx, dbClient, chatID)
+		if err != nil {
+			logger.GetDailyLogger().Warn("Client %d: Failed to verify sequence number for chat %s: %v", clientID, chatID, err)
+		} else if sequenceGapExceeded(nextSeq, serverNext, gap) {
+			logger.GetDailyLogger().Warn("Client %d: Rejecting sequence number %d, server max for chat %s implies %d (gap limit %d)", clientID, nextSeq, chatID, serverNext, gap)
+			sendErrorResponseWithCode(w, flusher, "Message history is out of sync with the server. Please reload the chat.", errCodeBadRequest, clientID)
+			atomic.AddInt64(&totalErrors, 1)
+			return
+		}
+	}
+
+	// Scrub PII from the stored copy only; the in-session prompt is untouched
 	userMessage := aws.Message{
 		ChatID:         chatID,
 		UserID:         user.UID,
-		Content:        prompt,
+		Content:        scrubPII(prompt),
 		ModelName:      "user",
 		Role:           "user",
 		SequenceNumber: nextSeq,
@@ -248,6 +461,11 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 
 	logger.GetDailyLogger().Info("Client %d: Saved user message %s", clientID, savedUserMessage.ID)
 
+	// Keep the chat's denormalized last-message metadata current
+	if err := aws.RecordChatMessageActivity(dbCtx, dbClient, chatID, savedUserMessage.Content, savedUserMessage.CreatedAt, 1); err != nil {
+		logger.GetDailyLogger().Error("Error updating chat metadata for client %d: %v", clientID, err)
+	}
+
 	// STEP 4: Get model classification (can be parallel with other setup)
 	// Create context with timeout for the entire request
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
@@ -267,18 +485,31 @@ func ClientHandler(w http.ResponseWriter, r *http.Request) {
 	modelResponse, err := callModelServiceWithTimeout(ctx, prompt, requestType)
 	if err != nil {
 		logger.GetDailyLogger().Error("Model service error for client %d: %v", clientID, err)
-		sendErrorResponse(w, flusher, fmt.Sprintf("Model service error: %v", err), clientID)
+		errCode := errCodeProvider
+		if errors.Is(err, context.DeadlineExceeded) {
+			errCode = errCodeTimeout
+		}
+		sendErrorResponseWithCode(w, flusher, fmt.Sprintf("Model service error: %v", err), errCode, clientID)
+		if chatCreatedThisRequest {
+			cleanupOrphanedChat(dbCtx, dbClient, chatID, savedUserMessage.ID, clientID)
+		}
 		atomic.AddInt64(&totalErrors, 1)
 		return
 	}
 
 	logger.GetDailyLogger().Info("Selected model: %s (%s)", modelResponse.PrimaryModel, modelResponse.PrimaryModelDisplayName)
 
+	// Surface the classifier's category decision to the client before streaming
+	sendCategoryMetaFrame(w, flusher, modelResponse, clientID)
+
 	// STEP 5: Stream response and save assistant message after completion
-	err = streamWithFallbackAndSaveAfterCompletion(ctx, w, flusher, modelResponse, prompt, clientID, reqBody.PreviousMessages, reqBody.ProfileContext, user.UID, chatID, nextSeq+1)
+	err = streamWithFallbackAndSaveAfterCompletion(ctx, w, flusher, modelResponse, prompt, clientID, reqBody.PreviousMessages, reqBody.ProfileContext, user.UID, chatID, nextSeq+1, reqBody.RequestID)
 	if err != nil {
 		logger.GetDailyLogger().Error("Streaming error for client %d: %v", clientID, err)
-		sendErrorResponse(w, flusher, "Models not available currently. Please try again later.", clientID)
+		sendErrorResponseWithCode(w, flusher, "Models not available currently. Please try again later.", errCodeProvider, clientID)
+		if chatCreatedThisRequest {
+			cleanupOrphanedChat(dbCtx, dbClient, chatID, savedUserMessage.ID, clientID)
+		}
 		atomic.AddInt64(&totalErrors, 1)
 		return
 	}
@@ -312,11 +543,62 @@ func callModelServiceWithTimeout(ctx context.Context, prompt string, requestType
 	}
 }
 
-// sendErrorResponse sends an error response in SSE format
+// sendCategoryMetaFrame emits a small "meta" SSE frame with the classifier's
+// predicted category and confidence. Full category probabilities are only
+// included when SSE_DEBUG_METADATA=true to keep the frame small.
+func sendCategoryMetaFrame(w http.ResponseWriter, flusher http.Flusher, modelResponse services.ModelResponse, clientID int) {
+	if modelResponse.Metadata.PredictedCategory == "" {
+		return
+	}
+
+	metaResponse := models.Response{
+		Type:       "meta",
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Category:   modelResponse.Metadata.PredictedCategory,
+		Confidence: modelResponse.Metadata.Confidence,
+	}
+
+	if os.Getenv("SSE_DEBUG_METADATA") == "true" {
+		metaResponse.CategoryProbabilities = modelResponse.Metadata.CategoryProbabilities
+	}
+
+	msg, err := models.FormatSSEMessage(metaResponse)
+	if err != nil {
+		logger.GetDailyLogger().Error("Error formatting meta frame for client %d: %v", clientID, err)
+		return
+	}
+
+	fmt.Fprint(w, msg)
+	flusher.Flush()
+}
+
+// Stable error codes carried in the error_code field of "error" frames.
+// Clients branch on these instead of string-matching the human message.
+const (
+	errCodeAuth        = "auth_error"
+	errCodeBadRequest  = "bad_request"
+	errCodeRateLimited = "rate_limited"
+	errCodeDuplicate   = "duplicate_request"
+	errCodeProvider    = "provider_error"
+	errCodeTimeout     = "timeout"
+	errCodeInternal    = "internal_error"
+)
+
+// sendErrorResponse sends an error response in SSE format with the generic
+// internal_error code; call sites with a more specific failure class use
+// sendErrorResponseWithCode directly
 func sendErrorResponse(w http.ResponseWriter, flusher http.Flusher, errorMsg string, clientID int) {
+	sendErrorResponseWithCode(w, flusher, errorMsg, errCodeInternal, clientID)
+}
+
+// sendErrorResponseWithCode sends an error SSE frame tagged with a stable
+// machine-readable code. The message is sent raw — clients decide how to
+// present it.
+func sendErrorResponseWithCode(w http.ResponseWriter, flusher http.Flusher, errorMsg, errorCode string, clientID int) {
 	errorResponse := models.Response{
-		Message:   fmt.Sprintf("Error: %s", errorMsg),
+		Message:   errorMsg,
 		Type:      "error",
+		ErrorCode: errorCode,
 		Timestamp: time.Now().Format(time.RFC3339),
 		// UserID and Model are omitted for error messages in the new format
 	}
@@ -341,7 +623,24 @@ func GetMetrics() map[string]interface{} {
 		"total_errors":          atomic.LoadInt64(&totalErrors),
 		"error_rate":            float64(atomic.LoadInt64(&totalErrors)) / float64(atomic.LoadInt64(&totalRequests)),
 		"circuit_breaker_stats": services.GetCircuitBreakerStats(),
+		"disabled_providers":    services.GetDisabledProviders(),
+		"provider_health":       services.GetProviderHealthStats(),
+		"thinking_tokens":       services.GetThinkingTokenStats(),
+		"provider_streams":      services.GetProviderStreamStats(),
+		"usage_refunds":         middleware.GetRefundCount(),
+		"auth_cache":            middleware.GetAuthCacheStats(),
+		"tier_cache":            middleware.GetTierCacheStats(),
+	}
+}
+
+// signUpPitch builds the sign-up call-to-action shown to anonymous users from
+// the free tier's configured limits instead of a hard-coded number
+func signUpPitch() string {
+	freeConfig, err := config.GetRateLimitConfig(config.TierFree)
+	if err != nil {
+		return "Sign up to get more free requests per day!"
 	}
+	return fmt.Sprintf("Sign up to get %d free requests per day!", freeConfig.RequestsPerDay)
 }
 
 // RateLimitStatusHandler returns the current rate limit status for the authenticated user
@@ -362,8 +661,8 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 			key = "user:" + user.UID
 		}
 	} else {
-		// Fall back to IP address for unauthenticated users
-		key = "ip:" + r.RemoteAddr
+		// Fall back to the client IP for unauthenticated users
+		key = "ip:" + iputil.ClientIP(r)
 	}
 
 	// Get tier configuration
@@ -397,6 +696,7 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 	// Calculate total requests used and remaining based on tier
 	var totalUsed int
 	var totalRemaining int
+	var unlimited bool
 	var currentMode middleware.RequestType
 	var message string
 
@@ -408,18 +708,19 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		// Determine current mode and calculate remaining requests
 		if isAnonymous {
-			// Anonymous users only have free requests
+			// Anonymous users only have free requests, capped for their lifetime
 			totalUsed = freeCount + proCount
-			totalRemaining = max(0, tierConfig.RequestsPerDay-totalUsed)
+			totalRemaining = max(0, tierConfig.EffectiveLifetimeRequests()-totalUsed)
 			currentMode = middleware.FreeRequest
 
 			if tierConfig.LifetimeLimit {
+				signupPitch := signUpPitch()
 				if totalRemaining == 0 {
-					message = "You've used all your free requests. Sign up to get 100 free requests per day!"
+					message = "You've used all your free requests. " + signupPitch
 				} else if totalRemaining == 1 {
-					message = "You have 1 free request remaining. Sign up to get 100 free requests per day!"
+					message = "You have 1 free request remaining. " + signupPitch
 				} else {
-					message = fmt.Sprintf("You have %d free requests remaining. Sign up to get 100 free requests per day!", totalRemaining)
+					message = fmt.Sprintf("You have %d free requests remaining. %s", totalRemaining, signupPitch)
 				}
 			} else {
 				message = "Anonymous users should have lifetime limits - configuration error"
@@ -443,7 +744,7 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 				if config.IsUnlimited(tierConfig.FreeRequests) {
 					totalUsed = freeCount
-					totalRemaining = 999999 // Large number to indicate unlimited
+					unlimited = true
 					message = "You've used all your max requests for today. Continuing with unlimited free requests."
 				} else {
 					totalUsed = freeCount
@@ -461,17 +762,27 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create response
+	// Anonymous lifetime caps are reported in place of a daily limit
+	reportedLimit := tierConfig.RequestsPerDay
+	if isAnonymous && tierConfig.LifetimeLimit {
+		reportedLimit = tierConfig.EffectiveLifetimeRequests()
+	}
+
+	// Create response; unlimited tiers report null remaining instead of a
+	// magic large number clients would have to special-case
 	status := RateLimitStatus{
-		DailyLimit:        tierConfig.RequestsPerDay,
-		RequestsUsed:      totalUsed,
-		RequestsRemaining: totalRemaining,
-		CurrentMode:       currentMode,
-		ResetTime:         resetTime,
-		ResetTimeUnix:     resetTime.Unix(),
-		Message:           message,
-		IsBlocked:         isBlocked,
-		RecentRequests:    recentRequests,
+		DailyLimit:     reportedLimit,
+		RequestsUsed:   totalUsed,
+		Unlimited:      unlimited,
+		CurrentMode:    currentMode,
+		ResetTime:      resetTime,
+		ResetTimeUnix:  resetTime.Unix(),
+		Message:        message,
+		IsBlocked:      isBlocked,
+		RecentRequests: recentRequests,
+	}
+	if !unlimited {
+		status.RequestsRemaining = &totalRemaining
 	}
 
 	// Add blocking information if blocked
@@ -490,10 +801,20 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if userOk && user != nil {
 		status.UserID = user.UID
 		status.UserEmail = user.Email
+
+		// Current estimated spend against the tier's daily cost cap
+		if spend, err := middleware.GetDailySpend(ctx, user.UID); err == nil {
+			status.DailySpendUSD = spend
+		}
+		status.DailyCostCapUSD = tierConfig.EffectiveMaxDailyCostUSD()
 	}
 
 	// Return JSON response
 	w.Header().Set("Content-Type", "application/json")
+	if isBlocked && blockedUntil.After(time.Now()) {
+		// Mirror the middleware's Retry-After so blocked clients know when to retry
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(blockedUntil).Seconds()+0.5)))
+	}
 	if err := json.NewEncoder(w).Encode(status); err != nil {
 		logger.GetDailyLogger().Error("Error encoding rate limit status: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -501,11 +822,67 @@ func RateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxModelDisplayNameLength bounds display names before they reach the
+// client or the database
+const maxModelDisplayNameLength = 64
+
+// sanitizeModelDisplayName trims, bounds, and strips control characters from
+// a classifier-supplied display name, so a malformed classifier response
+// can't corrupt stream frames or persisted messages
+func sanitizeModelDisplayName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	cleaned = strings.TrimSpace(cleaned)
+
+	if runes := []rune(cleaned); len(runes) > maxModelDisplayNameLength {
+		cleaned = strings.TrimSpace(string(runes[:maxModelDisplayNameLength]))
+	}
+	if cleaned == "" {
+		return "Unknown model"
+	}
+	return cleaned
+}
+
 // streamModelResponse handles streaming with fallback logic for different providers
 func streamModelResponse(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, modelName string, displayName string, providerName string, prompt string, clientID int, previousMessages []models.ChatMessage, profileContext string, isThinkingModel bool) error {
 	var err error
 
+	// Display names come from the classifier and are untrusted
+	displayName = sanitizeModelDisplayName(displayName)
+
+	// Test-only override: route everything to the deterministic mock provider
+	// so the full flow can run without provider API keys
+	if services.MockProviderEnabled() {
+		providerName = "mock"
+	}
+
+	// Treat the model as non-thinking when reasoning was disabled for this
+	// request, regardless of the model's default
+	if isThinkingModel && services.ThinkingDisabled(ctx) {
+		isThinkingModel = false
+	}
+
+	// Skip providers that operators have disabled so fallback moves on immediately
+	if services.IsProviderDisabled(providerName) {
+		logger.GetDailyLogger().Warn("Skipping disabled provider %s for model %s (client %d)", providerName, displayName, clientID)
+		return fmt.Errorf("provider %s is disabled", providerName)
+	}
+
+	// Tool calling is only wired up for the OpenAI-compatible providers and Gemini
+	if services.GetToolConfigFromContext(ctx) != nil {
+		switch providerName {
+		case "gemini", "openrouter", "groq", "mock":
+		default:
+			return fmt.Errorf("provider %s does not support tool calling", providerName)
+		}
+	}
+
 	// Route to appropriate provider based on provider name
+	attemptStart := time.Now()
 	switch providerName {
 	case "gemini":
 		err = services.StreamGeminiResponse(ctx, w, flusher, prompt, modelName, displayName, clientID, previousMessages, profileContext, isThinkingModel)
@@ -513,21 +890,212 @@ func streamModelResponse(ctx context.Context, w http.ResponseWriter, flusher htt
 		err = services.StreamOpenRouterResponse(ctx, w, flusher, prompt, modelName, displayName, clientID, previousMessages, profileContext, isThinkingModel)
 	case "groq":
 		err = services.StreamGroqResponse(ctx, w, flusher, prompt, modelName, displayName, clientID, previousMessages, profileContext, isThinkingModel)
+	case "mock":
+		// Only reachable via the override; the classifier never returns "mock"
+		if !services.MockProviderEnabled() {
+			return fmt.Errorf("unsupported provider: %s", providerName)
+		}
+		err = services.StreamMockResponse(ctx, w, flusher, prompt, modelName, displayName, clientID, previousMessages, profileContext, isThinkingModel)
 	default:
 		return fmt.Errorf("unsupported provider: %s", providerName)
 	}
 
 	if err != nil {
-		// Check if error is due to context cancellation
+		// Check if error is due to context cancellation - a client disconnect
+		// is not the provider's fault, so don't count it against its health
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
+		services.RecordProviderResult(providerName, false, time.Since(attemptStart))
 		return err
 	}
 
+	services.RecordProviderResult(providerName, true, time.Since(attemptStart))
 	return nil
 }
 
+// firstChunkTimeout returns the time-to-first-content deadline for a single
+// model attempt (FIRST_CHUNK_TIMEOUT_MS, 0 disables)
+func firstChunkTimeout() time.Duration {
+	if value := os.Getenv("FIRST_CHUNK_TIMEOUT_MS"); value != "" {
+		if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// firstChunkWatcher wraps the response writer and records the first time a
+// content chunk frame passes through, so the caller can enforce a
+// time-to-first-chunk deadline on each model attempt
+type firstChunkWatcher struct {
+	http.ResponseWriter
+	flusher  http.Flusher
+	once     sync.Once
+	gotChunk chan struct{}
+}
+
+func newFirstChunkWatcher(w http.ResponseWriter, flusher http.Flusher) *firstChunkWatcher {
+	return &firstChunkWatcher{ResponseWriter: w, flusher: flusher, gotChunk: make(chan struct{})}
+}
+
+func (fw *firstChunkWatcher) Write(p []byte) (int, error) {
+	// All frames are FormatSSEMessage JSON, so matching the type field is
+	// reliable; start/meta frames deliberately don't count as content
+	if bytes.Contains(p, []byte(`"type":"chunk"`)) {
+		fw.once.Do(func() { close(fw.gotChunk) })
+	}
+	return fw.ResponseWriter.Write(p)
+}
+
+func (fw *firstChunkWatcher) Flush() {
+	fw.flusher.Flush()
+}
+
+// streamModelResponseWithFirstChunkDeadline runs streamModelResponse and
+// cancels the attempt if the model hasn't produced its first content chunk
+// within FIRST_CHUNK_TIMEOUT_MS. A slow start usually indicates a stuck
+// provider, so failing fast moves fallback along instead of burning the
+// overall request timeout; once content is flowing, the attempt may take as
+// long as it needs.
+func streamModelResponseWithFirstChunkDeadline(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, modelName string, displayName string, providerName string, prompt string, clientID int, previousMessages []models.ChatMessage, profileContext string, isThinkingModel bool) error {
+	timeout := firstChunkTimeout()
+	if timeout <= 0 {
+		return streamModelResponse(ctx, w, flusher, modelName, displayName, providerName, prompt, clientID, previousMessages, profileContext, isThinkingModel)
+	}
+
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watcher := newFirstChunkWatcher(w, flusher)
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		select {
+		case <-watcher.gotChunk:
+		default:
+			timedOut.Store(true)
+			cancel()
+		}
+	})
+	defer timer.Stop()
+
+	err := streamModelResponse(attemptCtx, watcher, watcher, modelName, displayName, providerName, prompt, clientID, previousMessages, profileContext, isThinkingModel)
+	if timedOut.Load() && ctx.Err() == nil {
+		// The cancellation was ours, not the client's; count it against the
+		// provider's health and report a distinct error
+		services.RecordProviderResult(providerName, false, timeout)
+		logger.GetDailyLogger().Warn("Provider %s produced no content within %v for client %d, falling back", providerName, timeout, clientID)
+		return fmt.Errorf("no content within %v (first-chunk timeout)", timeout)
+	}
+	return err
+}
+
+// tierFallbackModel resolves the configured fallback model for the tier the
+// rate limiter stored in the request context, defaulting to the free tier's
+// setting when no tier was recorded
+func tierFallbackModel(ctx context.Context) (model, provider, displayName string) {
+	tier, ok := middleware.GetTierFromRequestContext(ctx)
+	if !ok {
+		tier = config.TierFree
+	}
+	tierConfig, err := config.GetRateLimitConfig(tier)
+	if err != nil {
+		return config.RateLimitConfig{}.EffectiveFallbackModel()
+	}
+	return tierConfig.EffectiveFallbackModel()
+}
+
+// defaultMaxSequenceGap bounds how far a client-computed sequence number may
+// run ahead of the server-known max before the request is rejected
+const defaultMaxSequenceGap = 100
+
+// maxSequenceGap returns the allowed sequence-number gap (MAX_SEQUENCE_GAP,
+// 0 disables the check)
+// sequenceGapExceeded reports whether a client-computed sequence number is
+// further ahead of the server-known next sequence than the allowed gap
+func sequenceGapExceeded(nextSeq, serverNext, gap int) bool {
+	return nextSeq > serverNext+gap
+}
+
+func maxSequenceGap() int {
+	if value := os.Getenv("MAX_SEQUENCE_GAP"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultMaxSequenceGap
+}
+
+// newChatName builds the default name for a freshly created chat based on
+// CHAT_NAME_TEMPLATE: "prompt" (or unset) keeps the historical first-50-chars
+// truncation, "date" names the chat after the current date, and any other
+// value is used as a literal name (e.g. "New chat"). A stopgap until async
+// AI titling lands.
+func newChatName(prompt string) string {
+	switch template := os.Getenv("CHAT_NAME_TEMPLATE"); template {
+	case "", "prompt":
+		name := strings.TrimSpace(prompt)
+		if len(name) > 50 {
+			name = name[:50] + "..."
+		}
+		return name
+	case "date":
+		return "Chat from " + time.Now().Format("Jan 2, 2006")
+	default:
+		return template
+	}
+}
+
+// findRecentEmptyChat returns the user's most recently created chat that has
+// no messages yet, or nil when none exists (or the lookup fails — the caller
+// then just creates a fresh chat)
+func findRecentEmptyChat(ctx context.Context, client *dynamodb.Client, userID string) *aws.Chat {
+	chats, err := aws.GetChatsByUserID(ctx, client, userID)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Failed to look up empty chats for user %s: %v", userID, err)
+		return nil
+	}
+
+	var newest *aws.Chat
+	for i := range chats {
+		if chats[i].MessageCount != 0 {
+			continue
+		}
+		if newest == nil || chats[i].CreatedAt.After(newest.CreatedAt) {
+			newest = &chats[i]
+		}
+	}
+	return newest
+}
+
+// cleanupOrphanedChat removes a chat that was created earlier in the same
+// request along with its lone user message after streaming failed entirely,
+// so failed first completions don't accumulate answerless chats. Best-effort:
+// failures are logged and the request's error response is unaffected.
+func cleanupOrphanedChat(ctx context.Context, client *dynamodb.Client, chatID, userMessageID string, clientID int) {
+	if err := aws.DeleteMessage(ctx, client, userMessageID); err != nil {
+		logger.GetDailyLogger().Warn("Client %d: Failed to delete orphaned user message %s: %v", clientID, userMessageID, err)
+	}
+	if err := aws.DeleteChat(ctx, client, chatID); err != nil {
+		logger.GetDailyLogger().Warn("Client %d: Failed to delete orphaned chat %s: %v", clientID, chatID, err)
+		return
+	}
+	logger.GetDailyLogger().Info("Client %d: Cleaned up orphaned chat %s", clientID, chatID)
+}
+
+// validatedProvider returns the provider to use for a classifier model score.
+// When the provider allow-list pins the model to a different provider, the
+// pinned one wins — a mismatch means the classifier's data is corrupt and the
+// request would otherwise go to an endpoint that cannot serve the model.
+func validatedProvider(model string, score services.ModelScore, clientID int) string {
+	expected := config.ExpectedProvider(model)
+	if expected == "" || strings.EqualFold(expected, score.Provider) {
+		return score.Provider
+	}
+	logger.GetDailyLogger().Warn("Client %d: Classifier paired model %s with provider %s, expected %s; using expected provider", clientID, model, score.Provider, expected)
+	return expected
+}
+
 // streamWithFallback tries models in order with fallback logic
 func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, modelResponse services.ModelResponse, prompt string, clientID int, previousMessages []models.ChatMessage, profileContext string) error {
 	modelsToTry := []struct {
@@ -539,15 +1107,18 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 
 	// Extract model information from response metadata
 	if len(modelResponse.Metadata.ModelScores) == 0 {
+		// Without scores there is no provider mapping for the classifier's
+		// default, so use the tier-configured fallback model instead
+		fallbackModel, fallbackProvider, fallbackDisplayName := tierFallbackModel(ctx)
 		modelsToTry = append(modelsToTry, struct {
 			modelName       string
 			provider        string
 			displayName     string
 			isThinkingModel bool
 		}{
-			modelName:       modelResponse.DefaultModel,
-			provider:        "default", // Fallback provider
-			displayName:     modelResponse.DefaultModelDisplayName,
+			modelName:       fallbackModel,
+			provider:        fallbackProvider,
+			displayName:     fallbackDisplayName,
 			isThinkingModel: false, // Default to false for fallback
 		})
 	} else {
@@ -559,7 +1130,7 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 				isThinkingModel bool
 			}{
 				modelName:       primaryScore.ProviderModelName,
-				provider:        primaryScore.Provider,
+				provider:        validatedProvider(modelResponse.PrimaryModel, primaryScore, clientID),
 				displayName:     primaryScore.DisplayName,
 				isThinkingModel: primaryScore.IsThinkingModel,
 			})
@@ -573,7 +1144,7 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 				isThinkingModel bool
 			}{
 				modelName:       secondaryScore.ProviderModelName,
-				provider:        secondaryScore.Provider,
+				provider:        validatedProvider(modelResponse.SecondaryModel, secondaryScore, clientID),
 				displayName:     secondaryScore.DisplayName,
 				isThinkingModel: secondaryScore.IsThinkingModel,
 			})
@@ -588,7 +1159,7 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 				isThinkingModel bool
 			}{
 				modelName:       defaultScore.ProviderModelName,
-				provider:        defaultScore.Provider,
+				provider:        validatedProvider(modelResponse.DefaultModel, defaultScore, clientID),
 				displayName:     defaultScore.DisplayName,
 				isThinkingModel: defaultScore.IsThinkingModel,
 			})
@@ -602,8 +1173,8 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 	for i, model := range modelsToTry {
 		logger.GetDailyLogger().Info("Trying model %d/%d: %s (%s) for client %d", i+1, len(modelsToTry), model.displayName, model.provider, clientID)
 
-		// Try to stream with this model
-		err := streamModelResponse(ctx, w, flusher, model.modelName, model.displayName, model.provider, prompt, clientID, previousMessages, profileContext, model.isThinkingModel)
+		// Try to stream with this model, bounded by the first-chunk deadline
+		err := streamModelResponseWithFirstChunkDeadline(ctx, w, flusher, model.modelName, model.displayName, model.provider, prompt, clientID, previousMessages, profileContext, model.isThinkingModel)
 
 		if err == nil {
 			// Success!
@@ -630,7 +1201,7 @@ func streamWithFallback(ctx context.Context, w http.ResponseWriter, flusher http
 }
 
 // streamWithFallbackAndSaveAfterCompletion handles streaming with fallback logic and saves assistant message AFTER streaming completes
-func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, modelResponse services.ModelResponse, prompt string, clientID int, previousMessages []models.ChatMessage, profileContext string, userID string, chatID string, assistantSeq int) error {
+func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, modelResponse services.ModelResponse, prompt string, clientID int, previousMessages []models.ChatMessage, profileContext string, userID string, chatID string, assistantSeq int, requestID string) error {
 	modelsToTry := []struct {
 		modelName       string
 		provider        string
@@ -640,15 +1211,17 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 
 	// Extract model information from response metadata
 	if len(modelResponse.Metadata.ModelScores) == 0 {
+		services.RecordClassifierFallback("classifier returned no model scores; using tier fallback model")
+		fallbackModel, fallbackProvider, fallbackDisplayName := tierFallbackModel(ctx)
 		modelsToTry = append(modelsToTry, struct {
 			modelName       string
 			provider        string
 			displayName     string
 			isThinkingModel bool
 		}{
-			modelName:       modelResponse.DefaultModel,
-			provider:        "default", // Fallback provider
-			displayName:     modelResponse.DefaultModelDisplayName,
+			modelName:       fallbackModel,
+			provider:        fallbackProvider,
+			displayName:     fallbackDisplayName,
 			isThinkingModel: false, // Default to false for fallback
 		})
 	} else {
@@ -660,7 +1233,7 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 				isThinkingModel bool
 			}{
 				modelName:       primaryScore.ProviderModelName,
-				provider:        primaryScore.Provider,
+				provider:        validatedProvider(modelResponse.PrimaryModel, primaryScore, clientID),
 				displayName:     primaryScore.DisplayName,
 				isThinkingModel: primaryScore.IsThinkingModel,
 			})
@@ -674,7 +1247,7 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 				isThinkingModel bool
 			}{
 				modelName:       secondaryScore.ProviderModelName,
-				provider:        secondaryScore.Provider,
+				provider:        validatedProvider(modelResponse.SecondaryModel, secondaryScore, clientID),
 				displayName:     secondaryScore.DisplayName,
 				isThinkingModel: secondaryScore.IsThinkingModel,
 			})
@@ -689,13 +1262,61 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 				isThinkingModel bool
 			}{
 				modelName:       defaultScore.ProviderModelName,
-				provider:        defaultScore.Provider,
+				provider:        validatedProvider(modelResponse.DefaultModel, defaultScore, clientID),
 				displayName:     defaultScore.DisplayName,
 				isThinkingModel: defaultScore.IsThinkingModel,
 			})
 		}
 	}
 
+	// Rescore candidates with operator-configured quality/cost weights; when
+	// neither weight is set the classifier's FinalScore ordering stands
+	if qualityWeight, costWeight, weightsSet := services.ScoreWeights(); weightsSet && len(modelResponse.Metadata.ModelScores) > 0 {
+		rescored := make(map[string]float64, len(modelResponse.Metadata.ModelScores))
+		for _, score := range modelResponse.Metadata.ModelScores {
+			rescored[score.ProviderModelName] = services.RescoreModel(score, qualityWeight, costWeight)
+		}
+		sort.SliceStable(modelsToTry, func(i, j int) bool {
+			return rescored[modelsToTry[i].modelName] > rescored[modelsToTry[j].modelName]
+		})
+		logger.GetDailyLogger().Info("Rescored %d candidates with weights quality=%.2f cost=%.2f for client %d", len(modelsToTry), qualityWeight, costWeight, clientID)
+	}
+
+	// Apply per-category routing overrides when the classifier is confident
+	if preferred := config.GetPreferredProviders(modelResponse.Metadata.PredictedCategory, modelResponse.Metadata.Confidence); len(preferred) > 0 {
+		providerRank := func(provider string) int {
+			for i, p := range preferred {
+				if p == provider {
+					return i
+				}
+			}
+			return len(preferred)
+		}
+		sort.SliceStable(modelsToTry, func(i, j int) bool {
+			return providerRank(modelsToTry[i].provider) < providerRank(modelsToTry[j].provider)
+		})
+		logger.GetDailyLogger().Info("Applied category routing for %q (confidence %.2f) for client %d", modelResponse.Metadata.PredictedCategory, modelResponse.Metadata.Confidence, clientID)
+	}
+
+	// Reorder candidates by recent provider health when adaptive fallback is
+	// enabled, keeping the classifier's top choice first unless it's clearly
+	// unhealthy
+	if services.AdaptiveFallbackEnabled() && len(modelsToTry) > 1 {
+		primary := modelsToTry[0]
+		sort.SliceStable(modelsToTry, func(i, j int) bool {
+			return services.GetProviderHealthScore(modelsToTry[i].provider) > services.GetProviderHealthScore(modelsToTry[j].provider)
+		})
+		if !services.IsProviderUnhealthy(primary.provider) {
+			for i := range modelsToTry {
+				if modelsToTry[i] == primary {
+					copy(modelsToTry[1:i+1], modelsToTry[:i])
+					modelsToTry[0] = primary
+					break
+				}
+			}
+		}
+	}
+
 	// Try models in order
 	var lastError error
 	var errors []string
@@ -706,26 +1327,59 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 
 		// Create a custom response writer to capture the assistant's response
 		responseCapture := &responseWriterWithCapture{
-			ResponseWriter: w,
-			response:       &assistantResponse,
+			ResponseWriter:  w,
+			response:        &assistantResponse,
+			resumeUserID:    userID,
+			resumeRequestID: requestID,
+			persistEvery:    resumePersistEveryChunks(),
 		}
 
-		// Try to stream with this model
-		err := streamModelResponse(ctx, responseCapture, flusher, model.modelName, model.displayName, model.provider, prompt, clientID, previousMessages, profileContext, model.isThinkingModel)
+		// Try to stream with this model, bounded by the first-chunk deadline
+		err := streamModelResponseWithFirstChunkDeadline(ctx, responseCapture, flusher, model.modelName, model.displayName, model.provider, prompt, clientID, previousMessages, profileContext, model.isThinkingModel)
 
 		if err == nil {
+			// The full message is about to be persisted; drop the partial
+			// snapshot used for resumption
+			if requestID != "" {
+				clearPartialContent(context.Background(), userID, requestID)
+			}
+
 			// Success! Now save the assistant's response to database AFTER streaming is complete
 			if assistantResponse.Len() > 0 {
 				dbCtx := context.Background()
 				dbClient := aws.GetDynamoDBClient(dbCtx)
 
+				// Truncate oversized responses instead of failing the save
+				content := assistantResponse.String()
+				truncated := false
+				if limit := maxMessageContentBytes(); len(content) > limit {
+					logger.GetDailyLogger().Warn("Client %d: Assistant response is %d bytes, truncating to %d before save", clientID, len(content), limit)
+					content = content[:limit]
+					truncated = true
+				}
+
+				// Record how many tokens went to reasoning vs the answer, both
+				// in metrics and on the persisted message
+				thinkingTokens, answerTokens := services.SplitThinkingTokens(content)
+				services.RecordThinkingSplit(model.modelName, thinkingTokens, answerTokens)
+
+				// The streamed response already reached the user; only the
+				// stored copy is scrubbed
 				assistantMessage := aws.Message{
 					ChatID:         chatID,
 					UserID:         userID,
-					Content:        assistantResponse.String(),
-					ModelName:      model.displayName,
+					Content:        scrubPII(content),
+					ModelName:      sanitizeModelDisplayName(model.displayName),
 					Role:           "assistant",
 					SequenceNumber: assistantSeq,
+					Truncated:      truncated,
+					ThinkingTokens: thinkingTokens,
+					AnswerTokens:   answerTokens,
+				}
+
+				// Persist the seed so the generation can be reproduced later
+				if opts := services.GetGenerationOptionsFromContext(ctx); opts != nil {
+					assistantMessage.Seed = opts.Seed
 				}
 
 				savedAssistantMessage, err := aws.CreateMessage(dbCtx, dbClient, assistantMessage)
@@ -734,9 +1388,20 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 					// Don't fail the request if we can't save the message, just log it
 				} else {
 					logger.GetDailyLogger().Info("Client %d: Saved assistant message %s after streaming completion", clientID, savedAssistantMessage.ID)
+
+					// Keep the chat's denormalized last-message metadata current
+					if err := aws.RecordChatMessageActivity(dbCtx, dbClient, chatID, savedAssistantMessage.Content, savedAssistantMessage.CreatedAt, 1); err != nil {
+						logger.GetDailyLogger().Error("Error updating chat metadata for client %d: %v", clientID, err)
+					}
 				}
 			}
 
+			// Refund the charged request if the provider returned trivially
+			// little content
+			if minChars := minBillableChars(); minChars > 0 && assistantResponse.Len() < minChars {
+				refundRequestUsage(ctx, userID, clientID, assistantResponse.Len())
+			}
+
 			logger.GetDailyLogger().Info("Successfully streamed with model %s for client %d", model.displayName, clientID)
 			return nil
 		}
@@ -762,10 +1427,199 @@ func streamWithFallbackAndSaveAfterCompletion(ctx context.Context, w http.Respon
 	return fmt.Errorf("all models failed to respond")
 }
 
+// Default budgets for client-supplied message history
+const (
+	defaultMaxPreviousMessages     = 50
+	defaultMaxPreviousMessageBytes = 256 * 1024
+)
+
+// defaultMaxRequestBodyBytes caps request bodies at 1MB unless overridden
+const defaultMaxRequestBodyBytes = 1024 * 1024
+
+// maxRequestBodyBytes returns the request body size cap
+// (MAX_REQUEST_BODY_BYTES); multimodal deployments may need more than the
+// default, text-only ones less
+func maxRequestBodyBytes() int64 {
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultMaxRequestBodyBytes
+}
+
+// previousMessageLimits returns the configured count and byte budgets for
+// client-supplied history (MAX_PREVIOUS_MESSAGES / MAX_PREVIOUS_MESSAGES_BYTES)
+func previousMessageLimits() (maxCount, maxBytes int) {
+	maxCount = defaultMaxPreviousMessages
+	if value := os.Getenv("MAX_PREVIOUS_MESSAGES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			maxCount = parsed
+		}
+	}
+
+	maxBytes = defaultMaxPreviousMessageBytes
+	if value := os.Getenv("MAX_PREVIOUS_MESSAGES_BYTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			maxBytes = parsed
+		}
+	}
+
+	return maxCount, maxBytes
+}
+
+// boundPreviousMessages truncates client-supplied history to the most recent
+// messages that fit within the configured count and byte budgets, protecting
+// provider cost regardless of the per-provider context window
+func boundPreviousMessages(previousMessages []models.ChatMessage, clientID int) []models.ChatMessage {
+	maxCount, maxBytes := previousMessageLimits()
+
+	start := len(previousMessages)
+	totalBytes := 0
+	for start > 0 {
+		msgBytes := len(previousMessages[start-1].Content)
+		if len(previousMessages)-start+1 > maxCount || totalBytes+msgBytes > maxBytes {
+			break
+		}
+		totalBytes += msgBytes
+		start--
+	}
+
+	if start > 0 {
+		logger.GetDailyLogger().Warn("Client %d: Truncated previous messages from %d to %d (budget: %d messages, %d bytes)", clientID, len(previousMessages), len(previousMessages)-start, maxCount, maxBytes)
+	}
+
+	return previousMessages[start:]
+}
+
+// allowedContentTypes returns the media types accepted on the complete
+// endpoint, configurable via ALLOWED_CONTENT_TYPES (comma-separated)
+func allowedContentTypes() []string {
+	if value := os.Getenv("ALLOWED_CONTENT_TYPES"); value != "" {
+		var allowed []string
+		for _, entry := range strings.Split(value, ",") {
+			if entry = strings.TrimSpace(strings.ToLower(entry)); entry != "" {
+				allowed = append(allowed, entry)
+			}
+		}
+		if len(allowed) > 0 {
+			return allowed
+		}
+	}
+	return []string{"application/json"}
+}
+
+// isAllowedContentType reports whether the request body's declared media type
+// is acceptable; requests without a body or Content-Type header pass
+func isAllowedContentType(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" || r.ContentLength == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range allowedContentTypes() {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// serverSideHistoryEnabled reports whether client-sent history should be
+// replaced with the stored messages for existing chats (SERVER_SIDE_HISTORY)
+func serverSideHistoryEnabled() bool {
+	return os.Getenv("SERVER_SIDE_HISTORY") == "true"
+}
+
+// loadServerSideHistory fetches the stored messages for a chat after verifying
+// the chat belongs to the requesting user, ordered by sequence number
+func loadServerSideHistory(ctx context.Context, chatID, userID string) ([]models.ChatMessage, error) {
+	dbClient := aws.GetDynamoDBClient(ctx)
+
+	chat, err := aws.GetChat(ctx, dbClient, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat.UserID != userID {
+		return nil, fmt.Errorf("chat %s does not belong to user", chatID)
+	}
+
+	messages, err := aws.GetMessagesByChatID(ctx, dbClient, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].SequenceNumber < messages[j].SequenceNumber
+	})
+
+	history := make([]models.ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		history = append(history, models.ChatMessage{
+			Role:           msg.Role,
+			Content:        msg.Content,
+			ModelName:      msg.ModelName,
+			ChatID:         msg.ChatID,
+			SequenceNumber: msg.SequenceNumber,
+		})
+	}
+
+	return history, nil
+}
+
+// minBillableChars returns the minimum captured content length for a request
+// to count against the user's quota (MIN_BILLABLE_CHARS, 0 disables refunds)
+func minBillableChars() int {
+	if value := os.Getenv("MIN_BILLABLE_CHARS"); value != "" {
+		if minChars, err := strconv.Atoi(value); err == nil && minChars > 0 {
+			return minChars
+		}
+	}
+	return 0
+}
+
+// refundRequestUsage decrements the usage counter charged for this request
+// when the streamed response was below the billable threshold
+func refundRequestUsage(ctx context.Context, userID string, clientID int, contentLen int) {
+	requestType, ok := middleware.GetRequestTypeFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	key := "user:" + userID
+	isAnonymous := false
+	tier := config.TierFree
+	if user, ok := middleware.GetFirebaseUserFromContext(ctx); ok && user != nil && middleware.IsAnonymousUser(user) {
+		key = "anonymous:" + userID
+		isAnonymous = true
+		tier = config.TierAnonymous
+	}
+
+	if err := middleware.RefundUsage(context.Background(), key, tier, isAnonymous, requestType); err != nil {
+		logger.GetDailyLogger().Error("Failed to refund usage for client %d: %v", clientID, err)
+		return
+	}
+
+	logger.GetDailyLogger().Info("Refunded %s request for client %d (response was %d chars)", string(requestType), clientID, contentLen)
+}
+
 // responseWriterWithCapture wraps http.ResponseWriter to capture the response content while preserving streaming
 type responseWriterWithCapture struct {
 	http.ResponseWriter
 	response *strings.Builder
+
+	// When resumeRequestID is set, the captured content is snapshotted to
+	// Redis every persistEvery chunks so the stream can be resumed after a
+	// client disconnect
+	resumeUserID    string
+	resumeRequestID string
+	persistEvery    int
+	chunkCount      int
 }
 
 func (rw *responseWriterWithCapture) Write(b []byte) (int, error) {
@@ -784,6 +1638,15 @@ func (rw *responseWriterWithCapture) Write(b []byte) (int, error) {
 		if err := json.Unmarshal([]byte(jsonData), &response); err == nil {
 			if response.Type == "chunk" && response.Message != "" {
 				rw.response.WriteString(response.Message)
+
+				if rw.resumeRequestID != "" {
+					rw.chunkCount++
+					if rw.persistEvery > 0 && rw.chunkCount%rw.persistEvery == 0 {
+						// Deliberately not the request context: snapshots
+						// must still land while the client is disconnecting
+						persistPartialContent(context.Background(), rw.resumeUserID, rw.resumeRequestID, rw.response.String())
+					}
+				}
 			}
 		}
 	}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+
+	"gateway/middleware"
+	"gateway/models"
+)
+
+func TestMaxRequestBodyBytes(t *testing.T) {
+	if got := maxRequestBodyBytes(); got != defaultMaxRequestBodyBytes {
+		t.Errorf("default limit = %d, want %d", got, defaultMaxRequestBodyBytes)
+	}
+
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "1024")
+	if got := maxRequestBodyBytes(); got != 1024 {
+		t.Errorf("limit with override = %d, want 1024", got)
+	}
+
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "-5")
+	if got := maxRequestBodyBytes(); got != defaultMaxRequestBodyBytes {
+		t.Errorf("limit with negative override = %d, want default %d", got, defaultMaxRequestBodyBytes)
+	}
+}
+
+func TestClientHandlerOversizedBody413(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "1024")
+
+	body := strings.Repeat("a", 2048)
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	user := &auth.UserRecord{UserInfo: &auth.UserInfo{UID: "test-uid", Email: "test@example.com"}}
+	r = r.WithContext(context.WithValue(r.Context(), middleware.FirebaseUserContextKey, user))
+
+	w := httptest.NewRecorder()
+	ClientHandler(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body returned status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestRateLimitStatusSerialization(t *testing.T) {
+	remaining := 42
+	limited := RateLimitStatus{
+		DailyLimit:        100,
+		RequestsUsed:      58,
+		RequestsRemaining: &remaining,
+	}
+	data, err := json.Marshal(limited)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"requests_remaining":42`) {
+		t.Errorf("limited status missing numeric requests_remaining: %s", data)
+	}
+	if !strings.Contains(string(data), `"unlimited":false`) {
+		t.Errorf("limited status should report unlimited=false: %s", data)
+	}
+
+	unlimited := RateLimitStatus{
+		DailyLimit: -1,
+		Unlimited:  true,
+	}
+	data, err = json.Marshal(unlimited)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	// Unlimited tiers must report null, not a fake large remaining count
+	if !strings.Contains(string(data), `"requests_remaining":null`) {
+		t.Errorf("unlimited status should serialize requests_remaining as null: %s", data)
+	}
+	if !strings.Contains(string(data), `"unlimited":true`) {
+		t.Errorf("unlimited status should report unlimited=true: %s", data)
+	}
+}
+
+func TestSanitizeModelDisplayName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain name", "Gemini 2.0 Flash", "Gemini 2.0 Flash"},
+		{"empty", "", "Unknown model"},
+		{"whitespace only", "   \t  ", "Unknown model"},
+		{"control characters stripped", "Fast\x00 Model\x1b[31m", "Fast Model[31m"},
+		{"newlines stripped", "Line\nBreak\rModel", "LineBreakModel"},
+		{"control characters only", "\x00\x01\x02", "Unknown model"},
+		{"surrounding whitespace trimmed", "  Model  ", "Model"},
+		{"overlong name truncated", strings.Repeat("a", 100), strings.Repeat("a", 64)},
+		{"multibyte runes counted as runes", strings.Repeat("é", 70), strings.Repeat("é", 64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeModelDisplayName(tt.input); got != tt.want {
+				t.Errorf("sanitizeModelDisplayName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxSequenceGap(t *testing.T) {
+	t.Setenv("MAX_SEQUENCE_GAP", "")
+	if got := maxSequenceGap(); got != defaultMaxSequenceGap {
+		t.Errorf("default gap = %d, want %d", got, defaultMaxSequenceGap)
+	}
+
+	t.Setenv("MAX_SEQUENCE_GAP", "10")
+	if got := maxSequenceGap(); got != 10 {
+		t.Errorf("gap with override = %d, want 10", got)
+	}
+
+	t.Setenv("MAX_SEQUENCE_GAP", "0")
+	if got := maxSequenceGap(); got != 0 {
+		t.Errorf("gap with 0 = %d, want 0 (check disabled)", got)
+	}
+
+	t.Setenv("MAX_SEQUENCE_GAP", "wide")
+	if got := maxSequenceGap(); got != defaultMaxSequenceGap {
+		t.Errorf("gap with invalid override = %d, want the default", got)
+	}
+}
+
+func TestSequenceGapExceeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		nextSeq    int
+		serverNext int
+		gap        int
+		want       bool
+	}{
+		{"in sync", 5, 5, 100, false},
+		{"slightly ahead", 10, 5, 100, false},
+		{"at the limit", 105, 5, 100, false},
+		{"just past the limit", 106, 5, 100, true},
+		{"wildly ahead", 100000, 5, 100, true},
+		{"behind the server", 3, 5, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sequenceGapExceeded(tt.nextSeq, tt.serverNext, tt.gap); got != tt.want {
+				t.Errorf("sequenceGapExceeded(%d, %d, %d) = %v, want %v", tt.nextSeq, tt.serverNext, tt.gap, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoundPreviousMessages(t *testing.T) {
+	t.Setenv("MAX_PREVIOUS_MESSAGES", "5")
+	t.Setenv("MAX_PREVIOUS_MESSAGES_BYTES", "")
+
+	// An oversized history array keeps only the most recent entries
+	oversized := make([]models.ChatMessage, 20)
+	for i := range oversized {
+		oversized[i] = models.ChatMessage{Role: "user", Content: fmt.Sprintf("message %d", i)}
+	}
+	got := boundPreviousMessages(oversized, 1)
+	if len(got) != 5 {
+		t.Fatalf("bounded %d messages to %d, want 5", len(oversized), len(got))
+	}
+	if got[0].Content != "message 15" || got[4].Content != "message 19" {
+		t.Errorf("kept %q..%q, want the most recent message 15..message 19", got[0].Content, got[4].Content)
+	}
+
+	// Under the limit the history passes through untouched
+	small := oversized[:3]
+	if got := boundPreviousMessages(small, 1); len(got) != 3 {
+		t.Errorf("under-limit history bounded to %d messages, want 3", len(got))
+	}
+}
+
+func TestBoundPreviousMessagesByteBudget(t *testing.T) {
+	t.Setenv("MAX_PREVIOUS_MESSAGES", "")
+	t.Setenv("MAX_PREVIOUS_MESSAGES_BYTES", "100")
+
+	history := []models.ChatMessage{
+		{Role: "user", Content: strings.Repeat("a", 80)},
+		{Role: "assistant", Content: strings.Repeat("b", 80)},
+	}
+	got := boundPreviousMessages(history, 1)
+	if len(got) != 1 {
+		t.Fatalf("bounded to %d messages under a 100-byte budget, want 1", len(got))
+	}
+	if got[0].Content[0] != 'b' {
+		t.Error("byte budget kept the oldest message instead of the newest")
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+)
+
+// In-flight completion deduplication: a double-clicked send fires two
+// identical /v1/complete requests, and without a guard both append messages
+// and both pay for a provider stream. The first request claims a Redis key
+// derived from (user, chat, prompt); concurrent identical requests are
+// rejected until it completes.
+
+// dedupeKeyPrefix namespaces in-flight completion markers in Redis
+const dedupeKeyPrefix = "inflight:"
+
+// defaultDedupeTTLSeconds bounds how long a marker can outlive a request that
+// failed to clean up (e.g. a crashed instance)
+const defaultDedupeTTLSeconds = 120
+
+// dedupeTTL returns the in-flight marker TTL (DEDUPE_TTL_SECONDS, 0 disables
+// deduplication)
+func dedupeTTL() time.Duration {
+	if value := os.Getenv("DEDUPE_TTL_SECONDS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return time.Duration(parsed) * time.Second
+		}
+	}
+	return defaultDedupeTTLSeconds * time.Second
+}
+
+// dedupeKey derives the in-flight marker key from the request identity; the
+// prompt is hashed so arbitrary content never lands in a Redis key
+func dedupeKey(userID, chatID, prompt string) string {
+	digest := sha256.Sum256([]byte(prompt))
+	return dedupeKeyPrefix + userID + ":" + chatID + ":" + hex.EncodeToString(digest[:8])
+}
+
+// tryAcquireInflightRequest claims the in-flight marker for this request.
+// Returns whether the caller may proceed and a release func to call when the
+// request finishes (a no-op when nothing was claimed). Fails open on Redis
+// errors like the other limiters.
+func tryAcquireInflightRequest(ctx context.Context, userID, chatID, prompt string) (bool, func()) {
+	noop := func() {}
+
+	ttl := dedupeTTL()
+	if ttl <= 0 {
+		return true, noop
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		return true, noop
+	}
+
+	key := dedupeKey(userID, chatID, prompt)
+	acquired, err := client.SetNX(ctx, key, "1", ttl).Result()
+	if err != nil {
+		logger.GetDailyLogger().Warn("In-flight dedupe check failed for user %s: %v", userID, err)
+		return true, noop
+	}
+	if !acquired {
+		return false, noop
+	}
+
+	// Deliberately not the request context: the marker must be cleared even
+	// when the client has disconnected
+	return true, func() {
+		if err := client.Del(context.Background(), key).Err(); err != nil {
+			logger.GetDailyLogger().Warn("Failed to clear in-flight dedupe marker for user %s: %v", userID, err)
+		}
+	}
+}
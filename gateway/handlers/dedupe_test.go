@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupeKey(t *testing.T) {
+	key := dedupeKey("user-1", "chat-1", "tell me a joke")
+
+	if !strings.HasPrefix(key, dedupeKeyPrefix) {
+		t.Errorf("key %q missing the %q namespace", key, dedupeKeyPrefix)
+	}
+	// The raw prompt must never appear in a Redis key
+	if strings.Contains(key, "tell me a joke") {
+		t.Errorf("key %q contains the raw prompt", key)
+	}
+
+	// Deterministic for identical requests
+	if again := dedupeKey("user-1", "chat-1", "tell me a joke"); again != key {
+		t.Errorf("identical requests produced different keys: %q vs %q", key, again)
+	}
+
+	// Distinct per user, chat and prompt
+	if other := dedupeKey("user-2", "chat-1", "tell me a joke"); other == key {
+		t.Error("different users produced the same key")
+	}
+	if other := dedupeKey("user-1", "chat-2", "tell me a joke"); other == key {
+		t.Error("different chats produced the same key")
+	}
+	if other := dedupeKey("user-1", "chat-1", "different prompt"); other == key {
+		t.Error("different prompts produced the same key")
+	}
+}
+
+func TestDedupeTTL(t *testing.T) {
+	t.Setenv("DEDUPE_TTL_SECONDS", "")
+	if got := dedupeTTL(); got != defaultDedupeTTLSeconds*time.Second {
+		t.Errorf("default TTL = %v, want %ds", got, defaultDedupeTTLSeconds)
+	}
+
+	t.Setenv("DEDUPE_TTL_SECONDS", "30")
+	if got := dedupeTTL(); got != 30*time.Second {
+		t.Errorf("TTL with override = %v, want 30s", got)
+	}
+
+	t.Setenv("DEDUPE_TTL_SECONDS", "0")
+	if got := dedupeTTL(); got != 0 {
+		t.Errorf("TTL with 0 = %v, want 0 (deduplication disabled)", got)
+	}
+
+	t.Setenv("DEDUPE_TTL_SECONDS", "not-a-number")
+	if got := dedupeTTL(); got != defaultDedupeTTLSeconds*time.Second {
+		t.Errorf("TTL with invalid override = %v, want the default", got)
+	}
+}
+
+func TestTryAcquireInflightRequestFailsOpen(t *testing.T) {
+	// Without an initialized Redis client the dedupe guard must admit the
+	// request rather than block completions
+	t.Setenv("DEDUPE_TTL_SECONDS", "")
+
+	ok, release := tryAcquireInflightRequest(context.Background(), "user-1", "chat-1", "prompt")
+	if !ok {
+		t.Error("dedupe guard should fail open without Redis")
+	}
+	release()
+
+	// Disabled via TTL: always admits
+	t.Setenv("DEDUPE_TTL_SECONDS", "0")
+	ok, release = tryAcquireInflightRequest(context.Background(), "user-1", "chat-1", "prompt")
+	if !ok {
+		t.Error("dedupe guard should admit when disabled")
+	}
+	release()
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gateway/middleware"
+	"gateway/pkg/logger"
+	"gateway/services"
+)
+
+// SetupInternalRoutes sets up service-to-service routes protected by the
+// internal shared secret
+func SetupInternalRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/internal/models-updated", func(w http.ResponseWriter, r *http.Request) {
+		middleware.InternalAuthMiddleware(http.HandlerFunc(ModelsUpdatedHandler)).ServeHTTP(w, r)
+	})
+}
+
+// ModelsUpdatedHandler handles POST /v1/internal/models-updated, called by
+// the classifier when its model roster changes. The new roster is carried in
+// the body so the gateway's cached copy is replaced atomically instead of
+// being invalidated and re-fetched. Malformed payloads are rejected without
+// touching the current roster.
+func ModelsUpdatedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Models []services.ModelRosterEntry `json:"models"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendAPIErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Models) == 0 {
+		sendAPIErrorResponse(w, "models list is required", http.StatusBadRequest)
+		return
+	}
+	for _, model := range req.Models {
+		if model.Name == "" {
+			sendAPIErrorResponse(w, "every model entry requires a name", http.StatusBadRequest)
+			return
+		}
+	}
+
+	services.SetModelRoster(req.Models)
+
+	logger.GetLogger("internal_routes").InfoWithFields("Model roster updated by classifier", map[string]interface{}{
+		"models": len(req.Models),
+	})
+
+	sendJSONResponse(w, map[string]interface{}{
+		"status": "updated",
+		"models": len(req.Models),
+	}, http.StatusOK)
+}
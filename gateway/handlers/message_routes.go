@@ -5,15 +5,41 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"gateway/aws"
 	"gateway/middleware"
+	"gateway/models"
 	"gateway/pkg/logger"
 )
 
+// defaultMaxMessageContentBytes keeps message items comfortably under
+// DynamoDB's 400KB item limit after accounting for the other attributes
+const defaultMaxMessageContentBytes = 350 * 1024
+
+// maxMessageContentBytes returns the configured limit on message content size in bytes
+func maxMessageContentBytes() int {
+	if value := os.Getenv("MAX_MESSAGE_CONTENT_BYTES"); value != "" {
+		if limit, err := strconv.Atoi(value); err == nil && limit > 0 {
+			return limit
+		}
+	}
+	return defaultMaxMessageContentBytes
+}
+
+// validateMessageContentSize rejects content over the configured limit with a 400
+func validateMessageContentSize(w http.ResponseWriter, content string) bool {
+	limit := maxMessageContentBytes()
+	if len(content) > limit {
+		sendAPIErrorResponse(w, fmt.Sprintf("Message content exceeds maximum size of %d bytes", limit), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 // handleMessageCombined handles both collection and individual message operations
 func handleMessageCombined(w http.ResponseWriter, r *http.Request) {
 	// Extract potential message ID from path
@@ -27,6 +53,9 @@ func handleMessageCombined(w http.ResponseWriter, r *http.Request) {
 		} else {
 			sendAPIErrorResponse(w, "Method not allowed for collection", http.StatusMethodNotAllowed)
 		}
+	} else if strings.HasSuffix(messageID, "/stream") {
+		// Replay a persisted assistant message as a stream
+		ReplayMessageStreamHandler(w, r, strings.TrimSuffix(messageID, "/stream"))
 	} else {
 		// Handle individual message operations
 		MessageByIDHandler(w, r)
@@ -62,13 +91,19 @@ func MessageOperationsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Stream messages as NDJSON when requested, avoiding buffering huge chats
+		if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+			streamMessagesByChatID(ctx, w, chatID)
+			return
+		}
+
 		messages, err := aws.GetMessagesByChatID(ctx, client, chatID)
 		if err != nil {
 			logger.GetDailyLogger().Error("Error getting messages by chat ID: %v", err)
 			sendAPIErrorResponse(w, "Failed to get messages", http.StatusInternalServerError)
 			return
 		}
-		sendJSONResponse(w, messages, http.StatusOK)
+		sendListResponse(w, r, messages, len(messages), http.StatusOK)
 		return
 	}
 
@@ -139,6 +174,32 @@ func MessageOperationsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// streamMessagesByChatID writes each message for a chat as an NDJSON line as it
+// is read from DynamoDB, improving time-to-first-byte for very large chats
+func streamMessagesByChatID(ctx context.Context, w http.ResponseWriter, chatID string) {
+	client := aws.GetDynamoDBClient(ctx)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	err := aws.ForEachMessageByChatID(ctx, client, chatID, func(message aws.Message) error {
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so all we can do is log and stop the stream
+		logger.GetDailyLogger().Error("Error streaming messages by chat ID: %v", err)
+	}
+}
+
 // BatchMessagesHandler handles POST /v1/messages/batch
 func BatchMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -162,26 +223,38 @@ func BatchMessagesHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	client := aws.GetDynamoDBClient(ctx)
 
+	// Validate the whole batch (content size and chat ownership) before
+	// creating anything, so a bad message at index N can't leave messages
+	// 0..N-1 persisted behind a 4xx response
+	verifiedChats := make(map[string]bool)
+	for _, message := range messages {
+		if !validateMessageContentSize(w, message.Content) {
+			return
+		}
+
+		if message.ChatID == "" || verifiedChats[message.ChatID] {
+			continue
+		}
+
+		chat, err := aws.GetChat(ctx, client, message.ChatID)
+		if err != nil {
+			sendAPIErrorResponse(w, "Chat not found", http.StatusNotFound)
+			return
+		}
+
+		if chat.UserID != user.UID {
+			sendAPIErrorResponse(w, "Access denied: You can only create messages in your own chats", http.StatusForbidden)
+			return
+		}
+		verifiedChats[message.ChatID] = true
+	}
+
 	// Create messages individually since we don't have a batch create function
 	var createdMessages []*aws.Message
 	for _, message := range messages {
 		// Force the user ID to match the authenticated user
 		message.UserID = user.UID
 
-		// Verify user owns the chat
-		if message.ChatID != "" {
-			chat, err := aws.GetChat(ctx, client, message.ChatID)
-			if err != nil {
-				sendAPIErrorResponse(w, "Chat not found", http.StatusNotFound)
-				return
-			}
-
-			if chat.UserID != user.UID {
-				sendAPIErrorResponse(w, "Access denied: You can only create messages in your own chats", http.StatusForbidden)
-				return
-			}
-		}
-
 		message.CreatedAt = time.Now()
 		message.UpdatedAt = time.Now()
 
@@ -351,6 +424,10 @@ func MessageByIDHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if !validateMessageContentSize(w, message.Content) {
+			return
+		}
+
 		// Ensure the user can't change ownership
 		message.ID = messageID
 		message.UserID = user.UID
@@ -385,6 +462,14 @@ func MessageByIDHandler(w http.ResponseWriter, r *http.Request) {
 			sendAPIErrorResponse(w, "Failed to delete message", http.StatusInternalServerError)
 			return
 		}
+
+		// Decrement the chat's denormalized message count
+		if existingMessage.ChatID != "" {
+			if err := aws.RecordChatMessageActivity(ctx, client, existingMessage.ChatID, "", time.Time{}, -1); err != nil {
+				logger.GetDailyLogger().Error("Error updating chat metadata: %v", err)
+			}
+		}
+
 		sendJSONResponse(w, map[string]bool{"success": true}, http.StatusOK)
 
 	default:
@@ -392,6 +477,109 @@ func MessageByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// replayThinkEndMarker matches the thinking end marker the providers emit,
+// so a stored thinking segment can be replayed as its own chunk
+const replayThinkEndMarker = "◁/think▷"
+
+// splitReplayChunks splits stored content into a thinking chunk (markers
+// included, since they are stored inline) and the answer, mirroring how the
+// live stream framed them
+func splitReplayChunks(content string) []string {
+	if idx := strings.Index(content, replayThinkEndMarker); idx != -1 {
+		end := idx + len(replayThinkEndMarker)
+		return []string{content[:end], content[end:]}
+	}
+	return []string{content}
+}
+
+// ReplayMessageStreamHandler handles GET /v1/messages/{messageId}/stream,
+// replaying a persisted assistant message through the SSE framing so a
+// client that lost the original stream (network blip) can re-render it
+// without re-generating. No provider is called and no usage is charged.
+func ReplayMessageStreamHandler(w http.ResponseWriter, r *http.Request, messageID string) {
+	if r.Method != http.MethodGet {
+		sendAPIErrorResponse(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if messageID == "" {
+		sendAPIErrorResponse(w, "Message ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get authenticated user from context
+	user, ok := middleware.GetFirebaseUserFromContext(r.Context())
+	if !ok || user == nil {
+		sendAPIErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.Background()
+	client := aws.GetDynamoDBClient(ctx)
+
+	message, err := aws.GetMessage(ctx, client, messageID)
+	if err != nil {
+		logger.GetDailyLogger().Error("Error getting message for replay: %v", err)
+		sendAPIErrorResponse(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify user owns this message
+	if message.UserID != user.UID {
+		sendAPIErrorResponse(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	// Only assistant messages have a stream to replay
+	if message.Role != "assistant" {
+		sendAPIErrorResponse(w, "Only assistant messages can be replayed", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendAPIErrorResponse(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	writeFrame := func(resp models.Response) {
+		if msg, err := models.FormatSSEMessage(resp); err == nil {
+			fmt.Fprint(w, msg)
+			flusher.Flush()
+		}
+	}
+
+	writeFrame(models.Response{
+		Message: message.ModelName,
+		Type:    "start",
+		Model:   message.ModelName,
+	})
+
+	// Stored content embeds any thinking segment between its original
+	// markers; emit it as its own chunk so clients render thinking and
+	// answer the same way they would have live
+	for _, chunk := range splitReplayChunks(message.Content) {
+		if chunk == "" {
+			continue
+		}
+		writeFrame(models.Response{
+			Message: chunk,
+			Type:    "chunk",
+		})
+	}
+
+	writeFrame(models.Response{
+		Type:         "end",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		FinishReason: "stop",
+	})
+
+	logger.GetDailyLogger().Info("Replayed stored message %s for user %s", messageID, user.UID)
+}
+
 // CreateMessageHandler handles POST /v1/messages
 func CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -412,6 +600,10 @@ func CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validateMessageContentSize(w, message.Content) {
+		return
+	}
+
 	ctx := context.Background()
 	client := aws.GetDynamoDBClient(ctx)
 
@@ -442,6 +634,13 @@ func CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep the chat's denormalized last-message metadata current
+	if createdMessage.ChatID != "" {
+		if err := aws.RecordChatMessageActivity(ctx, client, createdMessage.ChatID, createdMessage.Content, createdMessage.CreatedAt, 1); err != nil {
+			logger.GetDailyLogger().Error("Error updating chat metadata: %v", err)
+		}
+	}
+
 	sendJSONResponse(w, createdMessage, http.StatusCreated)
 }
 
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxMessageContentBytes(t *testing.T) {
+	if got := maxMessageContentBytes(); got != defaultMaxMessageContentBytes {
+		t.Errorf("default limit = %d, want %d", got, defaultMaxMessageContentBytes)
+	}
+
+	t.Setenv("MAX_MESSAGE_CONTENT_BYTES", "1024")
+	if got := maxMessageContentBytes(); got != 1024 {
+		t.Errorf("limit with override = %d, want 1024", got)
+	}
+
+	t.Setenv("MAX_MESSAGE_CONTENT_BYTES", "not-a-number")
+	if got := maxMessageContentBytes(); got != defaultMaxMessageContentBytes {
+		t.Errorf("limit with invalid override = %d, want default %d", got, defaultMaxMessageContentBytes)
+	}
+}
+
+func TestValidateMessageContentSize(t *testing.T) {
+	t.Setenv("MAX_MESSAGE_CONTENT_BYTES", "10")
+
+	tests := []struct {
+		name    string
+		content string
+		wantOK  bool
+	}{
+		{"empty", "", true},
+		{"under limit", strings.Repeat("a", 9), true},
+		{"at limit", strings.Repeat("a", 10), true},
+		{"over limit", strings.Repeat("a", 11), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			got := validateMessageContentSize(w, tt.content)
+			if got != tt.wantOK {
+				t.Errorf("validateMessageContentSize(%d bytes) = %v, want %v", len(tt.content), got, tt.wantOK)
+			}
+			if !tt.wantOK && w.Code != 400 {
+				t.Errorf("over-limit content wrote status %d, want 400", w.Code)
+			}
+		})
+	}
+}
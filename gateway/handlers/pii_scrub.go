@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gateway/pkg/logger"
+)
+
+// Optional PII scrubbing for persisted message content, for operators who
+// must not store raw PII. Only the stored copy is affected: the response is
+// still streamed to the user unmodified in-session. Opt-in via SCRUB_PII=true.
+
+const scrubReplacement = "[REDACTED]"
+
+// defaultScrubPatterns covers the common cases: emails, phone numbers, and
+// credit-card-like digit sequences
+var defaultScrubPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\+?\d[\d\s\-().]{8,}\d`),
+	regexp.MustCompile(`\b(?:\d[ \-]?){13,16}\b`),
+}
+
+var (
+	scrubPatternsOnce sync.Once
+	scrubPatterns     []*regexp.Regexp
+)
+
+func scrubPIIEnabled() bool {
+	return os.Getenv("SCRUB_PII") == "true"
+}
+
+// loadScrubPatterns merges the defaults with SCRUB_PII_PATTERNS, a
+// semicolon-separated list of additional regexes; invalid entries are skipped
+// with a warning
+func loadScrubPatterns() []*regexp.Regexp {
+	scrubPatternsOnce.Do(func() {
+		scrubPatterns = append(scrubPatterns, defaultScrubPatterns...)
+
+		raw := os.Getenv("SCRUB_PII_PATTERNS")
+		if raw == "" {
+			return
+		}
+		for _, expr := range strings.Split(raw, ";") {
+			expr = strings.TrimSpace(expr)
+			if expr == "" {
+				continue
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				logger.GetDailyLogger().Warn("Invalid SCRUB_PII_PATTERNS entry %q: %v", expr, err)
+				continue
+			}
+			scrubPatterns = append(scrubPatterns, re)
+		}
+	})
+	return scrubPatterns
+}
+
+// scrubPII redacts configured PII patterns from content bound for storage.
+// Returns the content unchanged when scrubbing is disabled.
+func scrubPII(content string) string {
+	if !scrubPIIEnabled() {
+		return content
+	}
+	for _, re := range loadScrubPatterns() {
+		content = re.ReplaceAllString(content, scrubReplacement)
+	}
+	return content
+}
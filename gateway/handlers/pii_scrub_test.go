@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScrubPIIDisabledPassthrough(t *testing.T) {
+	t.Setenv("SCRUB_PII", "")
+
+	content := "Contact me at jane.doe@example.com or +1 415 555 0123"
+	if got := scrubPII(content); got != content {
+		t.Errorf("scrubPII with scrubbing disabled = %q, want unchanged", got)
+	}
+}
+
+func TestScrubPIIRedactsDefaults(t *testing.T) {
+	t.Setenv("SCRUB_PII", "true")
+
+	tests := []struct {
+		name    string
+		content string
+		leaked  string
+	}{
+		{"email", "Reach me at jane.doe@example.com please", "jane.doe@example.com"},
+		{"phone", "Call +1 415-555-0123 tomorrow", "415-555-0123"},
+		{"card number", "Card: 4242 4242 4242 4242 exp 12/27", "4242 4242 4242 4242"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scrubPII(tt.content)
+			if strings.Contains(got, tt.leaked) {
+				t.Errorf("scrubPII(%q) = %q, still contains PII", tt.content, got)
+			}
+			if !strings.Contains(got, scrubReplacement) {
+				t.Errorf("scrubPII(%q) = %q, missing %q", tt.content, got, scrubReplacement)
+			}
+		})
+	}
+
+	// Plain prose must come through untouched
+	benign := "What is the capital of France?"
+	if got := scrubPII(benign); got != benign {
+		t.Errorf("scrubPII(%q) = %q, want unchanged", benign, got)
+	}
+}
+
+func TestScrubPIIEnabledFlag(t *testing.T) {
+	t.Setenv("SCRUB_PII", "")
+	if scrubPIIEnabled() {
+		t.Error("scrubbing should be disabled by default")
+	}
+
+	t.Setenv("SCRUB_PII", "true")
+	if !scrubPIIEnabled() {
+		t.Error("SCRUB_PII=true should enable scrubbing")
+	}
+
+	t.Setenv("SCRUB_PII", "yes")
+	if scrubPIIEnabled() {
+		t.Error("only the exact value \"true\" enables scrubbing")
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gateway/middleware"
+	"gateway/models"
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Partial stream persistence for resumable streaming: while a completion is
+// generating, the captured assistant content is snapshotted to Redis every N
+// chunks under the caller-supplied request ID, so a client that disconnects
+// mid-generation can fetch what it missed instead of starting over.
+
+// resumeKeyPrefix namespaces persisted partial streams in Redis
+const resumeKeyPrefix = "stream_partial:"
+
+// Default persistence cadence and retention for partial stream snapshots
+const (
+	defaultResumePersistEveryChunks = 20
+	defaultResumeTTLSeconds         = 900
+)
+
+// resumeRequestIDPattern constrains caller-supplied request IDs to a safe
+// Redis key segment
+var resumeRequestIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// resumePersistEveryChunks returns how many chunks to buffer between
+// snapshots (RESUME_PERSIST_CHUNKS)
+func resumePersistEveryChunks() int {
+	if value := os.Getenv("RESUME_PERSIST_CHUNKS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultResumePersistEveryChunks
+}
+
+// resumeTTL returns how long partial snapshots are retained (RESUME_TTL_SECONDS)
+func resumeTTL() time.Duration {
+	if value := os.Getenv("RESUME_TTL_SECONDS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Second
+		}
+	}
+	return defaultResumeTTLSeconds * time.Second
+}
+
+// resumeKey scopes persisted partials to the owning user so one user's
+// request ID can't replay another user's stream
+func resumeKey(userID, requestID string) string {
+	return resumeKeyPrefix + userID + ":" + requestID
+}
+
+// persistPartialContent snapshots the assistant content generated so far.
+// Best-effort: Redis being down just disables resumability.
+func persistPartialContent(ctx context.Context, userID, requestID, content string) {
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+	if err := client.Set(ctx, resumeKey(userID, requestID), content, resumeTTL()).Err(); err != nil {
+		logger.GetDailyLogger().Warn("Failed to persist partial stream for request %s: %v", requestID, err)
+	}
+}
+
+// clearPartialContent removes the snapshot once the generation has completed
+// and the full message is persisted
+func clearPartialContent(ctx context.Context, userID, requestID string) {
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+	if err := client.Del(ctx, resumeKey(userID, requestID)).Err(); err != nil {
+		logger.GetDailyLogger().Warn("Failed to clear partial stream for request %s: %v", requestID, err)
+	}
+}
+
+// ResumeStreamHandler handles GET /v1/complete/resume?request_id=..., replaying
+// the persisted partial content for a generation the client lost its
+// connection to. The replay is the latest snapshot; while the generation is
+// still running server-side the client can poll again for newer content, and
+// once it completes the snapshot is gone and the full message is available
+// through the normal chat history.
+func ResumeStreamHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := rand.Intn(1000000)
+	ctx := r.Context()
+
+	user, userOk := middleware.GetFirebaseUserFromContext(ctx)
+	if !userOk || user == nil {
+		sendErrorResponseWithCode(w, nil, "Authentication required", errCodeAuth, clientID)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		flusher = noopFlusher{}
+	}
+
+	requestID := r.URL.Query().Get("request_id")
+	if !resumeRequestIDPattern.MatchString(requestID) {
+		sendErrorResponseWithCode(w, flusher, "Invalid or missing request_id", errCodeBadRequest, clientID)
+		return
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		sendErrorResponseWithCode(w, flusher, "Stream resumption is not available", errCodeInternal, clientID)
+		return
+	}
+
+	content, err := client.Get(ctx, resumeKey(user.UID, requestID)).Result()
+	if err == redisv9.Nil {
+		sendErrorResponseWithCode(w, flusher, "No resumable stream found for this request_id", errCodeBadRequest, clientID)
+		return
+	}
+	if err != nil {
+		logger.GetDailyLogger().Error("Error loading partial stream for request %s: %v", requestID, err)
+		sendErrorResponseWithCode(w, flusher, "Failed to load partial stream", errCodeInternal, clientID)
+		return
+	}
+
+	// Replay the snapshot using the same frame sequence as a live stream
+	for _, frame := range []models.Response{
+		{Type: "start", Timestamp: time.Now().Format(time.RFC3339)},
+		{Type: "chunk", Message: content},
+		{Type: "end", Timestamp: time.Now().Format(time.RFC3339)},
+	} {
+		msg, err := models.FormatSSEMessage(frame)
+		if err != nil {
+			logger.GetDailyLogger().Error("Error formatting resume frame for client %d: %v", clientID, err)
+			return
+		}
+		fmt.Fprint(w, msg)
+		flusher.Flush()
+	}
+
+	logger.GetDailyLogger().Info("Client %d: Replayed %d bytes of partial stream for request %s", clientID, len(content), requestID)
+}
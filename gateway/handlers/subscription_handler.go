@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
 	"time"
 
 	"gateway/config"
@@ -15,6 +18,10 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// discountCodePattern matches the promo code format LemonSqueezy accepts;
+// anything else is rejected before being forwarded to the payment service
+var discountCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
 // SubscriptionHandler handles subscription-related requests
 type SubscriptionHandler struct {
 	RedisClient   *redis.Client
@@ -28,6 +35,7 @@ type UserSubscriptionResponse struct {
 	Tier           config.SubscriptionTier `json:"tier"`
 	Status         string                  `json:"status"`
 	ExpiresAt      *time.Time              `json:"expires_at,omitempty"`
+	InGrace        bool                    `json:"in_grace,omitempty"`
 	RateLimit      config.RateLimitConfig  `json:"rate_limit"`
 	Usage          *UserUsageStats         `json:"usage,omitempty"`
 }
@@ -47,6 +55,14 @@ func NewSubscriptionHandler(redisClient *redis.Client, paymentAPIURL string) *Su
 	}
 }
 
+// setInternalToken adds the shared-secret header the payment service requires
+// on its internal (gateway-only) endpoints, when INTERNAL_API_TOKEN is set
+func setInternalToken(req *http.Request) {
+	if token := os.Getenv("INTERNAL_API_TOKEN"); token != "" {
+		req.Header.Set("X-Internal-Token", token)
+	}
+}
+
 // GetUserSubscription retrieves user subscription information
 func (h *SubscriptionHandler) GetUserSubscription(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by Firebase auth middleware)
@@ -82,7 +98,13 @@ func (h *SubscriptionHandler) getSubscriptionFromPaymentService(userID string) (
 	url := fmt.Sprintf("%s/api/subscription/status/%s", h.PaymentAPIURL, userID)
 
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return h.createDefaultSubscription(userID), nil
+	}
+	setInternalToken(req)
+
+	resp, err := client.Do(req)
 	if err != nil {
 		// Fallback to free tier if payment service is down
 		log.Printf("Payment service unavailable, defaulting to free tier for user %s: %v", userID, err)
@@ -116,9 +138,17 @@ func (h *SubscriptionHandler) getSubscriptionFromPaymentService(userID string) (
 	// Validate tier and status
 	tier := config.ValidateSubscriptionTier(paymentResponse.Tier)
 
-	// Check if subscription is expired or cancelled
+	// Check if subscription is expired or cancelled, honoring the grace window
+	inGrace := false
 	if paymentResponse.ExpiresAt != nil && time.Now().After(*paymentResponse.ExpiresAt) {
-		tier = config.TierFree
+		graceUntil := paymentResponse.ExpiresAt.AddDate(0, 0, subscriptionGraceDays())
+		if time.Now().Before(graceUntil) {
+			// Recently expired: keep the tier so a transient billing failure
+			// doesn't drop the user mid-session
+			inGrace = true
+		} else {
+			tier = config.TierFree
+		}
 	}
 
 	// Get rate limit config for the tier
@@ -133,10 +163,22 @@ func (h *SubscriptionHandler) getSubscriptionFromPaymentService(userID string) (
 		Tier:           tier,
 		Status:         paymentResponse.Status,
 		ExpiresAt:      paymentResponse.ExpiresAt,
+		InGrace:        inGrace,
 		RateLimit:      rateLimit,
 	}, nil
 }
 
+// subscriptionGraceDays returns the grace window in days during which an
+// expired subscription keeps its tier (SUBSCRIPTION_GRACE_DAYS, 0 disables it)
+func subscriptionGraceDays() int {
+	if value := os.Getenv("SUBSCRIPTION_GRACE_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			return days
+		}
+	}
+	return 0
+}
+
 // createDefaultSubscription creates a default free tier subscription
 func (h *SubscriptionHandler) createDefaultSubscription(userID string) *UserSubscriptionResponse {
 	rateLimit, _ := config.GetRateLimitConfig(config.TierFree)
@@ -191,7 +233,10 @@ func (h *SubscriptionHandler) CreateCheckoutSession(w http.ResponseWriter, r *ht
 	}
 
 	var request struct {
-		Tier string `json:"tier"`
+		Tier         string `json:"tier"`
+		DiscountCode string `json:"discount_code"`
+		RedirectURL  string `json:"redirect_url"`
+		ReceiptURL   string `json:"receipt_url"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -206,6 +251,12 @@ func (h *SubscriptionHandler) CreateCheckoutSession(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Validate discount code format before forwarding it to the payment service
+	if request.DiscountCode != "" && !discountCodePattern.MatchString(request.DiscountCode) {
+		sendAPIErrorResponse(w, "Invalid discount code format", http.StatusBadRequest)
+		return
+	}
+
 	// Get the Authorization header from the original request
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -219,6 +270,17 @@ func (h *SubscriptionHandler) CreateCheckoutSession(w http.ResponseWriter, r *ht
 	paymentRequest := map[string]interface{}{
 		"tier": string(tier),
 	}
+	if request.DiscountCode != "" {
+		paymentRequest["discount_code"] = request.DiscountCode
+	}
+	// Redirect/receipt overrides are validated by the payment service against
+	// its host allow-list; a rejection surfaces through the 400 passthrough
+	if request.RedirectURL != "" {
+		paymentRequest["redirect_url"] = request.RedirectURL
+	}
+	if request.ReceiptURL != "" {
+		paymentRequest["receipt_url"] = request.ReceiptURL
+	}
 
 	paymentData, _ := json.Marshal(paymentRequest)
 
@@ -259,6 +321,15 @@ func (h *SubscriptionHandler) CreateCheckoutSession(w http.ResponseWriter, r *ht
 			}
 			sendAPIErrorResponse(w, "Already subscribed to this tier", http.StatusConflict)
 		case http.StatusBadRequest:
+			// Pass through the payment service's message (e.g. an invalid
+			// discount code) instead of masking it with a generic error
+			var errorResponse struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil && errorResponse.Error != "" {
+				sendAPIErrorResponse(w, errorResponse.Error, http.StatusBadRequest)
+				return
+			}
 			sendAPIErrorResponse(w, "Invalid subscription request", http.StatusBadRequest)
 		case http.StatusUnauthorized:
 			sendAPIErrorResponse(w, "Authentication failed", http.StatusUnauthorized)
@@ -309,6 +380,7 @@ func (h *SubscriptionHandler) GetManagementURL(w http.ResponseWriter, r *http.Re
 
 	// Set headers
 	req.Header.Set("Authorization", authHeader)
+	setInternalToken(req)
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"gateway/pkg/iputil"
+	"gateway/pkg/logger"
+)
+
+// AdminAuthMiddleware restricts access to operational endpoints using a shared
+// admin key from the ADMIN_API_KEY environment variable. Requests must present
+// the key in the X-Admin-Key header; if no key is configured the endpoints are
+// disabled entirely.
+func AdminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.GetLogger("admin_auth")
+
+		adminKey := os.Getenv("ADMIN_API_KEY")
+		if adminKey == "" {
+			log.Warn("Admin endpoint requested but ADMIN_API_KEY is not configured")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Admin endpoints are disabled", "status": 403}`))
+			return
+		}
+
+		providedKey := r.Header.Get("X-Admin-Key")
+		if providedKey == "" || subtle.ConstantTimeCompare([]byte(providedKey), []byte(adminKey)) != 1 {
+			log.WarnWithFields("Invalid admin key", map[string]interface{}{
+				"client_ip": iputil.ClientIP(r),
+				"path":      r.URL.Path,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Invalid admin key", "status": 401}`))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminAccessMiddleware protects admin endpoints with either credential:
+// requests presenting an X-Admin-Key header go through the shared-key check,
+// everything else must carry a Firebase token whose custom claims include the
+// admin role. This keeps key-based ops tooling working while letting signed-in
+// admins use their own identity.
+func AdminAccessMiddleware(next http.Handler) http.Handler {
+	keyAuth := AdminAuthMiddleware(next)
+	tokenAuth := FirebaseAuthMiddleware(RequireAdmin(next))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Admin-Key") != "" {
+			keyAuth.ServeHTTP(w, r)
+			return
+		}
+		tokenAuth.ServeHTTP(w, r)
+	})
+}
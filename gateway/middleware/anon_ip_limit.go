@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/config"
+	"gateway/pkg/iputil"
+)
+
+// Anonymous users are keyed by UID, but a client can mint a fresh anonymous
+// Firebase UID at will. This file adds a second limit keyed by client IP so
+// rotating UIDs cannot sidestep the lifetime limit.
+
+const defaultAnonymousIPRequestsPerDay = 20
+
+// anonymousIPLimitEnabled reports whether the per-IP layer is active
+// (ANON_IP_LIMIT_ENABLED, default true)
+func anonymousIPLimitEnabled() bool {
+	return os.Getenv("ANON_IP_LIMIT_ENABLED") != "false"
+}
+
+// anonymousIPRequestsPerDay returns the daily allowance shared by all
+// anonymous users behind one IP (ANON_IP_REQUESTS_PER_DAY)
+func anonymousIPRequestsPerDay() int {
+	if v := os.Getenv("ANON_IP_REQUESTS_PER_DAY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultAnonymousIPRequestsPerDay
+}
+
+// CheckAnonymousIPLimit enforces the per-IP allowance for anonymous traffic.
+// It returns whether the request is allowed; both this and the UID-keyed
+// limit must pass for an anonymous request to proceed.
+func CheckAnonymousIPLimit(ctx context.Context, r *http.Request) (bool, error) {
+	if !anonymousIPLimitEnabled() {
+		return true, nil
+	}
+
+	key := "ip:" + iputil.BucketIPv6(iputil.ClientIP(r))
+
+	cleanupConfig, err := config.GetCleanupConfig()
+	if err != nil {
+		return false, err
+	}
+
+	usage, err := getUsageFromRedis(ctx, key, config.TierAnonymous, true)
+	if err != nil {
+		return false, err
+	}
+
+	// Per-IP buckets reset daily even though anonymous UIDs are
+	// lifetime-limited; a shared IP (NAT, campus) shouldn't be burned forever
+	now := time.Now()
+	if now.After(usage.ResetTime) {
+		usage.FreeRequestCount = 0
+		usage.MaxRequestCount = 0
+		usage.ResetTime = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	}
+
+	ttl := config.GetDurationFromSeconds(cleanupConfig.TTL)
+	if usage.FreeRequestCount+usage.MaxRequestCount >= anonymousIPRequestsPerDay() {
+		saveUsageToRedis(ctx, key, usage, ttl)
+		return false, nil
+	}
+
+	usage.FreeRequestCount++
+	if err := saveUsageToRedis(ctx, key, usage, ttl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
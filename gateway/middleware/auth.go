@@ -75,11 +75,19 @@ func initFirebase() error {
 	return nil
 }
 
+// allowAnonymousUsers reports whether anonymous Firebase users are served at
+// all (ALLOW_ANONYMOUS, default true). Locked-down deployments set it to
+// false to require a signed-up account.
+func allowAnonymousUsers() bool {
+	return os.Getenv("ALLOW_ANONYMOUS") != "false"
+}
+
 type firebaseContextKey string
 
 const (
-	FirebaseUserContextKey  firebaseContextKey = "firebase_user"
-	FirebaseTokenContextKey firebaseContextKey = "firebase_token"
+	FirebaseUserContextKey         firebaseContextKey = "firebase_user"
+	FirebaseTokenContextKey        firebaseContextKey = "firebase_token"
+	FirebaseDecodedTokenContextKey firebaseContextKey = "firebase_decoded_token"
 )
 
 // FirebaseAuthMiddleware validates Firebase ID tokens using the Firebase Admin SDK
@@ -91,7 +99,7 @@ func FirebaseAuthMiddleware(next http.Handler) http.Handler {
 		log.InfoWithFields("Request headers", map[string]interface{}{
 			"method":  r.Method,
 			"path":    r.URL.Path,
-			"headers": r.Header,
+			"headers": logger.RedactHeaders(r.Header),
 		})
 
 		// Initialize Firebase if not already done
@@ -129,9 +137,9 @@ func FirebaseAuthMiddleware(next http.Handler) http.Handler {
 		token, err := firebaseAuth.VerifyIDToken(context.Background(), idToken)
 		if err != nil {
 			log.WarnWithFields("Token validation failed", map[string]interface{}{
-				"error":         err.Error(),
-				"error_type":    fmt.Sprintf("%T", err),
-				"token_preview": idToken[:min(50, len(idToken))] + "...",
+				"error":        err.Error(),
+				"error_type":   fmt.Sprintf("%T", err),
+				"token_length": len(idToken),
 			})
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -139,16 +147,32 @@ func FirebaseAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Get user record from Firebase
-		userRecord, err := firebaseAuth.GetUser(context.Background(), token.UID)
-		if err != nil {
-			log.WarnWithFields("Failed to get user record", map[string]interface{}{
-				"error": err.Error(),
-				"uid":   token.UID,
+		// Get user record from the cache or Firebase
+		userRecord, cached := getCachedUserRecord(token.UID)
+		if !cached {
+			userRecord, err = firebaseAuth.GetUser(context.Background(), token.UID)
+			if err != nil {
+				invalidateCachedUserRecord(token.UID)
+				log.WarnWithFields("Failed to get user record", map[string]interface{}{
+					"error": err.Error(),
+					"uid":   token.UID,
+				})
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "User not found", "status": 401}`))
+				return
+			}
+			cacheUserRecord(token.UID, userRecord, token.Expires)
+		}
+
+		// Reject anonymous users when the deployment has disabled them
+		if IsAnonymousUser(userRecord) && !allowAnonymousUsers() {
+			log.WarnWithFields("Anonymous user rejected (ALLOW_ANONYMOUS=false)", map[string]interface{}{
+				"uid": userRecord.UID,
 			})
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "User not found", "status": 401}`))
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Sign in required: anonymous access is disabled", "status": 403}`))
 			return
 		}
 
@@ -164,15 +188,33 @@ func FirebaseAuthMiddleware(next http.Handler) http.Handler {
 			})
 		}
 
-		// Add the user and token to the request context
+		// Add the user and token to the request context. The decoded token is
+		// kept as well so downstream middleware can inspect custom claims.
 		ctx := context.WithValue(r.Context(), FirebaseUserContextKey, userRecord)
 		ctx = context.WithValue(ctx, FirebaseTokenContextKey, idToken)
+		ctx = context.WithValue(ctx, FirebaseDecodedTokenContextKey, token)
 
 		// Call the next handler with the updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// VerifyIDToken verifies a Firebase ID token and returns the corresponding
+// user record, for handlers that need to validate a second token (e.g. the
+// anonymous token during account migration)
+func VerifyIDToken(ctx context.Context, idToken string) (*auth.UserRecord, error) {
+	if err := initFirebase(); err != nil {
+		return nil, fmt.Errorf("failed to initialize Firebase: %w", err)
+	}
+
+	token, err := firebaseAuth.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired token: %w", err)
+	}
+
+	return firebaseAuth.GetUser(ctx, token.UID)
+}
+
 // GetFirebaseUserFromContext retrieves the authenticated user from the context
 func GetFirebaseUserFromContext(ctx context.Context) (*auth.UserRecord, bool) {
 	user, ok := ctx.Value(FirebaseUserContextKey).(*auth.UserRecord)
@@ -185,6 +227,43 @@ func GetFirebaseTokenFromContext(ctx context.Context) (string, bool) {
 	return token, ok
 }
 
+// GetDecodedTokenFromContext retrieves the decoded Firebase token (including
+// custom claims) from the context
+func GetDecodedTokenFromContext(ctx context.Context) (*auth.Token, bool) {
+	token, ok := ctx.Value(FirebaseDecodedTokenContextKey).(*auth.Token)
+	return token, ok
+}
+
+// RequireAdmin restricts an endpoint to users whose Firebase token carries the
+// "role": "admin" custom claim. It must run after FirebaseAuthMiddleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.GetLogger("admin_authorization")
+
+		token, ok := GetDecodedTokenFromContext(r.Context())
+		if !ok || token == nil {
+			log.Warn("No decoded token found in context")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Authentication required", "status": 401}`))
+			return
+		}
+
+		if role, _ := token.Claims["role"].(string); role != "admin" {
+			log.WarnWithFields("Admin authorization failed", map[string]interface{}{
+				"uid":  token.UID,
+				"path": r.URL.Path,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Admin access required", "status": 403}`))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // IsAnonymousUser checks if the Firebase user is anonymous
 func IsAnonymousUser(user *auth.UserRecord) bool {
 	return user.Email == ""
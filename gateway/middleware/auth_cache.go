@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// The GetUser call in FirebaseAuthMiddleware is a network round-trip to
+// Firebase on every request. Verified user records are cached briefly, keyed
+// by UID, so repeated requests from the same user within the window skip it.
+// Entries are only served after the request's token has been verified, and
+// never outlive the token itself.
+
+const defaultAuthCacheTTLSeconds = 300
+
+type cachedUserRecord struct {
+	record    *auth.UserRecord
+	expiresAt time.Time
+}
+
+var (
+	userRecordCache sync.Map // uid -> cachedUserRecord
+	authCacheHits   int64
+	authCacheMisses int64
+)
+
+// authCacheEnabled reports whether user record caching is active
+// (AUTH_CACHE_ENABLED, default true)
+func authCacheEnabled() bool {
+	return os.Getenv("AUTH_CACHE_ENABLED") != "false"
+}
+
+// authCacheTTL returns how long a verified user record may be reused
+// (AUTH_CACHE_TTL_SECONDS)
+func authCacheTTL() time.Duration {
+	if v := os.Getenv("AUTH_CACHE_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultAuthCacheTTLSeconds * time.Second
+}
+
+// getCachedUserRecord returns the cached record for a UID, if still fresh
+func getCachedUserRecord(uid string) (*auth.UserRecord, bool) {
+	if !authCacheEnabled() {
+		return nil, false
+	}
+
+	value, ok := userRecordCache.Load(uid)
+	if !ok {
+		atomic.AddInt64(&authCacheMisses, 1)
+		return nil, false
+	}
+
+	entry := value.(cachedUserRecord)
+	if time.Now().After(entry.expiresAt) {
+		userRecordCache.Delete(uid)
+		atomic.AddInt64(&authCacheMisses, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&authCacheHits, 1)
+	return entry.record, true
+}
+
+// cacheUserRecord stores a freshly fetched record. tokenExpiry is the token's
+// exp claim (unix seconds); the entry never outlives it.
+func cacheUserRecord(uid string, record *auth.UserRecord, tokenExpiry int64) {
+	if !authCacheEnabled() {
+		return
+	}
+
+	expiresAt := time.Now().Add(authCacheTTL())
+	if tokenExpiry > 0 {
+		if tokenEnd := time.Unix(tokenExpiry, 0); tokenEnd.Before(expiresAt) {
+			expiresAt = tokenEnd
+		}
+	}
+
+	userRecordCache.Store(uid, cachedUserRecord{record: record, expiresAt: expiresAt})
+}
+
+// invalidateCachedUserRecord drops a UID from the cache, e.g. after a failed
+// lookup so a stale record is never served
+func invalidateCachedUserRecord(uid string) {
+	userRecordCache.Delete(uid)
+}
+
+// GetAuthCacheStats reports cache effectiveness for the metrics endpoint
+func GetAuthCacheStats() map[string]interface{} {
+	hits := atomic.LoadInt64(&authCacheHits)
+	misses := atomic.LoadInt64(&authCacheMisses)
+
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+
+	return map[string]interface{}{
+		"enabled":  authCacheEnabled(),
+		"hits":     hits,
+		"misses":   misses,
+		"hit_rate": hitRate,
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+func TestAllowAnonymousUsers(t *testing.T) {
+	t.Setenv("ALLOW_ANONYMOUS", "")
+	if !allowAnonymousUsers() {
+		t.Error("anonymous users should be allowed by default")
+	}
+
+	t.Setenv("ALLOW_ANONYMOUS", "false")
+	if allowAnonymousUsers() {
+		t.Error("ALLOW_ANONYMOUS=false should disable anonymous users")
+	}
+
+	t.Setenv("ALLOW_ANONYMOUS", "true")
+	if !allowAnonymousUsers() {
+		t.Error("ALLOW_ANONYMOUS=true should allow anonymous users")
+	}
+}
+
+func TestIsAnonymousUser(t *testing.T) {
+	anonymous := &auth.UserRecord{UserInfo: &auth.UserInfo{UID: "anon-uid"}}
+	signedUp := &auth.UserRecord{UserInfo: &auth.UserInfo{UID: "uid", Email: "user@example.com"}}
+
+	if !IsAnonymousUser(anonymous) {
+		t.Error("user with empty email should be anonymous")
+	}
+	if IsAnonymousUser(signedUp) {
+		t.Error("user with an email should not be anonymous")
+	}
+}
+
+// unsignedIDToken mints the unsigned JWT the Firebase emulator mode accepts:
+// three segments, no real signature, issuer and audience matching the project
+func unsignedIDToken(t *testing.T, projectID, uid string, exp int64) string {
+	t.Helper()
+
+	encode := func(v map[string]interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal token segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	header := encode(map[string]interface{}{"alg": "none", "typ": "JWT"})
+	payload := encode(map[string]interface{}{
+		"iss":       "https://securetoken.google.com/" + projectID,
+		"aud":       projectID,
+		"sub":       uid,
+		"user_id":   uid,
+		"auth_time": time.Now().Add(-time.Minute).Unix(),
+		"iat":       time.Now().Add(-time.Minute).Unix(),
+		"exp":       exp,
+	})
+	return header + "." + payload + "."
+}
+
+func TestFirebaseAuthMiddlewareRejectsAnonymousWhenDisabled(t *testing.T) {
+	const projectID = "demo-test"
+	const uid = "anon-test-uid"
+
+	// A stand-in for the Firebase Auth emulator: the SDK resolves every
+	// account lookup against it, and a user without an email is anonymous
+	emulator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"identitytoolkit#GetAccountInfoResponse","users":[{"localId":"` + uid + `"}]}`))
+	}))
+	defer emulator.Close()
+
+	// Emulator mode makes the SDK accept unsigned tokens
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", strings.TrimPrefix(emulator.URL, "http://"))
+	t.Setenv("GOOGLE_CLOUD_PROJECT", projectID)
+	t.Setenv("ALLOW_ANONYMOUS", "false")
+	t.Setenv("AUTH_CACHE_ENABLED", "false")
+
+	// The Firebase singletons must be rebuilt against the emulator env
+	savedApp, savedAuth := firebaseApp, firebaseAuth
+	firebaseApp, firebaseAuth = nil, nil
+	t.Cleanup(func() { firebaseApp, firebaseAuth = savedApp, savedAuth })
+	t.Cleanup(func() { invalidateCachedUserRecord(uid) })
+
+	exp := time.Now().Add(time.Hour).Unix()
+
+	handler := FirebaseAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("anonymous user reached the protected handler with ALLOW_ANONYMOUS=false")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/chats", nil)
+	r.Header.Set("Authorization", "Bearer "+unsignedIDToken(t, projectID, uid, exp))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("anonymous user got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(w.Body.String(), "Sign in required") {
+		t.Errorf("response body = %q, want the sign-in-required error", w.Body.String())
+	}
+
+	// With the flag back at its default the same token is served
+	t.Setenv("ALLOW_ANONYMOUS", "")
+	served := false
+	handler = FirebaseAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/chats", nil)
+	r.Header.Set("Authorization", "Bearer "+unsignedIDToken(t, projectID, uid, exp))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || !served {
+		t.Errorf("anonymous user got status %d (served=%v), want to be served by default", w.Code, served)
+	}
+}
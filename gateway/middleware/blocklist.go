@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gateway/pkg/iputil"
+	"gateway/pkg/redis"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Manual blocklist for abusive users and IPs, maintained by ops through the
+// admin endpoints. Unlike the temporary suspicious-activity block this is
+// deliberate and (unless given an expiry) permanent, and it returns 403
+// rather than 429.
+
+const blocklistPrefix = "blocklist:"
+
+// Blocklist entry kinds
+const (
+	BlocklistKindUser = "user"
+	BlocklistKindIP   = "ip"
+)
+
+func blocklistKey(kind, id string) string {
+	return blocklistPrefix + kind + ":" + id
+}
+
+// ValidateBlocklistKind checks an entry kind from an admin request
+func ValidateBlocklistKind(kind string) error {
+	switch kind {
+	case BlocklistKindUser, BlocklistKindIP:
+		return nil
+	}
+	return fmt.Errorf("unknown blocklist kind: %s", kind)
+}
+
+// AddBlocklistEntry blocklists a user UID or IP, optionally expiring after
+// ttl (zero means no expiry). The stored value records when and why.
+func AddBlocklistEntry(ctx context.Context, kind, id, reason string, ttl time.Duration) error {
+	client := redis.GetClient()
+	if client == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	value := reason
+	if value == "" {
+		value = "blocked at " + time.Now().Format(time.RFC3339)
+	}
+	return client.Set(ctx, blocklistKey(kind, id), value, ttl).Err()
+}
+
+// RemoveBlocklistEntry removes a user UID or IP from the blocklist
+func RemoveBlocklistEntry(ctx context.Context, kind, id string) error {
+	client := redis.GetClient()
+	if client == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return client.Del(ctx, blocklistKey(kind, id)).Err()
+}
+
+// isBlocklisted checks the manual blocklist for the request's user and IP.
+// Fail-open: Redis errors don't block the request.
+func isBlocklisted(ctx context.Context, r *http.Request) bool {
+	client := redis.GetClient()
+	if client == nil {
+		return false
+	}
+
+	keys := []string{blocklistKey(BlocklistKindIP, iputil.ClientIP(r))}
+	if user, ok := GetFirebaseUserFromContext(ctx); ok && user != nil {
+		keys = append(keys, blocklistKey(BlocklistKindUser, user.UID))
+	}
+
+	for _, key := range keys {
+		if _, err := client.Get(ctx, key).Result(); err == nil {
+			return true
+		} else if err != redisv9.Nil {
+			continue
+		}
+	}
+	return false
+}
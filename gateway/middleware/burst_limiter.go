@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/config"
+)
+
+// Optional token-bucket layer on top of the fixed per-minute limit, so short
+// bursts of legitimate requests are allowed while the sustained rate stays
+// capped. Opt-in via BURST_ENABLED; the default remains the fixed window.
+
+// burstEnabled reports whether the token-bucket layer replaces the fixed
+// per-minute check (BURST_ENABLED=true)
+func burstEnabled() bool {
+	return os.Getenv("BURST_ENABLED") == "true"
+}
+
+// burstSize returns the bucket capacity: BURST_SIZE, or twice the tier's
+// per-minute limit by default
+func burstSize(tierConfig config.RateLimitConfig) float64 {
+	if value := os.Getenv("BURST_SIZE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return float64(parsed)
+		}
+	}
+	return float64(2 * tierConfig.RequestsPerMinute)
+}
+
+// burstRefillPerSecond returns the refill rate: BURST_REFILL_PER_MINUTE, or
+// the tier's per-minute limit by default, converted to tokens per second
+func burstRefillPerSecond(tierConfig config.RateLimitConfig) float64 {
+	refillPerMinute := float64(tierConfig.RequestsPerMinute)
+	if value := os.Getenv("BURST_REFILL_PER_MINUTE"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			refillPerMinute = float64(parsed)
+		}
+	}
+	return refillPerMinute / 60.0
+}
+
+// takeBurstToken refills the bucket from the elapsed time and consumes one
+// token. Returns whether a token was available and, when it wasn't, the time
+// at which the next token will be.
+func takeBurstToken(usage *DailyUsage, tierConfig config.RateLimitConfig, now time.Time) (bool, time.Time) {
+	size := burstSize(tierConfig)
+	refillRate := burstRefillPerSecond(tierConfig)
+
+	// A fresh bucket (zero timestamp) starts full
+	if usage.BurstLastRefill.IsZero() {
+		usage.BurstTokens = size
+	} else if elapsed := now.Sub(usage.BurstLastRefill).Seconds(); elapsed > 0 {
+		usage.BurstTokens += elapsed * refillRate
+		if usage.BurstTokens > size {
+			usage.BurstTokens = size
+		}
+	}
+	usage.BurstLastRefill = now
+
+	if usage.BurstTokens < 1 {
+		secondsUntilToken := (1 - usage.BurstTokens) / refillRate
+		return false, now.Add(time.Duration(secondsUntilToken * float64(time.Second)))
+	}
+
+	usage.BurstTokens--
+	return true, time.Time{}
+}
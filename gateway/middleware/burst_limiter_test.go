@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"gateway/config"
+)
+
+func TestTakeBurstTokenBurstThenThrottle(t *testing.T) {
+	t.Setenv("BURST_SIZE", "")
+	t.Setenv("BURST_REFILL_PER_MINUTE", "")
+
+	// Default bucket: capacity 2x requests_per_minute, refilled at the
+	// per-minute rate
+	tierConfig := config.RateLimitConfig{RequestsPerMinute: 5}
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	usage := &DailyUsage{}
+
+	// A fresh bucket allows an immediate burst of the full capacity
+	for i := 0; i < 10; i++ {
+		allowed, _ := takeBurstToken(usage, tierConfig, now)
+		if !allowed {
+			t.Fatalf("request %d of the initial burst was throttled, bucket should hold 10", i+1)
+		}
+	}
+
+	// The bucket is empty: the next request is throttled and told when a
+	// token will be available
+	allowed, nextToken := takeBurstToken(usage, tierConfig, now)
+	if allowed {
+		t.Fatal("request beyond the bucket capacity should be throttled")
+	}
+	if !nextToken.After(now) {
+		t.Errorf("next-token time %v should be after now %v", nextToken, now)
+	}
+
+	// Refill at 5 tokens/minute: 12 seconds buys exactly one token
+	later := now.Add(12 * time.Second)
+	allowed, _ = takeBurstToken(usage, tierConfig, later)
+	if !allowed {
+		t.Error("one token should have refilled after 12 seconds")
+	}
+	allowed, _ = takeBurstToken(usage, tierConfig, later)
+	if allowed {
+		t.Error("second request in the same instant should be throttled again")
+	}
+
+	// After a long idle period the bucket refills to capacity, not beyond
+	muchLater := later.Add(time.Hour)
+	takeBurstToken(usage, tierConfig, muchLater)
+	if usage.BurstTokens > 9 {
+		t.Errorf("bucket holds %.1f tokens after refill and one take, want at most 9", usage.BurstTokens)
+	}
+}
+
+func TestTakeBurstTokenEnvOverrides(t *testing.T) {
+	t.Setenv("BURST_SIZE", "3")
+	t.Setenv("BURST_REFILL_PER_MINUTE", "60")
+
+	tierConfig := config.RateLimitConfig{RequestsPerMinute: 100}
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	usage := &DailyUsage{}
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := takeBurstToken(usage, tierConfig, now); !allowed {
+			t.Fatalf("request %d should fit in the overridden bucket of 3", i+1)
+		}
+	}
+	if allowed, _ := takeBurstToken(usage, tierConfig, now); allowed {
+		t.Fatal("fourth request should exceed BURST_SIZE=3")
+	}
+
+	// 60 tokens/minute refills one token per second
+	if allowed, _ := takeBurstToken(usage, tierConfig, now.Add(time.Second)); !allowed {
+		t.Error("one token should have refilled after a second at 60/minute")
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+)
+
+// chatCreateKeyPrefix namespaces per-user chat-creation counters in Redis
+const chatCreateKeyPrefix = "chat_create:"
+
+// Default chat-creation budget: distinct from completion limits, it only
+// bounds how fast brand-new chats can be minted
+const (
+	defaultChatCreateLimit         = 5
+	defaultChatCreateWindowSeconds = 60
+)
+
+// chatCreateLimit returns the max new chats per window (CHAT_CREATE_LIMIT,
+// 0 disables the limiter)
+func chatCreateLimit() int {
+	if value := os.Getenv("CHAT_CREATE_LIMIT"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultChatCreateLimit
+}
+
+// chatCreateWindow returns the limiter window (CHAT_CREATE_WINDOW_SECONDS)
+func chatCreateWindow() time.Duration {
+	if value := os.Getenv("CHAT_CREATE_WINDOW_SECONDS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Second
+		}
+	}
+	return defaultChatCreateWindowSeconds * time.Second
+}
+
+// CheckChatCreationLimit counts a chat-creation attempt for the user and
+// reports whether another new chat may be created in the current window, so a
+// runaway client retrying failed first-completions can't mint chats
+// unbounded. Fails open on Redis errors like the other limiters.
+func CheckChatCreationLimit(ctx context.Context, userID string) bool {
+	limit := chatCreateLimit()
+	if limit <= 0 {
+		return true
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		return true
+	}
+
+	key := chatCreateKeyPrefix + userID
+	count, err := client.Incr(ctx, key).Result()
+	if err != nil {
+		logger.GetDailyLogger().Warn("Chat creation limit check failed for %s: %v", userID, err)
+		return true
+	}
+	if count == 1 {
+		if err := client.Expire(ctx, key, chatCreateWindow()).Err(); err != nil {
+			logger.GetDailyLogger().Warn("Failed to set TTL on chat creation counter for %s: %v", userID, err)
+		}
+	}
+
+	return count <= int64(limit)
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gateway/config"
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Per-user daily spend tracking. Request counts bound abuse, but model costs
+// vary wildly per request, so operators can additionally cap estimated spend
+// per user per day. Cost is estimated from provider-reported token usage and
+// a configured $/1K-token price per model; the cap is tier-dependent and
+// unlimited by default.
+
+const (
+	dailyCostKeyPrefix = "cost:daily:"
+
+	// Cost keys are date-scoped, so a generous TTL just keeps yesterday's
+	// keys from lingering forever
+	dailyCostTTL = 48 * time.Hour
+)
+
+var (
+	modelCostsOnce     sync.Once
+	modelCosts         map[string]float64
+	defaultCostPer1K   float64
+	costTrackerEnabled bool
+)
+
+// loadModelCosts parses MODEL_COSTS_JSON, a JSON object mapping model name to
+// $/1K tokens (e.g. {"gemini-2.0-flash": 0.4}), plus DEFAULT_COST_PER_1K_USD
+// for models not listed. With neither set, no cost is recorded.
+func loadModelCosts() {
+	modelCostsOnce.Do(func() {
+		if raw := os.Getenv("MODEL_COSTS_JSON"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &modelCosts); err != nil {
+				logger.GetDailyLogger().Warn("Invalid MODEL_COSTS_JSON, ignoring: %v", err)
+				modelCosts = nil
+			}
+		}
+		if raw := os.Getenv("DEFAULT_COST_PER_1K_USD"); raw != "" {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil && parsed >= 0 {
+				defaultCostPer1K = parsed
+			}
+		}
+		costTrackerEnabled = len(modelCosts) > 0 || defaultCostPer1K > 0
+	})
+}
+
+// costPer1KTokens returns the configured price for a model, falling back to
+// the default price for unlisted models
+func costPer1KTokens(model string) float64 {
+	loadModelCosts()
+	if price, ok := modelCosts[model]; ok {
+		return price
+	}
+	return defaultCostPer1K
+}
+
+// secondsUntilTomorrow reports how long until the daily cost window rolls
+// over; cost keys are UTC-dated, so midnight UTC is the reset point
+func secondsUntilTomorrow() int {
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	seconds := int(time.Until(midnight).Seconds() + 0.5)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+func dailyCostKey(userID string) string {
+	return dailyCostKeyPrefix + userID + ":" + time.Now().UTC().Format("2006-01-02")
+}
+
+// RecordTokenCost accumulates the estimated cost of a completed request for
+// the user's daily total. Fail-open: recording errors are logged, not returned,
+// so a Redis hiccup never breaks a finished stream.
+func RecordTokenCost(ctx context.Context, userID, model string, totalTokens int) {
+	if userID == "" || totalTokens <= 0 {
+		return
+	}
+	price := costPer1KTokens(model)
+	if price <= 0 {
+		return
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+
+	cost := float64(totalTokens) / 1000 * price
+	key := dailyCostKey(userID)
+	if err := client.IncrByFloat(ctx, key, cost).Err(); err != nil {
+		logger.GetDailyLogger().Warn("Failed to record token cost for %s: %v", userID, err)
+		return
+	}
+	client.Expire(ctx, key, dailyCostTTL)
+}
+
+// GetDailySpend returns the user's estimated spend for today in USD
+func GetDailySpend(ctx context.Context, userID string) (float64, error) {
+	client := redis.GetClient()
+	if client == nil || userID == "" {
+		return 0, nil
+	}
+
+	value, err := client.Get(ctx, dailyCostKey(userID)).Result()
+	if err == redisv9.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	spend, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return spend, nil
+}
+
+// CheckDailyCostCap reports whether the user is under their tier's daily
+// spend cap, along with the current spend and the cap itself (0 = unlimited).
+// Fail-open: lookup errors allow the request.
+func CheckDailyCostCap(ctx context.Context, userID string, tier config.SubscriptionTier) (bool, float64, float64) {
+	loadModelCosts()
+	if !costTrackerEnabled {
+		return true, 0, 0
+	}
+
+	tierConfig, err := config.GetRateLimitConfig(tier)
+	if err != nil {
+		return true, 0, 0
+	}
+	capUSD := tierConfig.EffectiveMaxDailyCostUSD()
+	if capUSD <= 0 {
+		return true, 0, 0
+	}
+
+	spend, err := GetDailySpend(ctx, userID)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Failed to read daily spend for %s: %v", userID, err)
+		return true, 0, capUSD
+	}
+	return spend < capUSD, spend, capUSD
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/pkg/iputil"
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+)
+
+// Per-endpoint limits protect the cheap data endpoints (DynamoDB writes)
+// separately from the expensive completion quota
+const (
+	endpointKeyPrefix                = "api:"
+	defaultEndpointRequestsPerMinute = 60
+)
+
+// endpointRequestsPerMinute returns the per-minute limit for an endpoint,
+// honoring API_RATE_LIMIT_<NAME> (e.g. API_RATE_LIMIT_CREATE_MESSAGE) with an
+// API_RATE_LIMIT_DEFAULT fallback
+func endpointRequestsPerMinute(name string) int {
+	if value := os.Getenv("API_RATE_LIMIT_" + strings.ToUpper(name)); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	if value := os.Getenv("API_RATE_LIMIT_DEFAULT"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultEndpointRequestsPerMinute
+}
+
+// EndpointRateLimitMiddleware throttles a single endpoint per user with a
+// fixed one-minute window, using keys like "api:create_message:UID". It is
+// fail-open: if Redis is unavailable the request goes through.
+func EndpointRateLimitMiddleware(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := redis.GetClient()
+		if client == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Key on the authenticated user when available, the client IP otherwise
+		id := ""
+		if user, ok := GetFirebaseUserFromContext(r.Context()); ok && user != nil {
+			id = user.UID
+		} else {
+			id = iputil.ClientIP(r)
+		}
+
+		ctx := r.Context()
+		key := endpointKeyPrefix + name + ":" + id
+
+		count, err := client.Incr(ctx, key).Result()
+		if err != nil {
+			logger.GetDailyLogger().Warn("Endpoint rate limit check failed for %s: %v", key, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if count == 1 {
+			client.Expire(ctx, key, time.Minute)
+		}
+
+		if int(count) > endpointRequestsPerMinute(name) {
+			retryAfter := 60
+			if ttl, err := client.TTL(ctx, key).Result(); err == nil && ttl > 0 {
+				retryAfter = int(ttl.Seconds())
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Too many requests to this endpoint. Please try again later.",
+				"type":  "endpoint_rate_limit_exceeded",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
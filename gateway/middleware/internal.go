@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"gateway/pkg/iputil"
+	"gateway/pkg/logger"
+)
+
+// InternalAuthMiddleware restricts access to service-to-service endpoints
+// using a shared secret from the INTERNAL_API_SECRET environment variable.
+// Callers (e.g. the classifier) must present the secret in the
+// X-Internal-Secret header; if no secret is configured the endpoints are
+// disabled entirely.
+func InternalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := logger.GetLogger("internal_auth")
+
+		secret := os.Getenv("INTERNAL_API_SECRET")
+		if secret == "" {
+			log.Warn("Internal endpoint requested but INTERNAL_API_SECRET is not configured")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "Internal endpoints are disabled", "status": 403}`))
+			return
+		}
+
+		providedSecret := r.Header.Get("X-Internal-Secret")
+		if providedSecret == "" || subtle.ConstantTimeCompare([]byte(providedSecret), []byte(secret)) != 1 {
+			log.WarnWithFields("Invalid internal secret", map[string]interface{}{
+				"client_ip": iputil.ClientIP(r),
+				"path":      r.URL.Path,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Invalid internal secret", "status": 401}`))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
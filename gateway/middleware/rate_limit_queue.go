@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"gateway/config"
+)
+
+// Optional bounded wait at the per-minute boundary, so an interactive user who
+// overruns the limit by one request is held briefly instead of 429'd — the
+// minute often rolls over within the wait. Opt-in via RATE_LIMIT_QUEUE_MS;
+// only per-minute denials are queued, daily and lifetime limits still fail
+// immediately.
+
+// defaultRateLimitQueueMS disables queueing unless explicitly configured
+const defaultRateLimitQueueMS = 0
+
+// rateLimitQueuePollInterval caps how often a queued request re-checks, so
+// held requests don't hammer Redis
+const rateLimitQueuePollInterval = 250 * time.Millisecond
+
+// rateLimitQueueWait returns the maximum time a per-minute-limited request may
+// be held (RATE_LIMIT_QUEUE_MS, 0 disables)
+func rateLimitQueueWait() time.Duration {
+	if value := os.Getenv("RATE_LIMIT_QUEUE_MS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Millisecond
+		}
+	}
+	return defaultRateLimitQueueMS * time.Millisecond
+}
+
+// waitForPerMinuteSlot holds a per-minute-denied request for up to the
+// configured wait, re-checking as the window reset approaches. Returns the
+// last check's result: allowed if a slot freed up, still denied otherwise.
+// The caller's request context bounds the wait, so a disconnecting client
+// doesn't leave a goroutine parked.
+func waitForPerMinuteSlot(ctx context.Context, key string, tier config.SubscriptionTier, isAnonymous bool, denied RateLimitResult) RateLimitResult {
+	wait := rateLimitQueueWait()
+	if wait <= 0 {
+		return denied
+	}
+
+	deadline := time.Now().Add(wait)
+	result := denied
+
+	for time.Now().Before(deadline) {
+		// Sleep until the poll interval, the window reset, or the deadline,
+		// whichever is soonest
+		pause := rateLimitQueuePollInterval
+		if !result.ResetTime.IsZero() {
+			if untilReset := time.Until(result.ResetTime); untilReset > 0 && untilReset < pause {
+				pause = untilReset
+			}
+		}
+		if remaining := time.Until(deadline); remaining < pause {
+			pause = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(pause):
+		}
+
+		rechecked, err := CheckAndIncrementUsage(ctx, key, tier, isAnonymous)
+		if err != nil {
+			// Keep the original denial; the middleware's own fail-open path
+			// only applies to the first check
+			return result
+		}
+		result = rechecked
+		if result.Allowed || result.Reason != ReasonPerMinuteLimit {
+			return result
+		}
+	}
+
+	return result
+}
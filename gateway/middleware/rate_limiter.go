@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"gateway/config"
+	"gateway/pkg/iputil"
 	"gateway/pkg/logger"
 	"gateway/pkg/redis"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	redisv9 "github.com/redis/go-redis/v9"
@@ -27,6 +29,10 @@ type DailyUsage struct {
 	MinuteRequestCount int       `json:"minute_request_count"` // Number of requests made in current minute
 	MinuteResetTime    time.Time `json:"minute_reset_time"`    // When the minute limit resets
 
+	// Token bucket state (only used when BURST_ENABLED is set)
+	BurstTokens     float64   `json:"burst_tokens,omitempty"`      // Tokens currently in the bucket
+	BurstLastRefill time.Time `json:"burst_last_refill,omitempty"` // Last time the bucket was refilled
+
 	// Suspicious activity tracking
 	RequestTimestamps []time.Time `json:"request_timestamps"` // Recent request timestamps for burst detection
 	BlockedUntil      time.Time   `json:"blocked_until"`      // When the user/IP is blocked until
@@ -55,7 +61,9 @@ type RateLimitConfig struct {
 type contextKey string
 
 const (
-	RequestTypeContextKey contextKey = "request_type"
+	RequestTypeContextKey    contextKey = "request_type"
+	ModeDowngradedContextKey contextKey = "mode_downgraded"
+	UserTierContextKey       contextKey = "user_tier"
 )
 
 // RequestType represents whether a request is pro or free
@@ -66,6 +74,27 @@ const (
 	FreeRequest RequestType = "free"
 )
 
+// RateLimitReason identifies why a rate limit check denied a request
+type RateLimitReason string
+
+const (
+	ReasonAllowed          RateLimitReason = "allowed"
+	ReasonSuspiciousBlock  RateLimitReason = "suspicious_activity_block"
+	ReasonPerMinuteLimit   RateLimitReason = "per_minute_limit"
+	ReasonLifetimeExceeded RateLimitReason = "lifetime_limit_exceeded"
+	ReasonDailyExceeded    RateLimitReason = "daily_limit_exceeded"
+)
+
+// RateLimitResult describes the outcome of a rate limit check so callers can
+// produce distinct responses per denial cause
+type RateLimitResult struct {
+	RequestType RequestType
+	Allowed     bool
+	Reason      RateLimitReason
+	ResetTime   time.Time // when the limiting counter next resets (zero when unknown)
+	Downgraded  bool      // pro allotment ran out and the request fell back to free
+}
+
 // Redis key prefixes
 const (
 	rateLimitPrefix = "rate_limit:"
@@ -113,15 +142,23 @@ func getUsageFromRedis(ctx context.Context, key string, tier config.Subscription
 		return nil, fmt.Errorf("failed to get usage from redis: %w", err)
 	}
 
-	// Unmarshal JSON data
+	return decodeUsage([]byte(data), tier, isAnonymous)
+}
+
+// decodeUsage unmarshals a stored usage record, preserving the stored tier
+// information: callers like stats collection don't know the real tier and
+// would otherwise stamp every record with their guess. Only records written
+// before these fields existed get the caller's values filled in.
+func decodeUsage(data []byte, tier config.SubscriptionTier, isAnonymous bool) (*DailyUsage, error) {
 	var usage DailyUsage
-	if err := json.Unmarshal([]byte(data), &usage); err != nil {
+	if err := json.Unmarshal(data, &usage); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal usage data: %w", err)
 	}
 
-	// Update tier information in case it changed
-	usage.UserTier = tier
-	usage.IsAnonymous = isAnonymous
+	if usage.UserTier == "" {
+		usage.UserTier = tier
+		usage.IsAnonymous = isAnonymous
+	}
 
 	return &usage, nil
 }
@@ -150,37 +187,46 @@ func saveUsageToRedis(ctx context.Context, key string, usage *DailyUsage, ttl ti
 	return nil
 }
 
-// CheckAndIncrementUsage checks if a request should be considered pro or free and increments usage
-func CheckAndIncrementUsage(ctx context.Context, key string, tier config.SubscriptionTier, isAnonymous bool) (RequestType, bool, error) {
+// CheckAndIncrementUsage checks if a request should be considered pro or free
+// and increments usage, reporting the denial reason and reset time on failure
+func CheckAndIncrementUsage(ctx context.Context, key string, tier config.SubscriptionTier, isAnonymous bool) (RateLimitResult, error) {
+	denied := RateLimitResult{RequestType: FreeRequest, Allowed: false}
+
 	// Get tier configuration
 	tierConfig, err := config.GetRateLimitConfig(tier)
 	if err != nil {
-		return FreeRequest, false, fmt.Errorf("failed to get tier config: %w", err)
+		return denied, fmt.Errorf("failed to get tier config: %w", err)
 	}
 
 	// Get suspicious activity configuration
 	suspiciousConfig, err := config.GetSuspiciousActivityConfig()
 	if err != nil {
-		return FreeRequest, false, fmt.Errorf("failed to get suspicious activity config: %w", err)
+		return denied, fmt.Errorf("failed to get suspicious activity config: %w", err)
 	}
 
 	// Get cleanup configuration
 	cleanupConfig, err := config.GetCleanupConfig()
 	if err != nil {
-		return FreeRequest, false, fmt.Errorf("failed to get cleanup config: %w", err)
+		return denied, fmt.Errorf("failed to get cleanup config: %w", err)
 	}
 
 	// Get current usage from Redis
 	usage, err := getUsageFromRedis(ctx, key, tier, isAnonymous)
 	if err != nil {
-		return FreeRequest, false, err
+		return denied, err
 	}
 
 	now := time.Now()
 
+	// Reconcile the record with the user's current tier before any counters
+	// are consulted, so a mid-day upgrade takes effect immediately
+	applyTierTransition(usage, key, tier)
+
 	// Check if user/IP is currently blocked
 	if usage.IsBlocked && now.Before(usage.BlockedUntil) {
-		return FreeRequest, false, nil // Request is blocked
+		denied.Reason = ReasonSuspiciousBlock
+		denied.ResetTime = usage.BlockedUntil
+		return denied, nil
 	}
 
 	// If block period has expired, reset blocking
@@ -208,20 +254,32 @@ func CheckAndIncrementUsage(ctx context.Context, key string, tier config.Subscri
 		usage.MinuteResetTime = nextMinute
 	}
 
-	// Check per-minute rate limit first
-	if usage.MinuteRequestCount >= tierConfig.RequestsPerMinute {
+	// Check per-minute rate limit first (token bucket when burst mode is on)
+	if burstEnabled() {
+		if ok, nextToken := takeBurstToken(usage, tierConfig, now); !ok {
+			// Save current state to Redis
+			saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
+			denied.Reason = ReasonPerMinuteLimit
+			denied.ResetTime = nextToken
+			return denied, nil
+		}
+	} else if usage.MinuteRequestCount >= tierConfig.RequestsPerMinute {
 		// Save current state to Redis
 		saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
-		return FreeRequest, false, nil // Request is rate limited by per-minute limit
+		denied.Reason = ReasonPerMinuteLimit
+		denied.ResetTime = usage.MinuteResetTime
+		return denied, nil
 	}
 
 	// For anonymous users with lifetime limits, check if they've exceeded their total limit
 	if isAnonymous && tierConfig.LifetimeLimit {
 		totalRequests := usage.FreeRequestCount + usage.MaxRequestCount
-		if totalRequests >= tierConfig.RequestsPerDay {
+		if totalRequests >= tierConfig.EffectiveLifetimeRequests() {
 			// Save current state to Redis
 			saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
-			return FreeRequest, false, nil // Request is blocked - lifetime limit exceeded
+			// Lifetime limits have no reset time
+			denied.Reason = ReasonLifetimeExceeded
+			return denied, nil
 		}
 	}
 
@@ -248,16 +306,22 @@ func CheckAndIncrementUsage(ctx context.Context, key string, tier config.Subscri
 			// Save updated usage to Redis
 			err = saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
 			if err != nil {
-				return FreeRequest, false, err
+				return denied, err
 			}
 
-			return FreeRequest, false, nil // Request is blocked due to suspicious activity
+			denied.Reason = ReasonSuspiciousBlock
+			denied.ResetTime = usage.BlockedUntil
+			return denied, nil
 		}
 	}
 
 	// Determine request type based on tier and current usage
 	requestType := determineRequestType(usage, tierConfig)
 
+	// A tier with a pro allotment that now serves a free request has silently
+	// downgraded; surface that so the UI can tell the user
+	downgraded := !isAnonymous && tierConfig.MaxRequests > 0 && requestType == FreeRequest
+
 	// Increment appropriate counters
 	if requestType == MaxRequest {
 		usage.MaxRequestCount++
@@ -269,10 +333,183 @@ func CheckAndIncrementUsage(ctx context.Context, key string, tier config.Subscri
 	// Save updated usage to Redis
 	err = saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
 	if err != nil {
-		return FreeRequest, false, err
+		return denied, err
+	}
+
+	return RateLimitResult{RequestType: requestType, Allowed: true, Reason: ReasonAllowed, Downgraded: downgraded}, nil
+}
+
+// retryAfterSeconds computes the soonest time at which a 429'd caller could
+// succeed again: the suspicious-activity block expiry, the per-minute window
+// reset, or the daily reset, whichever applies first. Returns 0 when no
+// reset time can be determined (e.g. lifetime limits or Redis errors).
+func retryAfterSeconds(ctx context.Context, key string, tier config.SubscriptionTier, isAnonymous bool) int {
+	usage, err := getUsageFromRedis(ctx, key, tier, isAnonymous)
+	if err != nil {
+		return 0
+	}
+
+	tierConfig, err := config.GetRateLimitConfig(tier)
+	if err != nil {
+		return 0
+	}
+
+	return retryAfterFromUsage(usage, tierConfig, time.Now())
+}
+
+// retryAfterFromUsage computes the Retry-After seconds for a usage record at
+// a given instant: the soonest of the block expiry, the per-minute window
+// reset and the daily reset, never less than one second
+func retryAfterFromUsage(usage *DailyUsage, tierConfig config.RateLimitConfig, now time.Time) int {
+	var candidates []time.Time
+	if usage.IsBlocked && usage.BlockedUntil.After(now) {
+		candidates = append(candidates, usage.BlockedUntil)
+	}
+	if usage.MinuteRequestCount >= tierConfig.RequestsPerMinute && usage.MinuteResetTime.After(now) {
+		candidates = append(candidates, usage.MinuteResetTime)
+	}
+	if tierConfig.DailyReset && usage.ResetTime.After(now) {
+		candidates = append(candidates, usage.ResetTime)
+	}
+	if len(candidates) == 0 {
+		return 0
+	}
+
+	soonest := candidates[0]
+	for _, t := range candidates[1:] {
+		if t.Before(soonest) {
+			soonest = t
+		}
+	}
+
+	seconds := int(soonest.Sub(now).Seconds() + 0.5)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// totalRefunds counts requests refunded after post-stream reconciliation
+var totalRefunds int64
+
+// RefundUsage decrements the counter that CheckAndIncrementUsage charged, for
+// requests that turned out not to be billable (e.g. an empty provider response)
+func RefundUsage(ctx context.Context, key string, tier config.SubscriptionTier, isAnonymous bool, requestType RequestType) error {
+	cleanupConfig, err := config.GetCleanupConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get cleanup config: %w", err)
+	}
+
+	usage, err := getUsageFromRedis(ctx, key, tier, isAnonymous)
+	if err != nil {
+		return err
+	}
+
+	if requestType == MaxRequest && usage.MaxRequestCount > 0 {
+		usage.MaxRequestCount--
+	} else if requestType == FreeRequest && usage.FreeRequestCount > 0 {
+		usage.FreeRequestCount--
+	} else {
+		return nil // Nothing to refund
+	}
+
+	err = saveUsageToRedis(ctx, key, usage, config.GetDurationFromSeconds(cleanupConfig.TTL))
+	if err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&totalRefunds, 1)
+	return nil
+}
+
+// GetRefundCount returns the number of refunds issued since startup
+func GetRefundCount() int64 {
+	return atomic.LoadInt64(&totalRefunds)
+}
+
+// MigrateUsage merges the usage recorded under fromKey into toKey and deletes
+// fromKey, so an anonymous user's consumption carries over on sign-up. A
+// missing fromKey is a no-op, which keeps the migration idempotent.
+func MigrateUsage(ctx context.Context, fromKey, toKey string, tier config.SubscriptionTier) error {
+	client := redis.GetClient()
+	if client == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+
+	fromUsageKey := rateLimitPrefix + usageKeyPrefix + fromKey
+
+	data, err := client.Get(ctx, fromUsageKey).Result()
+	if err != nil {
+		if err == redisv9.Nil {
+			return nil // Nothing to migrate
+		}
+		return fmt.Errorf("failed to get usage from redis: %w", err)
+	}
+
+	var fromUsage DailyUsage
+	if err := json.Unmarshal([]byte(data), &fromUsage); err != nil {
+		return fmt.Errorf("failed to unmarshal usage data: %w", err)
+	}
+
+	toUsage, err := getUsageFromRedis(ctx, toKey, tier, false)
+	if err != nil {
+		return err
+	}
+
+	toUsage.FreeRequestCount += fromUsage.FreeRequestCount
+	toUsage.MaxRequestCount += fromUsage.MaxRequestCount
+
+	cleanupConfig, err := config.GetCleanupConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get cleanup config: %w", err)
+	}
+
+	if err := saveUsageToRedis(ctx, toKey, toUsage, config.GetDurationFromSeconds(cleanupConfig.TTL)); err != nil {
+		return err
+	}
+
+	if err := client.Del(ctx, fromUsageKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete migrated usage key: %w", err)
 	}
 
-	return requestType, true, nil
+	return nil
+}
+
+// tierRank orders tiers so transitions can be classified as upgrades or
+// downgrades; unknown tiers rank lowest
+func tierRank(tier config.SubscriptionTier) int {
+	switch tier {
+	case config.TierPro:
+		return 3
+	case config.TierPlus:
+		return 2
+	case config.TierFree:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// applyTierTransition reconciles a usage record written under a different tier
+// with the tier the user holds now. On an upgrade the pro counter is reset so
+// the user immediately gets the full allotment they paid for; on a downgrade
+// the counters carry over so switching tiers can't mint extra requests. The
+// record's tier is updated either way so determineRequestType sees consistent
+// state.
+func applyTierTransition(usage *DailyUsage, key string, tier config.SubscriptionTier) {
+	if usage.IsAnonymous || usage.UserTier == "" || usage.UserTier == tier {
+		return
+	}
+
+	if tierRank(tier) > tierRank(usage.UserTier) {
+		logger.GetDailyLogger().Info("Tier upgrade detected for %s (%s -> %s), resetting pro counter from %d",
+			key, usage.UserTier, tier, usage.MaxRequestCount)
+		usage.MaxRequestCount = 0
+	} else {
+		logger.GetDailyLogger().Info("Tier downgrade detected for %s (%s -> %s), keeping counters",
+			key, usage.UserTier, tier)
+	}
+	usage.UserTier = tier
 }
 
 // determineRequestType determines if a request should be pro or free based on tier and usage
@@ -378,35 +615,44 @@ func GetBlockingInfo(ctx context.Context, key string, tier config.SubscriptionTi
 	return isBlocked, usage.BlockedUntil, recentRequests, nil
 }
 
-// GetUsageStats returns current usage statistics from Redis
+// GetUsageStats returns current usage statistics from Redis, including a
+// per-tier breakdown read from the tier stored on each usage record
 func GetUsageStats(ctx context.Context) (map[string]interface{}, error) {
 	client := redis.GetClient()
 	if client == nil {
 		return nil, fmt.Errorf("redis client not initialized")
 	}
 
-	// Get all usage keys
 	pattern := rateLimitPrefix + usageKeyPrefix + "*"
-	keys, err := client.Keys(ctx, pattern).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get usage keys: %w", err)
-	}
 
-	// Count blocked users and recent requests
+	activeUsers := 0
 	blockedUsers := 0
 	totalRecentRequests := 0
+	usersByTier := make(map[string]int)
 	now := time.Now()
 
-	for _, key := range keys {
-		// Remove prefix to get the actual key
-		actualKey := key[len(rateLimitPrefix+usageKeyPrefix):]
-
-		// We need tier info to get usage, so we'll use a default tier for stats
-		usage, err := getUsageFromRedis(ctx, actualKey, config.TierFree, false)
+	// SCAN instead of KEYS so stats collection doesn't stall Redis
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := client.Get(ctx, iter.Val()).Result()
 		if err != nil {
 			continue // Skip this key if we can't read it
 		}
 
+		// Read the record as stored rather than via getUsageFromRedis, which
+		// would overwrite the stored tier with whatever the caller passes
+		var usage DailyUsage
+		if err := json.Unmarshal([]byte(data), &usage); err != nil {
+			continue
+		}
+
+		activeUsers++
+		tier := string(usage.UserTier)
+		if tier == "" {
+			tier = string(config.TierFree)
+		}
+		usersByTier[tier]++
+
 		if usage.IsBlocked && now.Before(usage.BlockedUntil) {
 			blockedUsers++
 		}
@@ -419,11 +665,15 @@ func GetUsageStats(ctx context.Context) (map[string]interface{}, error) {
 			}
 		}
 	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan usage keys: %w", err)
+	}
 
 	return map[string]interface{}{
-		"active_users":    len(keys),
+		"active_users":    activeUsers,
 		"blocked_users":   blockedUsers,
 		"recent_requests": totalRecentRequests,
+		"users_by_tier":   usersByTier,
 		"storage_backend": "redis",
 		"config_source":   "tier_based",
 	}, nil
@@ -478,24 +728,72 @@ func RateLimitMiddleware(next http.Handler, legacyConfig RateLimitConfig) http.H
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// Manual blocklist first: a deliberate ops block returns 403, distinct
+		// from the temporary suspicious-activity 429 below
+		if isBlocklisted(ctx, r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Access blocked. Contact support if you believe this is a mistake.",
+				"type":  "blocklisted",
+			})
+			return
+		}
+
 		// Create rate limit key based on user ID (from auth) or IP address
 		key := getRateLimitKey(r)
 
 		// Get user tier from context (includes subscription service lookup)
 		tier, isAnonymous := GetUserTierFromContext(ctx, r)
 
+		// Hard daily spend cap, checked before the usage counters are
+		// incremented so a capped request costs nothing
+		if user, ok := GetFirebaseUserFromContext(ctx); ok && user != nil {
+			if allowed, spent, capUSD := CheckDailyCostCap(ctx, user.UID, tier); !allowed {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", strconv.Itoa(secondsUntilTomorrow()))
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": fmt.Sprintf("Daily spend limit reached ($%.2f of $%.2f). Please try again tomorrow.", spent, capUSD),
+					"type":  "cost_cap_exceeded",
+				})
+				return
+			}
+		}
+
 		// Check and increment usage, get request type
-		requestType, allowed, err := CheckAndIncrementUsage(ctx, key, tier, isAnonymous)
+		result, err := CheckAndIncrementUsage(ctx, key, tier, isAnonymous)
 		if err != nil {
 			// Log error but don't block request
 			logger.GetDailyLogger().Error("Rate limit check failed", "error", err, "key", key)
 			// Continue with request as FreeRequest if Redis fails
-			requestType = FreeRequest
-			allowed = true
+			result = RateLimitResult{RequestType: FreeRequest, Allowed: true, Reason: ReasonAllowed}
+		}
+
+		// Optionally hold a per-minute-limited request briefly instead of
+		// 429ing right away; the minute often rolls over within the wait
+		if !result.Allowed && result.Reason == ReasonPerMinuteLimit {
+			result = waitForPerMinuteSlot(ctx, key, tier, isAnonymous, result)
 		}
 
-		// If request is blocked, return 429
-		if !allowed {
+		// Second layer for anonymous users: a per-IP allowance, so minting
+		// fresh anonymous UIDs doesn't bypass the lifetime limit
+		if result.Allowed && isAnonymous {
+			ipAllowed, ipErr := CheckAnonymousIPLimit(ctx, r)
+			if ipErr != nil {
+				// Same fail-open policy as the UID check above
+				logger.GetDailyLogger().Error("Anonymous IP limit check failed for %s: %v", key, ipErr)
+			} else if !ipAllowed {
+				// The per-IP allowance resets daily
+				result.Allowed = false
+				result.Reason = ReasonDailyExceeded
+			}
+		}
+
+		requestType := result.RequestType
+
+		// If request is blocked, return 429 with a cause-specific message
+		if !result.Allowed {
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-RateLimit-Blocked", "true")
 
@@ -507,16 +805,50 @@ func RateLimitMiddleware(next http.Handler, legacyConfig RateLimitConfig) http.H
 			w.Header().Set("X-RateLimit-Remaining", "0")
 			w.Header().Set("X-Request-Type", string(requestType))
 
+			// Tell well-behaved clients when retrying could succeed, preferring
+			// the reset time the check itself reported
+			retryAfter := 0
+			if !result.ResetTime.IsZero() && result.ResetTime.After(time.Now()) {
+				retryAfter = int(time.Until(result.ResetTime).Seconds() + 0.5)
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+			} else {
+				retryAfter = retryAfterSeconds(ctx, key, tier, isAnonymous)
+			}
+			if retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			}
+
+			var message string
+			switch result.Reason {
+			case ReasonSuspiciousBlock:
+				message = "Your account is temporarily blocked due to suspicious activity. Please try again later."
+			case ReasonPerMinuteLimit:
+				message = "Too many requests per minute. Please slow down."
+			case ReasonLifetimeExceeded:
+				message = "You've used all your free requests. Sign up to continue."
+			case ReasonDailyExceeded:
+				message = "Daily request limit reached. Please try again tomorrow."
+			default:
+				message = "Rate limit exceeded. Please try again later."
+			}
+
 			w.WriteHeader(http.StatusTooManyRequests)
 			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Rate limit exceeded. Please try again later.",
-				"type":  "rate_limit_exceeded",
+				"error":  message,
+				"type":   "rate_limit_exceeded",
+				"reason": string(result.Reason),
 			})
 			return
 		}
 
-		// Add request type to context for downstream handlers
+		// Add request type and resolved tier to context for downstream handlers
 		ctx = context.WithValue(ctx, RequestTypeContextKey, requestType)
+		ctx = context.WithValue(ctx, UserTierContextKey, tier)
+		if result.Downgraded {
+			ctx = context.WithValue(ctx, ModeDowngradedContextKey, true)
+		}
 
 		// Get usage info for response headers
 		freeCount, maxCount, resetTime, _, _, err := GetUsageInfo(ctx, key, tier, isAnonymous)
@@ -525,19 +857,22 @@ func RateLimitMiddleware(next http.Handler, legacyConfig RateLimitConfig) http.H
 
 			// Calculate remaining requests based on request type
 			var remaining int
+			unlimited := false
 			if requestType == MaxRequest {
 				remaining = max(0, tierConfig.MaxRequests-maxCount)
+			} else if config.IsUnlimited(tierConfig.FreeRequests) {
+				unlimited = true
 			} else {
-				if config.IsUnlimited(tierConfig.FreeRequests) {
-					remaining = -1 // Unlimited
-				} else {
-					remaining = max(0, tierConfig.FreeRequests-freeCount)
-				}
+				remaining = max(0, tierConfig.FreeRequests-freeCount)
 			}
 
 			// Set response headers
 			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(tierConfig.RequestsPerDay))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if unlimited {
+				w.Header().Set("X-RateLimit-Remaining", config.UnlimitedHeaderValue)
+			} else {
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			}
 			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
 			w.Header().Set("X-Request-Type", string(requestType))
 
@@ -567,6 +902,13 @@ func GetRequestTypeFromContext(ctx context.Context) (RequestType, bool) {
 	return FreeRequest, false
 }
 
+// GetModeDowngradedFromContext reports whether the rate limiter downgraded
+// this request to free mode after the pro allotment ran out
+func GetModeDowngradedFromContext(ctx context.Context) bool {
+	downgraded, ok := ctx.Value(ModeDowngradedContextKey).(bool)
+	return ok && downgraded
+}
+
 // getRateLimitKey generates a rate limit key based on user ID or IP
 func getRateLimitKey(r *http.Request) string {
 	// Try to get user ID from context first
@@ -577,8 +919,8 @@ func getRateLimitKey(r *http.Request) string {
 		return "user:" + user.UID
 	}
 
-	// Fallback to IP address
-	return "ip:" + r.RemoteAddr
+	// Fallback to the client IP (proxy-aware, not the peer address)
+	return "ip:" + iputil.ClientIP(r)
 }
 
 // Legacy support functions for backward compatibility
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"gateway/config"
+)
+
+func TestRetryAfterFromUsage(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	tierConfig := config.RateLimitConfig{
+		RequestsPerMinute: 10,
+		DailyReset:        true,
+	}
+
+	tests := []struct {
+		name  string
+		usage *DailyUsage
+		want  int
+	}{
+		{
+			name:  "no pending limits",
+			usage: &DailyUsage{},
+			want:  0,
+		},
+		{
+			name: "blocked until",
+			usage: &DailyUsage{
+				IsBlocked:    true,
+				BlockedUntil: now.Add(90 * time.Second),
+			},
+			want: 90,
+		},
+		{
+			name: "per-minute window exhausted",
+			usage: &DailyUsage{
+				MinuteRequestCount: 10,
+				MinuteResetTime:    now.Add(30 * time.Second),
+			},
+			want: 30,
+		},
+		{
+			name: "daily reset pending",
+			usage: &DailyUsage{
+				ResetTime: now.Add(2 * time.Hour),
+			},
+			want: 7200,
+		},
+		{
+			name: "soonest of several candidates wins",
+			usage: &DailyUsage{
+				IsBlocked:          true,
+				BlockedUntil:       now.Add(10 * time.Minute),
+				MinuteRequestCount: 10,
+				MinuteResetTime:    now.Add(45 * time.Second),
+				ResetTime:          now.Add(6 * time.Hour),
+			},
+			want: 45,
+		},
+		{
+			name: "imminent reset rounds up to one second",
+			usage: &DailyUsage{
+				MinuteRequestCount: 10,
+				MinuteResetTime:    now.Add(100 * time.Millisecond),
+			},
+			want: 1,
+		},
+		{
+			name: "expired block ignored",
+			usage: &DailyUsage{
+				IsBlocked:    true,
+				BlockedUntil: now.Add(-time.Minute),
+			},
+			want: 0,
+		},
+		{
+			name: "minute window not exhausted",
+			usage: &DailyUsage{
+				MinuteRequestCount: 3,
+				MinuteResetTime:    now.Add(30 * time.Second),
+			},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfterFromUsage(tt.usage, tierConfig, now); got != tt.want {
+				t.Errorf("retryAfterFromUsage = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfterFromUsageNoDailyReset(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	usage := &DailyUsage{ResetTime: now.Add(time.Hour)}
+	cfg := config.RateLimitConfig{RequestsPerMinute: 10, DailyReset: false}
+
+	if got := retryAfterFromUsage(usage, cfg, now); got != 0 {
+		t.Errorf("lifetime-limited tier reported retry-after %d, want 0", got)
+	}
+}
+
+func TestDecodeUsagePreservesStoredTier(t *testing.T) {
+	// A record written for a pro user must not be restamped by a caller that
+	// guesses free (e.g. stats collection scanning all keys)
+	stored := []byte(`{"free_request_count":3,"user_tier":"pro","is_anonymous":false}`)
+	usage, err := decodeUsage(stored, config.TierFree, true)
+	if err != nil {
+		t.Fatalf("decodeUsage: %v", err)
+	}
+	if usage.UserTier != config.TierPro {
+		t.Errorf("UserTier = %q, caller's guess overwrote the stored tier", usage.UserTier)
+	}
+	if usage.IsAnonymous {
+		t.Error("IsAnonymous = true, caller's guess overwrote the stored flag")
+	}
+	if usage.FreeRequestCount != 3 {
+		t.Errorf("FreeRequestCount = %d, want 3", usage.FreeRequestCount)
+	}
+}
+
+func TestDecodeUsageStampsLegacyRecords(t *testing.T) {
+	// Records written before the tier fields existed carry an empty tier and
+	// get the caller's values filled in
+	stored := []byte(`{"free_request_count":1}`)
+	usage, err := decodeUsage(stored, config.TierPlus, false)
+	if err != nil {
+		t.Fatalf("decodeUsage: %v", err)
+	}
+	if usage.UserTier != config.TierPlus {
+		t.Errorf("UserTier = %q, want the caller's tier for legacy records", usage.UserTier)
+	}
+}
+
+func TestDecodeUsageRejectsMalformedData(t *testing.T) {
+	if _, err := decodeUsage([]byte("not-json"), config.TierFree, false); err == nil {
+		t.Error("decodeUsage should fail on malformed data")
+	}
+}
+
+func TestApplyTierTransition(t *testing.T) {
+	tests := []struct {
+		name          string
+		usage         DailyUsage
+		newTier       config.SubscriptionTier
+		wantTier      config.SubscriptionTier
+		wantMaxCount  int
+		wantFreeCount int
+	}{
+		{
+			name:          "upgrade resets the pro counter",
+			usage:         DailyUsage{UserTier: config.TierFree, MaxRequestCount: 42, FreeRequestCount: 10},
+			newTier:       config.TierPro,
+			wantTier:      config.TierPro,
+			wantMaxCount:  0,
+			wantFreeCount: 10,
+		},
+		{
+			name:          "downgrade keeps the counters",
+			usage:         DailyUsage{UserTier: config.TierPro, MaxRequestCount: 42, FreeRequestCount: 10},
+			newTier:       config.TierFree,
+			wantTier:      config.TierFree,
+			wantMaxCount:  42,
+			wantFreeCount: 10,
+		},
+		{
+			name:         "same tier is a no-op",
+			usage:        DailyUsage{UserTier: config.TierPlus, MaxRequestCount: 7},
+			newTier:      config.TierPlus,
+			wantTier:     config.TierPlus,
+			wantMaxCount: 7,
+		},
+		{
+			name:         "anonymous records are never transitioned",
+			usage:        DailyUsage{UserTier: config.TierAnonymous, IsAnonymous: true, MaxRequestCount: 3},
+			newTier:      config.TierPro,
+			wantTier:     config.TierAnonymous,
+			wantMaxCount: 3,
+		},
+		{
+			name:         "legacy record without a tier is left alone",
+			usage:        DailyUsage{MaxRequestCount: 5},
+			newTier:      config.TierPro,
+			wantTier:     "",
+			wantMaxCount: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := tt.usage
+			applyTierTransition(&usage, "user:test", tt.newTier)
+			if usage.UserTier != tt.wantTier {
+				t.Errorf("UserTier = %q, want %q", usage.UserTier, tt.wantTier)
+			}
+			if usage.MaxRequestCount != tt.wantMaxCount {
+				t.Errorf("MaxRequestCount = %d, want %d", usage.MaxRequestCount, tt.wantMaxCount)
+			}
+			if usage.FreeRequestCount != tt.wantFreeCount {
+				t.Errorf("FreeRequestCount = %d, want %d", usage.FreeRequestCount, tt.wantFreeCount)
+			}
+		})
+	}
+}
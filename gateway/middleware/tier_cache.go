@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gateway/config"
+	"gateway/pkg/logger"
+	"gateway/pkg/redis"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// Resolving a user's tier hits the payment service on every rate-limited
+// request. The resolved tier is cached briefly in Redis keyed by UID so the
+// hot path usually skips that round-trip. The key lives in the shared Redis
+// so the payment service can delete it when a webhook changes the
+// subscription, making upgrades take effect before the TTL expires.
+
+// tierCachePrefix namespaces cached tiers in Redis; the payment service
+// deletes these keys on subscription changes, so keep the two in sync
+const tierCachePrefix = "tier_cache:"
+
+const defaultTierCacheTTLSeconds = 60
+
+var (
+	tierCacheHits   int64
+	tierCacheMisses int64
+)
+
+// tierCacheTTL returns how long a resolved tier may be reused
+// (TIER_CACHE_TTL_SECONDS, 0 disables caching)
+func tierCacheTTL() time.Duration {
+	if value := os.Getenv("TIER_CACHE_TTL_SECONDS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return time.Duration(parsed) * time.Second
+		}
+	}
+	return defaultTierCacheTTLSeconds * time.Second
+}
+
+// getCachedTier returns the cached tier for a UID, if present and fresh
+func getCachedTier(ctx context.Context, uid string) (config.SubscriptionTier, bool) {
+	if tierCacheTTL() <= 0 {
+		return config.TierFree, false
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		return config.TierFree, false
+	}
+
+	value, err := client.Get(ctx, tierCachePrefix+uid).Result()
+	if err != nil {
+		if err != redisv9.Nil {
+			logger.GetDailyLogger().Warn("Tier cache read failed for %s: %v", uid, err)
+		}
+		atomic.AddInt64(&tierCacheMisses, 1)
+		return config.TierFree, false
+	}
+
+	atomic.AddInt64(&tierCacheHits, 1)
+	return config.ValidateSubscriptionTier(value), true
+}
+
+// cacheTier stores a freshly resolved tier, best-effort
+func cacheTier(ctx context.Context, uid string, tier config.SubscriptionTier) {
+	ttl := tierCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+
+	if err := client.Set(ctx, tierCachePrefix+uid, string(tier), ttl).Err(); err != nil {
+		logger.GetDailyLogger().Warn("Tier cache write failed for %s: %v", uid, err)
+	}
+}
+
+// InvalidateTierCache drops a UID's cached tier so the next request resolves
+// it fresh, e.g. after a subscription change notification
+func InvalidateTierCache(ctx context.Context, uid string) {
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+
+	if err := client.Del(ctx, tierCachePrefix+uid).Err(); err != nil {
+		logger.GetDailyLogger().Warn("Tier cache invalidation failed for %s: %v", uid, err)
+	}
+}
+
+// GetTierCacheStats reports cache effectiveness for the metrics endpoint
+func GetTierCacheStats() map[string]interface{} {
+	hits := atomic.LoadInt64(&tierCacheHits)
+	misses := atomic.LoadInt64(&tierCacheMisses)
+
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+
+	return map[string]interface{}{
+		"enabled":  tierCacheTTL() > 0,
+		"hits":     hits,
+		"misses":   misses,
+		"hit_rate": hitRate,
+	}
+}
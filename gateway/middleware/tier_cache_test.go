@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gateway/config"
+)
+
+func TestTierCacheTTL(t *testing.T) {
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "")
+	if got := tierCacheTTL(); got != defaultTierCacheTTLSeconds*time.Second {
+		t.Errorf("default TTL = %v, want %ds", got, defaultTierCacheTTLSeconds)
+	}
+
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "300")
+	if got := tierCacheTTL(); got != 300*time.Second {
+		t.Errorf("TTL with override = %v, want 300s", got)
+	}
+
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "0")
+	if got := tierCacheTTL(); got != 0 {
+		t.Errorf("TTL with 0 = %v, want 0 (caching disabled)", got)
+	}
+
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "forever")
+	if got := tierCacheTTL(); got != defaultTierCacheTTLSeconds*time.Second {
+		t.Errorf("TTL with invalid override = %v, want the default", got)
+	}
+}
+
+func TestTierCacheFailsOpenWithoutRedis(t *testing.T) {
+	// Without an initialized Redis client every operation must degrade to a
+	// miss or a no-op rather than fail the request
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "")
+
+	ctx := context.Background()
+	if _, ok := getCachedTier(ctx, "uid-1"); ok {
+		t.Error("getCachedTier should miss without Redis")
+	}
+	cacheTier(ctx, "uid-1", config.TierPro)
+	InvalidateTierCache(ctx, "uid-1")
+
+	// Disabled caching short-circuits before Redis is consulted
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "0")
+	if _, ok := getCachedTier(ctx, "uid-1"); ok {
+		t.Error("getCachedTier should miss when caching is disabled")
+	}
+}
+
+func TestGetTierCacheStats(t *testing.T) {
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "")
+
+	stats := GetTierCacheStats()
+	for _, key := range []string{"enabled", "hits", "misses", "hit_rate"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("stats missing %q", key)
+		}
+	}
+	if enabled, _ := stats["enabled"].(bool); !enabled {
+		t.Error("stats should report caching enabled at the default TTL")
+	}
+
+	t.Setenv("TIER_CACHE_TTL_SECONDS", "0")
+	stats = GetTierCacheStats()
+	if enabled, _ := stats["enabled"].(bool); enabled {
+		t.Error("stats should report caching disabled when the TTL is 0")
+	}
+}
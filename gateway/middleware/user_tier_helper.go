@@ -39,6 +39,12 @@ func GetUserTierFromSubscriptionService(ctx context.Context, userID string, auth
 	req.Header.Set("Authorization", authToken)
 	req.Header.Set("Content-Type", "application/json")
 
+	// Internal endpoints on the payment service require the shared token
+	// when INTERNAL_API_TOKEN is configured on both sides
+	if token := os.Getenv("INTERNAL_API_TOKEN"); token != "" {
+		req.Header.Set("X-Internal-Token", token)
+	}
+
 	// Make the request
 	resp, err := client.Do(req)
 	if err != nil {
@@ -75,6 +81,13 @@ func GetUserTierFromSubscriptionService(ctx context.Context, userID string, auth
 	}
 }
 
+// GetTierFromRequestContext returns the tier the rate limiter resolved for
+// this request, avoiding a second subscription-service lookup downstream
+func GetTierFromRequestContext(ctx context.Context) (config.SubscriptionTier, bool) {
+	tier, ok := ctx.Value(UserTierContextKey).(config.SubscriptionTier)
+	return tier, ok
+}
+
 // GetUserTierFromContext determines the user's tier from context with subscription service lookup
 func GetUserTierFromContext(ctx context.Context, r *http.Request) (config.SubscriptionTier, bool) {
 	// Get user from context
@@ -89,10 +102,15 @@ func GetUserTierFromContext(ctx context.Context, r *http.Request) (config.Subscr
 		return config.TierAnonymous, true
 	}
 
-	// For authenticated users, try to get tier from subscription service
+	// For authenticated users, try the cache first, then the subscription
+	// service, populating the cache on a successful lookup
 	authHeader := r.Header.Get("Authorization")
 	if authHeader != "" {
+		if tier, ok := getCachedTier(ctx, user.UID); ok {
+			return tier, false
+		}
 		if tier, err := GetUserTierFromSubscriptionService(ctx, user.UID, authHeader); err == nil {
+			cacheTier(ctx, user.UID, tier)
 			return tier, false
 		}
 	}
@@ -9,10 +9,58 @@ import (
 type Response struct {
 	Message   string `json:"message,omitempty"` // Holds content for "chunk" type, or full message for others
 	Content   string `json:"content,omitempty"` // Kept for potential frontend compatibility, can be removed if not used
-	Type      string `json:"type"`              // "start", "chunk", "end", "error"
+	Type      string `json:"type"`              // "meta", "start", "chunk", "tool_call", "end", "error"
 	Timestamp string `json:"timestamp,omitempty"`
 	UserID    string `json:"user_id,omitempty"`
 	Model     string `json:"model,omitempty"`
+
+	// Classifier metadata, only populated for "meta" frames
+	Category              string             `json:"category,omitempty"`
+	Confidence            float64            `json:"confidence,omitempty"`
+	CategoryProbabilities map[string]float64 `json:"category_probabilities,omitempty"`
+
+	// Tool-call deltas, only populated for "tool_call" frames
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+
+	// Stable machine-readable code, only populated for "error" frames so
+	// clients can branch on it instead of string-matching the message
+	ErrorCode string `json:"error_code,omitempty"`
+
+	// Normalized stop reason ("stop", "length", "content_filter", "error"),
+	// only populated for "end" frames
+	FinishReason string `json:"finish_reason,omitempty"`
+
+	// Populated on "start" frames when the pro allotment just ran out and
+	// the request is being served by the free tier
+	ModeDowngraded  bool   `json:"mode_downgraded,omitempty"`
+	DowngradeNotice string `json:"downgrade_notice,omitempty"`
+}
+
+// Tool describes a function the model may call, using the OpenAI tool schema
+type Tool struct {
+	Type     string       `json:"type"` // Currently always "function"
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the function declaration inside a Tool
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ToolCallDelta is a streamed fragment of a tool call from the model
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction carries the (possibly partial) function name and arguments
+type ToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 type ChatMessage struct {
@@ -30,6 +78,18 @@ type RequestBody struct {
 	WorkspaceInstructions string        `json:"workspace_instructions,omitempty"`
 	ChatID                string        `json:"chat_id,omitempty"`    // Optional: if provided, use existing chat; if not, create new
 	MessageID             string        `json:"message_id,omitempty"` // Optional: for message updates
+	Tools                 []Tool        `json:"tools,omitempty"`      // Optional: OpenAI-schema tool declarations
+	ToolChoice            interface{}   `json:"tool_choice,omitempty"`
+	Seed                  *int          `json:"seed,omitempty"`             // Optional: for reproducible generations
+	DisableThinking       bool          `json:"disable_thinking,omitempty"` // Optional: suppress reasoning output for latency-sensitive callers
+	RequestID             string        `json:"request_id,omitempty"`       // Optional: enables resuming this stream via /v1/complete/resume
+	ContextWindow         int           `json:"context_window,omitempty"`   // Optional: history messages to include, clamped to the tier's maximum
+	ResponseFormat        *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat requests a structured output mode from the model
+type ResponseFormat struct {
+	Type string `json:"type"` // Currently only "json_object" is supported
 }
 
 // GenerateResponse creates a new response with the given parameters
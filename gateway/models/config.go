@@ -1,7 +1,10 @@
 package models
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -77,6 +80,65 @@ func (c *SystemPromptConfig) SetModelSystemPrompt(model, prompt string) {
 	c.ModelSpecificPrompts[model] = prompt
 }
 
+// maxSystemPromptFileBytes caps individual prompt files so a stray large file
+// can't balloon every request's token usage
+const maxSystemPromptFileBytes = 64 * 1024
+
+// LoadSystemPromptsFromDir loads per-provider system prompts from the
+// directory named by SYSTEM_PROMPT_DIR. Each <provider>.txt file (gemini.txt,
+// groq.txt, ...) overrides that provider's prompt; providers without a file
+// fall back to the built-in default. Files must be non-empty and under the
+// size cap, and any invalid file aborts the load with the previous prompts
+// left untouched. Returns the providers loaded. A no-op when the variable is
+// unset.
+func (c *SystemPromptConfig) LoadSystemPromptsFromDir() ([]string, error) {
+	dir := os.Getenv("SYSTEM_PROMPT_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SYSTEM_PROMPT_DIR %q: %w", dir, err)
+	}
+
+	prompts := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read system prompt file %q: %w", path, err)
+		}
+		if len(data) > maxSystemPromptFileBytes {
+			return nil, fmt.Errorf("system prompt file %q exceeds the %d byte limit", path, maxSystemPromptFileBytes)
+		}
+
+		prompt := strings.TrimSpace(string(data))
+		if prompt == "" {
+			return nil, fmt.Errorf("system prompt file %q is empty", path)
+		}
+
+		provider := strings.TrimSuffix(entry.Name(), ".txt")
+		prompts[provider] = prompt
+	}
+
+	// All files validated; swap the override set in one shot so providers
+	// whose file was removed revert to the built-in default
+	c.mux.Lock()
+	c.ModelSpecificPrompts = prompts
+	c.mux.Unlock()
+
+	loaded := make([]string, 0, len(prompts))
+	for provider := range prompts {
+		loaded = append(loaded, provider)
+	}
+	return loaded, nil
+}
+
 // LoadSystemPromptsFromEnv loads system prompts from environment variables
 func (c *SystemPromptConfig) LoadSystemPromptsFromEnv() {
 	// Load global system prompt from environment variable if available
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+)
+
+// maxPromptVarLen caps substituted values so a hostile display name can't
+// balloon or restructure the system prompt
+const maxPromptVarLen = 64
+
+// PromptTemplateVars holds the per-request values substituted into system
+// prompt templates
+type PromptTemplateVars struct {
+	UserDisplayName string
+	CurrentDate     string
+	Tier            string
+}
+
+// sanitizePromptVar strips characters that could alter the prompt structure
+// (braces, newlines) and caps the value length before substitution
+func sanitizePromptVar(value string) string {
+	replacer := strings.NewReplacer(
+		"{", "",
+		"}", "",
+		"\n", " ",
+		"\r", " ",
+	)
+	value = strings.TrimSpace(replacer.Replace(value))
+
+	if len(value) > maxPromptVarLen {
+		value = value[:maxPromptVarLen]
+	}
+	return value
+}
+
+// RenderPromptTemplate substitutes the supported template variables into a
+// system prompt. Variables with empty values are removed from the output.
+func RenderPromptTemplate(prompt string, vars PromptTemplateVars) string {
+	replacer := strings.NewReplacer(
+		"{{user_display_name}}", sanitizePromptVar(vars.UserDisplayName),
+		"{{current_date}}", sanitizePromptVar(vars.CurrentDate),
+		"{{tier}}", sanitizePromptVar(vars.Tier),
+	)
+	return replacer.Replace(prompt)
+}
+
+// AnyPromptContains reports whether any configured system prompt references
+// the given placeholder, so callers can skip computing expensive values
+func (c *SystemPromptConfig) AnyPromptContains(placeholder string) bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	if strings.Contains(c.GlobalSystemPrompt, placeholder) {
+		return true
+	}
+	for _, prompt := range c.ModelSpecificPrompts {
+		if strings.Contains(prompt, placeholder) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// Package iputil resolves the real client IP for requests that arrive through
+// a load balancer or reverse proxy. Forwarding headers are only honoured when
+// the immediate peer is listed in TRUSTED_PROXIES, because otherwise the
+// client controls them and could spoof any address.
+package iputil
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TrustedProxies parses TRUSTED_PROXIES, a comma-separated list of CIDRs (or
+// bare IPs) identifying the proxies that sit in front of this service
+func TrustedProxies() []*net.IPNet {
+	raw := os.Getenv("TRUSTED_PROXIES")
+	if raw == "" {
+		return nil
+	}
+
+	networks := make([]*net.IPNet, 0)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+		// Allow bare IPs as single-host networks
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return networks
+}
+
+// IsTrustedProxy reports whether addr belongs to one of the configured proxy
+// networks
+func IsTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies() {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the caller's IP address. When the immediate peer is a
+// trusted proxy, the rightmost X-Forwarded-For entry is used since that is
+// the value the proxy itself appended, falling back to X-Real-IP. Headers
+// from untrusted peers are ignored.
+func ClientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+
+	if !IsTrustedProxy(peer) {
+		return peer
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		candidate := strings.TrimSpace(parts[len(parts)-1])
+		if net.ParseIP(candidate) != nil {
+			return candidate
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if net.ParseIP(realIP) != nil {
+			return realIP
+		}
+	}
+
+	return peer
+}
+
+// BucketIPv6 collapses IPv6 addresses to their /64 prefix so one host cannot
+// present itself as many by rotating through its interface identifiers.
+// IPv4 addresses are returned unchanged.
+func BucketIPv6(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
@@ -0,0 +1,92 @@
+package iputil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPIgnoresHeadersFromUntrustedPeers(t *testing.T) {
+	// No TRUSTED_PROXIES configured: forwarding headers are client-controlled
+	// and must be ignored
+	t.Setenv("TRUSTED_PROXIES", "")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.9:4567"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	r.Header.Set("X-Real-IP", "5.6.7.8")
+
+	if got := ClientIP(r); got != "203.0.113.9" {
+		t.Errorf("ClientIP with spoofed headers from untrusted peer = %q, want peer 203.0.113.9", got)
+	}
+}
+
+func TestClientIPHonorsHeadersFromTrustedProxy(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.1.2.3:4567"
+	// The rightmost entry is the one the trusted proxy itself appended
+	r.Header.Set("X-Forwarded-For", "6.6.6.6, 198.51.100.7")
+
+	if got := ClientIP(r); got != "198.51.100.7" {
+		t.Errorf("ClientIP behind trusted proxy = %q, want 198.51.100.7", got)
+	}
+}
+
+func TestClientIPFallsBackToRealIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:4567"
+	r.Header.Set("X-Real-IP", "198.51.100.8")
+
+	if got := ClientIP(r); got != "198.51.100.8" {
+		t.Errorf("ClientIP with X-Real-IP from trusted proxy = %q, want 198.51.100.8", got)
+	}
+}
+
+func TestClientIPRejectsGarbageHeaderValues(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:4567"
+	r.Header.Set("X-Forwarded-For", "not-an-ip")
+
+	if got := ClientIP(r); got != "10.0.0.2" {
+		t.Errorf("ClientIP with unparseable forwarded value = %q, want peer 10.0.0.2", got)
+	}
+}
+
+func TestTrustedProxiesParsing(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1, bogus")
+
+	networks := TrustedProxies()
+	if len(networks) != 2 {
+		t.Fatalf("TrustedProxies parsed %d entries, want 2 (CIDR + bare IP, bogus skipped)", len(networks))
+	}
+
+	if !IsTrustedProxy("10.255.0.1") {
+		t.Error("10.255.0.1 should be inside 10.0.0.0/8")
+	}
+	if !IsTrustedProxy("192.0.2.1") {
+		t.Error("bare IP entry 192.0.2.1 should be trusted")
+	}
+	if IsTrustedProxy("192.0.2.2") {
+		t.Error("192.0.2.2 should not be trusted")
+	}
+}
+
+func TestBucketIPv6(t *testing.T) {
+	if got := BucketIPv6("192.0.2.5"); got != "192.0.2.5" {
+		t.Errorf("BucketIPv6(IPv4) = %q, want unchanged", got)
+	}
+	a := BucketIPv6("2001:db8::1")
+	b := BucketIPv6("2001:db8::2")
+	if a != b {
+		t.Errorf("addresses in the same /64 bucketed differently: %q vs %q", a, b)
+	}
+	c := BucketIPv6("2001:db9::1")
+	if a == c {
+		t.Errorf("addresses in different /64s bucketed together: %q", a)
+	}
+}
@@ -0,0 +1,29 @@
+package logger
+
+import "net/http"
+
+// sensitiveHeaders lists headers whose values must never reach the logs
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Signature",
+	"X-Admin-Key",
+	"X-Api-Key",
+}
+
+// RedactHeaders returns a copy of the headers safe for logging, with token-
+// and signature-bearing values replaced by a placeholder. Header dumps must
+// always pass through this: a log scrape must not expose bearer tokens.
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		redacted[name] = values
+	}
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"gateway/models"
+	"gateway/pkg/logger"
+)
+
+// A Firebase token verified at the start of a stream can expire while the
+// stream (up to 10 minutes) is still running. Deployments with strict auth
+// can opt in to cutting the stream when that happens.
+
+// tokenExpiryContextKey carries the caller's token expiry through the request
+type tokenExpiryContextKey struct{}
+
+// WithTokenExpiry attaches the verified token's expiry time to the context
+func WithTokenExpiry(ctx context.Context, expiry time.Time) context.Context {
+	return context.WithValue(ctx, tokenExpiryContextKey{}, expiry)
+}
+
+// GetTokenExpiryFromContext retrieves the token expiry recorded for this
+// request, if any
+func GetTokenExpiryFromContext(ctx context.Context) (time.Time, bool) {
+	expiry, ok := ctx.Value(tokenExpiryContextKey{}).(time.Time)
+	return expiry, ok
+}
+
+// enforceTokenExpiryDuringStream reports whether streams are cut when the
+// caller's token expires (ENFORCE_TOKEN_EXPIRY_DURING_STREAM, default off to
+// preserve long-stream behavior)
+func enforceTokenExpiryDuringStream() bool {
+	return os.Getenv("ENFORCE_TOKEN_EXPIRY_DURING_STREAM") == "true"
+}
+
+// streamAuthExpired reports whether an in-flight stream should stop because
+// the caller's token has expired
+func streamAuthExpired(ctx context.Context) bool {
+	if !enforceTokenExpiryDuringStream() {
+		return false
+	}
+	expiry, ok := GetTokenExpiryFromContext(ctx)
+	return ok && time.Now().After(expiry)
+}
+
+// sendAuthExpiredEndFrame closes the stream gracefully, telling the client to
+// refresh its token
+func sendAuthExpiredEndFrame(w http.ResponseWriter, flusher http.Flusher, clientID int) {
+	endResponse := models.Response{
+		Message:   "Authentication expired, please refresh and try again",
+		Type:      "end",
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if msg, err := models.FormatSSEMessage(endResponse); err == nil {
+		fmt.Fprint(w, msg)
+		flusher.Flush()
+	}
+	logger.GetDailyLogger().Info("Stream cut for client %d: auth token expired", clientID)
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+
+	"gateway/middleware"
+)
+
+// recordStreamCost attributes a completed stream's provider-reported token
+// usage to the requesting user's daily spend. Providers call it after their
+// stream finishes; a zero count (provider didn't report usage) is a no-op.
+func recordStreamCost(ctx context.Context, model string, totalTokens int) {
+	if totalTokens <= 0 {
+		return
+	}
+	user, ok := middleware.GetFirebaseUserFromContext(ctx)
+	if !ok || user == nil {
+		return
+	}
+	middleware.RecordTokenCost(ctx, user.UID, model, totalTokens)
+}
@@ -0,0 +1,24 @@
+package services
+
+import "strings"
+
+// normalizeFinishReason maps provider-native stop reasons onto the stable
+// enum surfaced in the end frame: "stop", "length", "content_filter" or
+// "error". Gemini reports values like STOP/MAX_TOKENS/SAFETY, Groq and
+// OpenRouter report stop/length/content_filter. Unknown non-empty values map
+// to "stop" so clients always see a member of the enum; empty stays empty so
+// the field is omitted when the provider reported nothing.
+func normalizeFinishReason(reason string) string {
+	switch strings.ToLower(reason) {
+	case "":
+		return ""
+	case "length", "max_tokens":
+		return "length"
+	case "content_filter", "safety", "recitation", "blocklist", "prohibited_content", "spii":
+		return "content_filter"
+	case "error", "malformed_function_call":
+		return "error"
+	default:
+		return "stop"
+	}
+}
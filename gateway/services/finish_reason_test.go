@@ -0,0 +1,44 @@
+package services
+
+import "testing"
+
+func TestNormalizeFinishReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		// Empty means the provider sent no finish reason yet
+		{"", ""},
+
+		// Truncation
+		{"length", "length"},
+		{"max_tokens", "length"},
+		{"MAX_TOKENS", "length"},
+
+		// Safety/content filtering across providers
+		{"content_filter", "content_filter"},
+		{"safety", "content_filter"},
+		{"SAFETY", "content_filter"},
+		{"recitation", "content_filter"},
+		{"blocklist", "content_filter"},
+		{"prohibited_content", "content_filter"},
+		{"spii", "content_filter"},
+
+		// Provider-side failures
+		{"error", "error"},
+		{"malformed_function_call", "error"},
+
+		// Everything else is a normal stop
+		{"stop", "stop"},
+		{"STOP", "stop"},
+		{"end_turn", "stop"},
+		{"tool_calls", "stop"},
+		{"something_new", "stop"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeFinishReason(tt.reason); got != tt.want {
+			t.Errorf("normalizeFinishReason(%q) = %q, want %q", tt.reason, got, tt.want)
+		}
+	}
+}
@@ -28,12 +28,71 @@ type GeminiRequest struct {
 		Temperature     float64 `json:"temperature,omitempty"`
 		MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
 		TopP            float64 `json:"topP,omitempty"`
-		TopK            int     `json:"topK,omitempty"`
-		ThinkingConfig  *struct {
+		TopK             int     `json:"topK,omitempty"`
+		Seed             *int    `json:"seed,omitempty"`
+		ResponseMimeType string  `json:"responseMimeType,omitempty"`
+		ThinkingConfig   *struct {
 			ThinkingBudget  int  `json:"thinkingBudget,omitempty"`
 			IncludeThoughts bool `json:"includeThoughts,omitempty"`
 		} `json:"thinkingConfig,omitempty"`
 	} `json:"generationConfig,omitempty"`
+	Tools      []GeminiTool      `json:"tools,omitempty"`
+	ToolConfig *GeminiToolConfig `json:"toolConfig,omitempty"`
+}
+
+// GeminiTool wraps function declarations in the shape Gemini expects
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration mirrors the OpenAI function schema for Gemini
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// GeminiToolConfig controls how Gemini decides when to call functions
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// GeminiFunctionCallingConfig holds the function calling mode
+type GeminiFunctionCallingConfig struct {
+	Mode string `json:"mode"` // "AUTO", "ANY" or "NONE"
+}
+
+// geminiToolsFromConfig maps OpenAI-schema tool declarations and tool choice
+// to Gemini's functionDeclarations and toolConfig equivalents
+func geminiToolsFromConfig(cfg *ToolConfig) ([]GeminiTool, *GeminiToolConfig) {
+	declarations := make([]GeminiFunctionDeclaration, 0, len(cfg.Tools))
+	for _, tool := range cfg.Tools {
+		declarations = append(declarations, GeminiFunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+
+	var toolConfig *GeminiToolConfig
+	if choice, ok := cfg.ToolChoice.(string); ok {
+		mode := ""
+		switch choice {
+		case "auto":
+			mode = "AUTO"
+		case "none":
+			mode = "NONE"
+		case "required":
+			mode = "ANY"
+		}
+		if mode != "" {
+			toolConfig = &GeminiToolConfig{
+				FunctionCallingConfig: &GeminiFunctionCallingConfig{Mode: mode},
+			}
+		}
+	}
+
+	return []GeminiTool{{FunctionDeclarations: declarations}}, toolConfig
 }
 
 // GeminiContent represents a content part in a Gemini request
@@ -82,29 +141,10 @@ var (
 	geminiOnce   sync.Once
 )
 
-// initGeminiClient initializes the optimized HTTP client for Gemini
+// initGeminiClient initializes the Gemini HTTP client over the shared transport
 func initGeminiClient() {
 	geminiOnce.Do(func() {
-		geminiClient = &http.Client{
-			Timeout: 0, // No timeout for streaming
-			Transport: &http.Transport{
-				MaxIdleConns:        20,                // Max idle connections
-				MaxIdleConnsPerHost: 5,                 // Max idle per host
-				MaxConnsPerHost:     10,                // Max total per host
-				IdleConnTimeout:     120 * time.Second, // Keep connections alive longer
-				TLSHandshakeTimeout: 10 * time.Second,
-
-				// Streaming optimizations
-				DisableKeepAlives:  false,
-				DisableCompression: true,      // Disable compression for streaming
-				WriteBufferSize:    32 * 1024, // 32KB write buffer
-				ReadBufferSize:     32 * 1024, // 32KB read buffer
-
-				// Connection timeouts
-				ResponseHeaderTimeout: 30 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		}
+		geminiClient = providerHTTPClient("GEMINI_HTTP_TIMEOUT_SECONDS")
 	})
 }
 
@@ -473,8 +513,8 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
 
     // --- REFINED PROMPT AND HISTORY HANDLING ---
 
-    // 1. Get the base system prompt
-    systemPrompt := models.Config.GetSystemPrompt("gemini")
+    // 1. Get the base system prompt, rendering any template variables for this request
+    systemPrompt := renderSystemPrompt(ctx, models.Config.GetSystemPrompt("gemini"))
     finalSystemPrompt := systemPrompt
     if profileContext != "" {
         finalSystemPrompt += "\n\n--- User Profile Context (Follow these instructions implicitly) ---\n" + profileContext
@@ -495,21 +535,11 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
     // 3. Build the conversational history with proper roles
     contents := []GeminiContent{}
 
-    // Filter out thinking blocks from previous messages
-    filteredMessages := []models.ChatMessage{}
-    for _, msg := range previousMessages {
-        if !strings.Contains(msg.Content, "◁think▷") && !strings.Contains(msg.Content, "◁/think▷") {
-            filteredMessages = append(filteredMessages, msg)
-        }
-    }
-
-    // Process up to the last 4 messages for context
-    startIdx := 0
-    if len(filteredMessages) > 4 {
-        startIdx = len(filteredMessages) - 4
-    }
+    // History is windowed and trimmed to the context budget the same way
+    // for every provider
+    historyMessages := assembleHistoryWindow(ctx, previousMessages, prompt, finalSystemPrompt, clientID)
 
-    for _, msg := range filteredMessages[startIdx:] {
+    for _, msg := range historyMessages {
         role := "user"
         // Assign the 'model' role for assistant's messages
         if msg.Role != "user" {
@@ -535,6 +565,22 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         },
     })
 
+    // Optional prompt-assembly debug logging (LOG_ASSEMBLED_PROMPT)
+    if assembledPromptLoggingEnabled() {
+        assembled := []assembledMessage{}
+        if finalSystemPrompt != "" {
+            assembled = append(assembled, assembledMessage{role: "system", content: finalSystemPrompt})
+        }
+        for _, content := range contents {
+            text := ""
+            for _, part := range content.Parts {
+                text += part.Text
+            }
+            assembled = append(assembled, assembledMessage{role: content.Role, content: text})
+        }
+        logAssembledPrompt("gemini", clientID, assembled)
+    }
+
 
     // ... (the rest of the function, including generationConfig, request creation, and streaming logic, remains the same) ...
     // The previous complex logic for addCurrentPrompt, contextPrefix, etc., is no longer needed.
@@ -544,8 +590,10 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         Temperature     float64 `json:"temperature,omitempty"`
         MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
         TopP            float64 `json:"topP,omitempty"`
-        TopK            int     `json:"topK,omitempty"`
-        ThinkingConfig  *struct {
+        TopK             int     `json:"topK,omitempty"`
+        Seed             *int    `json:"seed,omitempty"`
+        ResponseMimeType string  `json:"responseMimeType,omitempty"`
+        ThinkingConfig   *struct {
             ThinkingBudget  int  `json:"thinkingBudget,omitempty"`
             IncludeThoughts bool `json:"includeThoughts,omitempty"`
         } `json:"thinkingConfig,omitempty"`
@@ -567,12 +615,30 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         }
     }
 
+    // Pass through the seed for reproducible generations
+    if opts := GetGenerationOptionsFromContext(ctx); opts != nil && opts.Seed != nil {
+        generationConfig.Seed = opts.Seed
+    }
+
+    // Map JSON mode to Gemini's native responseMimeType; JSON-mode responses
+    // won't contain think tags, so skip marker processing
+    if jsonModeRequested(ctx) {
+        generationConfig.ResponseMimeType = "application/json"
+        generationConfig.ThinkingConfig = nil
+        isThinkingModel = false
+    }
+
     reqBody := GeminiRequest{
         Contents:          contents,
         SystemInstruction: systemInstruction,
         GenerationConfig:  generationConfig,
     }
 
+    // Pass through tool declarations when the client supplied them
+    if toolConfig := GetToolConfigFromContext(ctx); toolConfig != nil {
+        reqBody.Tools, reqBody.ToolConfig = geminiToolsFromConfig(toolConfig)
+    }
+
     jsonData, err := json.Marshal(reqBody)
     if err != nil {
         return fmt.Errorf("error marshaling request: %v", err)
@@ -581,6 +647,12 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
     // Create streaming URL
     url := fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse&key=%s", baseURL, modelName, apiKey)
 
+    // Sampled debug logging of the exact provider exchange
+    debugSampled := providerDebugSampled()
+    if debugSampled {
+        logProviderDebugRequest("gemini", clientID, url, jsonData)
+    }
+
     // Create request with context for cancellation
     req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
     if err != nil {
@@ -611,6 +683,10 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         Type:    "start",
         Model:   displayName,
     }
+    if ModeDowngradedFromContext(ctx) {
+        startResponse.ModeDowngraded = true
+        startResponse.DowngradeNotice = DowngradeNotice
+    }
 
     startMsg, err := models.FormatSSEMessage(startResponse)
     if err == nil {
@@ -618,6 +694,27 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         flusher.Flush()
     }
 
+    // A proxy may have stripped SSE despite alt=sse; emit the full JSON body
+    // as one chunk rather than silently producing an empty stream
+    if nonStreamFallbackEnabled() && !isSSEResponse(resp) {
+        logger.GetDailyLogger().Warn("Gemini returned non-SSE Content-Type %q for client %d, using non-streaming fallback", resp.Header.Get("Content-Type"), clientID)
+        body, readErr := io.ReadAll(resp.Body)
+        if readErr != nil {
+            return fmt.Errorf("error reading non-streaming response: %v", readErr)
+        }
+        content, finishReason, extractErr := extractGeminiContent(body)
+        if extractErr != nil {
+            return extractErr
+        }
+        return emitNonStreamingContent(w, flusher, "Gemini", clientID, content, finishReason)
+    }
+
+    // Optionally coalesce tiny writes/flushes (SSE_BATCH_MS / SSE_BATCH_BYTES)
+    if batcher := newSSEBatcher(w, flusher); batcher != nil {
+        w, flusher = batcher, batcher
+        defer batcher.FlushFinal()
+    }
+
     // Stream processing with optimized buffering
     scanner := bufio.NewScanner(resp.Body)
 
@@ -626,10 +723,19 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
     scanner.Buffer(buf, 64*1024)
 
     chunkCount := 0
+    totalTokens := 0
+    transformer := newResponseTransformer()
     var fullResponse strings.Builder
     var inThinking bool = false
+    var streamFinishReason string
 
     for scanner.Scan() {
+        // Optionally stop streaming once the caller's token has expired
+        if streamAuthExpired(ctx) {
+            sendAuthExpiredEndFrame(w, flusher, clientID)
+            return nil
+        }
+
         line := scanner.Text()
         if len(line) == 0 {
             continue
@@ -652,6 +758,14 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
 
         chunkCount++
 
+        // Gemini reports cumulative token usage in usageMetadata; keep the
+        // last one seen
+        if usageMetadata, ok := streamResp["usageMetadata"].(map[string]interface{}); ok {
+            if count, ok := usageMetadata["totalTokenCount"].(float64); ok && count > 0 {
+                totalTokens = int(count)
+            }
+        }
+
         // Extract the response part
         var chunkText string
         var isThought bool = false
@@ -673,12 +787,19 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
                             if thought, ok := part["thought"].(bool); ok && thought {
                                 isThought = true
                             }
+                            // Forward function calls as tool_call frames
+                            if functionCall, ok := part["functionCall"].(map[string]interface{}); ok {
+                                if err := sendToolCallFrame(w, flusher, []models.ToolCallDelta{geminiToolCallDelta(functionCall)}); err != nil {
+                                    return err
+                                }
+                            }
                         }
                     }
                 }
 
                 // Check if this is the final message with finishReason
                 if finishReason, ok := candidate["finishReason"].(string); ok && finishReason != "" {
+                    streamFinishReason = finishReason
                     isFinal = true
                 }
             }
@@ -713,6 +834,9 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
             }
         }
 
+        // Post-process streamed text through the configured transformer
+        chunkText = transformer.Transform(chunkText)
+
         // Handle content based on whether it's thinking or regular content
         if chunkText != "" {
             // Send content chunks immediately - both thinking and regular content
@@ -743,10 +867,24 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
         return fmt.Errorf("error reading stream: %v", err)
     }
 
+    // Emit anything the transformer was still buffering
+    if tail := transformer.Flush(); tail != "" {
+        fullResponse.WriteString(tail)
+        tailResponse := models.Response{
+            Message: tail,
+            Type:    "chunk",
+        }
+        if msg, err := models.FormatSSEMessage(tailResponse); err == nil {
+            fmt.Fprint(w, msg)
+            flusher.Flush()
+        }
+    }
+
     // Send completion signal
     finalResponse := models.Response{
-        Type:      "end",
-        Timestamp: time.Now().Format(time.RFC3339),
+        Type:         "end",
+        Timestamp:    time.Now().Format(time.RFC3339),
+        FinishReason: normalizeFinishReason(streamFinishReason),
     }
 
     msg, _ := models.FormatSSEMessage(finalResponse)
@@ -755,6 +893,12 @@ func StreamGeminiResponse(ctx context.Context, w http.ResponseWriter, flusher ht
 
     logger.GetDailyLogger().Info("Gemini streaming completed for client %d: %d chunks in %.2fs", clientID, chunkCount, time.Since(startTime).Seconds())
 
+    if debugSampled {
+        logProviderDebugResponse("gemini", clientID, fullResponse.String())
+    }
+
+    recordStreamCost(ctx, model, totalTokens)
+
     return nil
 }
 
@@ -776,8 +920,10 @@ func CallGeminiAPI(model, prompt string, isThinkingModel bool) (string, error) {
 		Temperature     float64 `json:"temperature,omitempty"`
 		MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
 		TopP            float64 `json:"topP,omitempty"`
-		TopK            int     `json:"topK,omitempty"`
-		ThinkingConfig  *struct {
+		TopK             int     `json:"topK,omitempty"`
+		Seed             *int    `json:"seed,omitempty"`
+		ResponseMimeType string  `json:"responseMimeType,omitempty"`
+		ThinkingConfig   *struct {
 			ThinkingBudget  int  `json:"thinkingBudget,omitempty"`
 			IncludeThoughts bool `json:"includeThoughts,omitempty"`
 		} `json:"thinkingConfig,omitempty"`
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+
+	"gateway/models"
+)
+
+// GenerationOptions carries optional per-request generation parameters that
+// are passed through to the providers
+type GenerationOptions struct {
+	Seed           *int
+	ResponseFormat *models.ResponseFormat
+}
+
+// jsonModeRequested reports whether the request asked for guaranteed JSON output
+func jsonModeRequested(ctx context.Context) bool {
+	opts := GetGenerationOptionsFromContext(ctx)
+	return opts != nil && opts.ResponseFormat != nil && opts.ResponseFormat.Type == "json_object"
+}
+
+// generationOptionsContextKey is the context key for per-request generation options
+type generationOptionsContextKey struct{}
+
+// WithGenerationOptions returns a context carrying the request's generation options
+func WithGenerationOptions(ctx context.Context, opts *GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsContextKey{}, opts)
+}
+
+// GetGenerationOptionsFromContext returns the request's generation options, or nil
+func GetGenerationOptionsFromContext(ctx context.Context) *GenerationOptions {
+	opts, _ := ctx.Value(generationOptionsContextKey{}).(*GenerationOptions)
+	return opts
+}
@@ -24,9 +24,13 @@ type GroqMessage struct {
 
 // GroqRequest represents the request to Groq API
 type GroqRequest struct {
-	Model    string        `json:"model"`
-	Messages []GroqMessage `json:"messages"`
-	Stream   bool          `json:"stream"`
+	Model      string        `json:"model"`
+	Messages   []GroqMessage `json:"messages"`
+	Stream     bool          `json:"stream"`
+	Tools      []models.Tool `json:"tools,omitempty"`
+	ToolChoice interface{}   `json:"tool_choice,omitempty"`
+	Seed           *int                   `json:"seed,omitempty"`
+	ResponseFormat *models.ResponseFormat `json:"response_format,omitempty"`
 	// Messages map[string]interface{} `json:"options"`
 }
 
@@ -39,8 +43,9 @@ type GroqResponse struct {
 	Choices []struct {
 		Index int `json:"index"`
 		Delta struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
+			Role      string                 `json:"role"`
+			Content   string                 `json:"content"`
+			ToolCalls []models.ToolCallDelta `json:"tool_calls,omitempty"`
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
@@ -69,29 +74,10 @@ var (
 	groqOnce   sync.Once
 )
 
-// initGroqClient initializes the optimized HTTP client for Groq
+// initGroqClient initializes the Groq HTTP client over the shared transport
 func initGroqClient() {
 	groqOnce.Do(func() {
-		groqClient = &http.Client{
-			Timeout: 0, // No timeout for streaming
-			Transport: &http.Transport{
-				MaxIdleConns:        20,                // Max idle connections
-				MaxIdleConnsPerHost: 5,                 // Max idle per host
-				MaxConnsPerHost:     10,                // Max total per host
-				IdleConnTimeout:     120 * time.Second, // Keep connections alive longer
-				TLSHandshakeTimeout: 10 * time.Second,
-
-				// Streaming optimizations
-				DisableKeepAlives:  false,
-				DisableCompression: true,      // Disable compression for streaming
-				WriteBufferSize:    32 * 1024, // 32KB write buffer
-				ReadBufferSize:     32 * 1024, // 32KB read buffer
-
-				// Connection timeouts
-				ResponseHeaderTimeout: 30 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		}
+		groqClient = providerHTTPClient("GROQ_HTTP_TIMEOUT_SECONDS")
 	})
 }
 
@@ -110,6 +96,17 @@ func getGroqConfig() (apiKey, baseURL string) {
 	return apiKey, baseURL
 }
 
+// groqSystemPromptPosition returns how the system prompt is injected for Groq
+// (GROQ_SYSTEM_PROMPT_POSITION): "system" (default) sends it as a system-role
+// message like the other providers; "prefix" prepends it to the current user
+// prompt for models that ignore or mishandle system messages
+func groqSystemPromptPosition() string {
+	if os.Getenv("GROQ_SYSTEM_PROMPT_POSITION") == "prefix" {
+		return "prefix"
+	}
+	return "system"
+}
+
 // StreamGroqResponse calls Groq API and streams the response with optimizations
 func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, prompt string, model string, displayName string, clientID int, previousMessages []models.ChatMessage, profileContext string, isThinkingModel bool) error {
 	// Initialize optimized client
@@ -120,8 +117,8 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 	// Get API key and base URL from environment
 	apiKey, baseURL := getGroqConfig()
 
-	// Get the system prompt
-	systemPrompt := models.Config.GetSystemPrompt("groq")
+	// Get the system prompt, rendering any template variables for this request
+	systemPrompt := renderSystemPrompt(ctx, models.Config.GetSystemPrompt("groq"))
 
 	// Format messages for Groq
 	messages := []GroqMessage{}
@@ -134,30 +131,24 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 		finalSystemPrompt += "\n\n--- User Profile Context (Follow these instructions implicitly) ---\n" + profileContext
 	}
 
-	// 2. Add system prompt as a proper system message
-	if finalSystemPrompt != "" {
+	// 2. Add system prompt as a proper system message (unless the operator
+	// configured prefix injection for models that mishandle system roles).
+	// History assembly below deliberately mirrors Gemini and OpenRouter —
+	// clean roles, last-4 window, token budget, current prompt last — so
+	// multi-turn behavior stays consistent across providers.
+	systemAsPrefix := groqSystemPromptPosition() == "prefix"
+	if finalSystemPrompt != "" && !systemAsPrefix {
 		messages = append(messages, GroqMessage{
 			Role:    "system",
 			Content: finalSystemPrompt,
 		})
 	}
 
-	// 3. Build the conversational history with proper roles
-	// Filter out thinking blocks from previous messages
-	filteredMessages := []models.ChatMessage{}
-	for _, msg := range previousMessages {
-		if !strings.Contains(msg.Content, "◁think▷") && !strings.Contains(msg.Content, "◁/think▷") {
-			filteredMessages = append(filteredMessages, msg)
-		}
-	}
-
-	// Process up to the last 4 messages for context
-	startIdx := 0
-	if len(filteredMessages) > 4 {
-		startIdx = len(filteredMessages) - 4
-	}
+	// 3. Build the conversational history with proper roles, windowed and
+	// trimmed to the context budget
+	historyMessages := assembleHistoryWindow(ctx, previousMessages, prompt, finalSystemPrompt, clientID)
 
-	for _, msg := range filteredMessages[startIdx:] {
+	for _, msg := range historyMessages {
 		// Use clean message content without prefixes
 		messages = append(messages, GroqMessage{
 			Role:    msg.Role,    // Keep original role (user/assistant)
@@ -166,11 +157,24 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 	}
 
 	// 4. Add the current user prompt as the final message
+	currentPrompt := prompt
+	if systemAsPrefix && finalSystemPrompt != "" {
+		currentPrompt = finalSystemPrompt + "\n\n" + prompt
+	}
 	messages = append(messages, GroqMessage{
 		Role:    "user",
-		Content: prompt, // The current prompt is the last thing the user said
+		Content: currentPrompt, // The current prompt is the last thing the user said
 	})
 
+	// Optional prompt-assembly debug logging (LOG_ASSEMBLED_PROMPT)
+	if assembledPromptLoggingEnabled() {
+		assembled := make([]assembledMessage, 0, len(messages))
+		for _, msg := range messages {
+			assembled = append(assembled, assembledMessage{role: msg.Role, content: msg.Content})
+		}
+		logAssembledPrompt("groq", clientID, assembled)
+	}
+
 	// Create the request body
 	reqBody := GroqRequest{
 		Model:    model,
@@ -178,12 +182,35 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 		Stream:   true,
 	}
 
+	// Pass through tool declarations when the client supplied them
+	if toolConfig := GetToolConfigFromContext(ctx); toolConfig != nil {
+		reqBody.Tools = toolConfig.Tools
+		reqBody.ToolChoice = toolConfig.ToolChoice
+	}
+
+	// Pass through the seed and response format when supplied
+	if opts := GetGenerationOptionsFromContext(ctx); opts != nil {
+		reqBody.Seed = opts.Seed
+		reqBody.ResponseFormat = opts.ResponseFormat
+	}
+
+	// JSON-mode responses won't contain think tags, so skip marker processing
+	if jsonModeRequested(ctx) {
+		isThinkingModel = false
+	}
+
 	// Prepare optimized request
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("error marshaling request: %v", err)
 	}
 
+	// Sampled debug logging of the exact provider exchange
+	debugSampled := providerDebugSampled()
+	if debugSampled {
+		logProviderDebugRequest("groq", clientID, baseURL+"/chat/completions", jsonData)
+	}
+
 	// Create request with context for cancellation
 	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -215,6 +242,10 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 		Type:    "start",
 		Model:   displayName,
 	}
+	if ModeDowngradedFromContext(ctx) {
+		startResponse.ModeDowngraded = true
+		startResponse.DowngradeNotice = DowngradeNotice
+	}
 
 	startMsg, err := models.FormatSSEMessage(startResponse)
 	if err == nil {
@@ -222,6 +253,27 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 		flusher.Flush()
 	}
 
+	// A proxy may have stripped SSE despite stream:true; emit the full JSON
+	// body as one chunk rather than silently producing an empty stream
+	if nonStreamFallbackEnabled() && !isSSEResponse(resp) {
+		logger.GetDailyLogger().Warn("Groq returned non-SSE Content-Type %q for client %d, using non-streaming fallback", resp.Header.Get("Content-Type"), clientID)
+		body, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return fmt.Errorf("error reading non-streaming response: %v", readErr)
+		}
+		content, finishReason, extractErr := extractOpenAIStyleContent(body)
+		if extractErr != nil {
+			return extractErr
+		}
+		return emitNonStreamingContent(w, flusher, "Groq", clientID, content, finishReason)
+	}
+
+	// Optionally coalesce tiny writes/flushes (SSE_BATCH_MS / SSE_BATCH_BYTES)
+	if batcher := newSSEBatcher(w, flusher); batcher != nil {
+		w, flusher = batcher, batcher
+		defer batcher.FlushFinal()
+	}
+
 	// Stream processing with optimized buffering
 	scanner := bufio.NewScanner(resp.Body)
 
@@ -230,11 +282,20 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 	scanner.Buffer(buf, 64*1024)
 
 	chunkCount := 0
+	totalTokens := 0
+	transformer := newResponseTransformer()
 	var fullResponse strings.Builder
 	var inThinking bool = false
 	var thinkingBuffer strings.Builder
+	var streamFinishReason string
 
 	for scanner.Scan() {
+		// Optionally stop streaming once the caller's token has expired
+		if streamAuthExpired(ctx) {
+			sendAuthExpiredEndFrame(w, flusher, clientID)
+			return nil
+		}
+
 		line := scanner.Text()
 		if len(line) == 0 {
 			continue
@@ -270,9 +331,29 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 
 			chunkCount++
 
+			// Groq reports token usage on the final chunk (x_groq for
+			// streaming, top-level usage otherwise); keep the last one seen
+			if streamResp.XGroq.Usage.TotalTokens > 0 {
+				totalTokens = streamResp.XGroq.Usage.TotalTokens
+			} else if streamResp.Usage.TotalTokens > 0 {
+				totalTokens = streamResp.Usage.TotalTokens
+			}
+
 			// Extract the response part
 			if len(streamResp.Choices) > 0 {
-				content := streamResp.Choices[0].Delta.Content
+				// Keep the last finish_reason seen for the end frame
+				if reason := streamResp.Choices[0].FinishReason; reason != "" {
+					streamFinishReason = reason
+				}
+
+				// Forward tool-call deltas as distinct frames, not text chunks
+				if toolCalls := streamResp.Choices[0].Delta.ToolCalls; len(toolCalls) > 0 {
+					if err := sendToolCallFrame(w, flusher, toolCalls); err != nil {
+						return err
+					}
+				}
+
+				content := transformer.Transform(streamResp.Choices[0].Delta.Content)
 				if content != "" {
 					// Process content for thinking blocks only for thinking models
 					if isThinkingModel {
@@ -327,10 +408,24 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 		return fmt.Errorf("error reading response: %v", err)
 	}
 
+	// Emit anything the transformer was still buffering
+	if tail := transformer.Flush(); tail != "" {
+		fullResponse.WriteString(tail)
+		tailResponse := models.Response{
+			Message: tail,
+			Type:    "chunk",
+		}
+		if msg, err := models.FormatSSEMessage(tailResponse); err == nil {
+			fmt.Fprint(w, msg)
+			flusher.Flush()
+		}
+	}
+
 	// Send completion signal using structured format (matching Gemini)
 	finalResponse := models.Response{
-		Type:      "end",
-		Timestamp: time.Now().Format(time.RFC3339),
+		Type:         "end",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		FinishReason: normalizeFinishReason(streamFinishReason),
 	}
 
 	msg, _ := models.FormatSSEMessage(finalResponse)
@@ -339,6 +434,12 @@ func StreamGroqResponse(ctx context.Context, w http.ResponseWriter, flusher http
 
 	logger.GetDailyLogger().Info("Groq streaming completed for client %d: %d chunks in %.2fs", clientID, chunkCount, time.Since(startTime).Seconds())
 
+	if debugSampled {
+		logProviderDebugResponse("groq", clientID, fullResponse.String())
+	}
+
+	recordStreamCost(ctx, model, totalTokens)
+
 	return nil
 }
 
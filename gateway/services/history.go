@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"gateway/config"
+	"gateway/models"
+	"gateway/pkg/logger"
+)
+
+// Thinking block markers emitted by thinking models
+const (
+	thinkStartMarker = "◁think▷"
+	thinkEndMarker   = "◁/think▷"
+)
+
+// StripThinkingBlocks removes thinking segments (text between ◁think▷ and
+// ◁/think▷) from content while keeping the surrounding answer text, so a
+// message that happens to contain a marker still contributes its final answer
+// as context. Unbalanced markers are handled conservatively: an unclosed
+// ◁think▷ drops everything after it, and a stray ◁/think▷ drops everything
+// before it. Nested blocks are tracked by depth.
+func StripThinkingBlocks(content string) string {
+	if !strings.Contains(content, thinkStartMarker) && !strings.Contains(content, thinkEndMarker) {
+		return content
+	}
+
+	var out strings.Builder
+	depth := 0
+	for {
+		start := strings.Index(content, thinkStartMarker)
+		end := strings.Index(content, thinkEndMarker)
+		switch {
+		case start == -1 && end == -1:
+			if depth == 0 {
+				out.WriteString(content)
+			}
+			return strings.TrimSpace(out.String())
+		case end == -1 || (start != -1 && start < end):
+			// Opening marker comes first
+			if depth == 0 {
+				out.WriteString(content[:start])
+			}
+			depth++
+			content = content[start+len(thinkStartMarker):]
+		default:
+			// Closing marker comes first; at depth 0 it is stray and the
+			// text before it is treated as thinking output
+			if depth > 0 {
+				depth--
+			}
+			content = content[end+len(thinkEndMarker):]
+		}
+	}
+}
+
+// filterThinkingFromHistory strips thinking segments from each history
+// message, dropping only messages left empty by the strip
+func filterThinkingFromHistory(messages []models.ChatMessage) []models.ChatMessage {
+	filtered := make([]models.ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		msg.Content = StripThinkingBlocks(msg.Content)
+		if msg.Content != "" {
+			filtered = append(filtered, msg)
+		}
+	}
+	return filtered
+}
+
+// historyWindowContextKey carries the tier-resolved history window through
+// the request context to the provider assembly paths
+type historyWindowContextKey struct{}
+
+// WithHistoryWindow returns a context carrying the resolved history window
+// (how many trailing history messages the providers include as context)
+func WithHistoryWindow(ctx context.Context, window int) context.Context {
+	return context.WithValue(ctx, historyWindowContextKey{}, window)
+}
+
+// historyWindow returns the history window from context, defaulting to the
+// providers' long-standing last-4 when none was resolved
+func historyWindow(ctx context.Context) int {
+	if window, ok := ctx.Value(historyWindowContextKey{}).(int); ok && window > 0 {
+		return window
+	}
+	return config.DefaultMaxContextMessages
+}
+
+// defaultMaxContextTokens is the assembled-prompt token budget when
+// MAX_CONTEXT_TOKENS is not set, sized for the smallest model we route to
+const defaultMaxContextTokens = 8000
+
+// maxContextTokens returns the token budget for the assembled prompt
+// (system prompt + history + current prompt), overridable via MAX_CONTEXT_TOKENS
+func maxContextTokens() int {
+	if value := os.Getenv("MAX_CONTEXT_TOKENS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultMaxContextTokens
+}
+
+// estimateTokens roughly estimates the token count of a string using the
+// common chars/4 heuristic; close enough for budget enforcement
+func estimateTokens(text string) int {
+	return len(text)/4 + 1
+}
+
+// trimHistoryToTokenBudget drops the oldest history messages until the
+// assembled prompt fits the token budget. The current prompt and system
+// prompt are always kept, even if that leaves no room for history.
+func trimHistoryToTokenBudget(messages []models.ChatMessage, prompt, systemPrompt string, clientID int) []models.ChatMessage {
+	budget := maxContextTokens() - estimateTokens(prompt) - estimateTokens(systemPrompt)
+	if budget < 0 {
+		budget = 0
+	}
+
+	start := len(messages)
+	totalTokens := 0
+	for start > 0 {
+		msgTokens := estimateTokens(messages[start-1].Content)
+		if totalTokens+msgTokens > budget {
+			break
+		}
+		totalTokens += msgTokens
+		start--
+	}
+
+	if start > 0 {
+		logger.GetDailyLogger().Warn("Client %d: Trimmed %d oldest history messages to fit %d-token context budget", clientID, start, maxContextTokens())
+	}
+
+	return messages[start:]
+}
+
+// assembleHistoryWindow prepares client-supplied history for a provider
+// request: thinking segments are stripped, only the tier-resolved trailing
+// window is kept, and the oldest survivors are dropped until the assembled
+// prompt fits the token budget. All providers share this path so history
+// behaves the same regardless of routing.
+func assembleHistoryWindow(ctx context.Context, previousMessages []models.ChatMessage, prompt, systemPrompt string, clientID int) []models.ChatMessage {
+	filteredMessages := filterThinkingFromHistory(previousMessages)
+
+	window := historyWindow(ctx)
+	startIdx := 0
+	if len(filteredMessages) > window {
+		startIdx = len(filteredMessages) - window
+	}
+
+	return trimHistoryToTokenBudget(filteredMessages[startIdx:], prompt, systemPrompt, clientID)
+}
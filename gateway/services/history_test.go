@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gateway/config"
+	"gateway/models"
+)
+
+func historyOf(contents ...string) []models.ChatMessage {
+	messages := make([]models.ChatMessage, 0, len(contents))
+	role := "user"
+	for _, content := range contents {
+		messages = append(messages, models.ChatMessage{Role: role, Content: content})
+		if role == "user" {
+			role = "assistant"
+		} else {
+			role = "user"
+		}
+	}
+	return messages
+}
+
+func TestAssembleHistoryWindowDefaultWindow(t *testing.T) {
+	t.Setenv("MAX_CONTEXT_TOKENS", "")
+
+	history := historyOf("one", "two", "three", "four", "five", "six")
+	got := assembleHistoryWindow(context.Background(), history, "prompt", "", 1)
+
+	if len(got) != config.DefaultMaxContextMessages {
+		t.Fatalf("default window kept %d messages, want %d", len(got), config.DefaultMaxContextMessages)
+	}
+	// The most recent messages win
+	if got[0].Content != "three" || got[len(got)-1].Content != "six" {
+		t.Errorf("window = %q..%q, want three..six", got[0].Content, got[len(got)-1].Content)
+	}
+}
+
+func TestAssembleHistoryWindowTierWindow(t *testing.T) {
+	t.Setenv("MAX_CONTEXT_TOKENS", "")
+
+	history := historyOf("one", "two", "three", "four", "five", "six")
+	ctx := WithHistoryWindow(context.Background(), 2)
+	got := assembleHistoryWindow(ctx, history, "prompt", "", 1)
+
+	if len(got) != 2 {
+		t.Fatalf("window of 2 kept %d messages", len(got))
+	}
+	if got[0].Content != "five" || got[1].Content != "six" {
+		t.Errorf("window = %q,%q, want five,six", got[0].Content, got[1].Content)
+	}
+}
+
+func TestAssembleHistoryWindowStripsThinking(t *testing.T) {
+	t.Setenv("MAX_CONTEXT_TOKENS", "")
+
+	history := []models.ChatMessage{
+		{Role: "user", Content: "question"},
+		{Role: "assistant", Content: "◁think▷working...◁/think▷ answer"},
+		{Role: "assistant", Content: "◁think▷only thinking◁/think▷"},
+	}
+	got := assembleHistoryWindow(context.Background(), history, "prompt", "", 1)
+
+	if len(got) != 2 {
+		t.Fatalf("kept %d messages, want 2 (thinking-only message dropped)", len(got))
+	}
+	if got[1].Content != "answer" {
+		t.Errorf("stripped content = %q, want %q", got[1].Content, "answer")
+	}
+}
+
+func TestAssembleHistoryWindowRespectsTokenBudget(t *testing.T) {
+	// Budget of 100 tokens ≈ 400 chars; two 300-char messages cannot both fit
+	t.Setenv("MAX_CONTEXT_TOKENS", "100")
+
+	big := make([]byte, 300)
+	for i := range big {
+		big[i] = 'x'
+	}
+	history := historyOf(string(big), string(big))
+	got := assembleHistoryWindow(context.Background(), history, "prompt", "", 1)
+
+	if len(got) != 1 {
+		t.Fatalf("kept %d messages under a tight budget, want only the newest", len(got))
+	}
+}
+
+func TestGroqSystemPromptPosition(t *testing.T) {
+	t.Setenv("GROQ_SYSTEM_PROMPT_POSITION", "")
+	if got := groqSystemPromptPosition(); got != "system" {
+		t.Errorf("default position = %q, want %q", got, "system")
+	}
+
+	t.Setenv("GROQ_SYSTEM_PROMPT_POSITION", "prefix")
+	if got := groqSystemPromptPosition(); got != "prefix" {
+		t.Errorf("position with override = %q, want %q", got, "prefix")
+	}
+
+	t.Setenv("GROQ_SYSTEM_PROMPT_POSITION", "sideways")
+	if got := groqSystemPromptPosition(); got != "system" {
+		t.Errorf("position with invalid override = %q, want the %q default", got, "system")
+	}
+}
+
+func TestStripThinkingBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no markers", "plain answer", "plain answer"},
+		{"simple block", "◁think▷reasoning◁/think▷ final answer", "final answer"},
+		{"block in the middle", "before ◁think▷reasoning◁/think▷ after", "before  after"},
+		{"nested blocks", "◁think▷outer ◁think▷inner◁/think▷ more◁/think▷answer", "answer"},
+		{"multiple blocks", "◁think▷a◁/think▷one ◁think▷b◁/think▷two", "one two"},
+		{"unclosed start drops the rest", "answer ◁think▷never closed", "answer"},
+		{"stray end drops the prefix", "leaked thinking◁/think▷ answer", "answer"},
+		{"markers only", "◁think▷all thinking◁/think▷", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripThinkingBlocks(tt.content); got != tt.want {
+				t.Errorf("StripThinkingBlocks(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterThinkingFromHistoryDropsEmptiedMessages(t *testing.T) {
+	history := []models.ChatMessage{
+		{Role: "user", Content: "question"},
+		{Role: "assistant", Content: "◁think▷only thinking◁/think▷"},
+		{Role: "assistant", Content: "◁think▷thinking◁/think▷ answer"},
+	}
+
+	filtered := filterThinkingFromHistory(history)
+	if len(filtered) != 2 {
+		t.Fatalf("filtered %d messages, want 2 (thinking-only message dropped)", len(filtered))
+	}
+	if filtered[0].Content != "question" || filtered[1].Content != "answer" {
+		t.Errorf("filtered = %q,%q, want question,answer", filtered[0].Content, filtered[1].Content)
+	}
+}
+
+func TestHistoryWindowContext(t *testing.T) {
+	if got := historyWindow(context.Background()); got != config.DefaultMaxContextMessages {
+		t.Errorf("historyWindow without a resolved window = %d, want %d", got, config.DefaultMaxContextMessages)
+	}
+
+	ctx := WithHistoryWindow(context.Background(), 8)
+	if got := historyWindow(ctx); got != 8 {
+		t.Errorf("historyWindow with a resolved window = %d, want 8", got)
+	}
+
+	// Zero and negative windows fall back to the default
+	if got := historyWindow(WithHistoryWindow(context.Background(), 0)); got != config.DefaultMaxContextMessages {
+		t.Errorf("historyWindow with a zero window = %d, want the default", got)
+	}
+}
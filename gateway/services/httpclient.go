@@ -0,0 +1,88 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Gemini, Groq, and OpenRouter previously each kept their own identically
+// configured http.Client, tripling the idle-connection budget and letting the
+// settings drift apart when only one was tuned. All providers now share a
+// single transport (one connection pool); clients stay per-provider only so
+// a timeout override for one provider doesn't affect the others.
+
+var (
+	providerTransport     *http.Transport
+	providerTransportOnce sync.Once
+)
+
+// sharedProviderTransport returns the streaming-optimized transport shared by
+// every provider client
+func sharedProviderTransport() *http.Transport {
+	providerTransportOnce.Do(func() {
+		providerTransport = &http.Transport{
+			MaxIdleConns:        providerMaxIdleConns(),        // Max idle connections
+			MaxIdleConnsPerHost: providerMaxIdleConnsPerHost(), // Max idle per host
+			MaxConnsPerHost:     providerMaxConnsPerHost(),     // Max total per host
+			IdleConnTimeout:     120 * time.Second,             // Keep connections alive longer
+			TLSHandshakeTimeout: 10 * time.Second,
+
+			// Streaming optimizations
+			DisableKeepAlives:  false,
+			DisableCompression: true,      // Disable compression for streaming
+			WriteBufferSize:    32 * 1024, // 32KB write buffer
+			ReadBufferSize:     32 * 1024, // 32KB read buffer
+
+			// Connection timeouts
+			ResponseHeaderTimeout: 30 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	})
+	return providerTransport
+}
+
+// GetTimeoutSettings returns the resolved classifier and per-provider HTTP
+// timeouts for the effective-config admin endpoint. Provider values are in
+// seconds; zero means no timeout, which streaming requires.
+func GetTimeoutSettings() map[string]interface{} {
+	initClassifier()
+
+	providerTimeoutSeconds := func(envKey string) int {
+		if value := os.Getenv(envKey); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+				return parsed
+			}
+		}
+		return 0
+	}
+
+	return map[string]interface{}{
+		"classifier_ms":           classifierTimeout.Milliseconds(),
+		"classifier_acquire_ms":   classifierAcquireTimeout.Milliseconds(),
+		"gemini_http_seconds":     providerTimeoutSeconds("GEMINI_HTTP_TIMEOUT_SECONDS"),
+		"groq_http_seconds":       providerTimeoutSeconds("GROQ_HTTP_TIMEOUT_SECONDS"),
+		"openrouter_http_seconds": providerTimeoutSeconds("OPENROUTER_HTTP_TIMEOUT_SECONDS"),
+		"response_header_seconds": int(sharedProviderTransport().ResponseHeaderTimeout.Seconds()),
+		"tls_handshake_seconds":   int(sharedProviderTransport().TLSHandshakeTimeout.Seconds()),
+		"idle_connection_seconds": int(sharedProviderTransport().IdleConnTimeout.Seconds()),
+	}
+}
+
+// providerHTTPClient builds a client over the shared transport. The per-
+// provider timeout env var (seconds) is honored when set; the default is no
+// timeout, which streaming requires.
+func providerHTTPClient(timeoutEnvKey string) *http.Client {
+	timeout := time.Duration(0)
+	if value := os.Getenv(timeoutEnvKey); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			timeout = time.Duration(parsed) * time.Second
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: sharedProviderTransport(),
+	}
+}
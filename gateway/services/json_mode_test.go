@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gateway/models"
+)
+
+func TestJSONModeRequested(t *testing.T) {
+	jsonMode := &GenerationOptions{ResponseFormat: &models.ResponseFormat{Type: "json_object"}}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"no options", context.Background(), false},
+		{"options without format", WithGenerationOptions(context.Background(), &GenerationOptions{}), false},
+		{"json_object", WithGenerationOptions(context.Background(), jsonMode), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonModeRequested(tt.ctx); got != tt.want {
+				t.Errorf("jsonModeRequested = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseFormatReachesGroqRequest(t *testing.T) {
+	reqBody := GroqRequest{
+		Model:          "llama-3",
+		ResponseFormat: &models.ResponseFormat{Type: "json_object"},
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"response_format":{"type":"json_object"}`) {
+		t.Errorf("Groq request body missing response_format: %s", data)
+	}
+}
+
+func TestResponseFormatReachesOpenRouterRequest(t *testing.T) {
+	reqBody := OpenRouterRequest{
+		Model:          "gpt-4o",
+		ResponseFormat: &models.ResponseFormat{Type: "json_object"},
+	}
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"response_format":{"type":"json_object"}`) {
+		t.Errorf("OpenRouter request body missing response_format: %s", data)
+	}
+}
+
+func TestResponseFormatReachesGeminiRequest(t *testing.T) {
+	var reqBody GeminiRequest
+	reqBody.GenerationConfig.ResponseMimeType = "application/json"
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"responseMimeType":"application/json"`) {
+		t.Errorf("Gemini request body missing responseMimeType: %s", data)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/models"
+	"gateway/pkg/logger"
+)
+
+// defaultMockResponseText is streamed when MOCK_RESPONSE_TEXT is not set
+const defaultMockResponseText = "This is a deterministic mock response from the gateway. " +
+	"It exercises the full streaming path without calling a real provider."
+
+// defaultMockThinkingText is the canned thinking block for thinking models
+const defaultMockThinkingText = "Considering the request and composing a canned answer."
+
+// mockChunkDelay returns the artificial delay between mock chunks
+// (MOCK_CHUNK_DELAY_MS, default 0)
+func mockChunkDelay() time.Duration {
+	if v := os.Getenv("MOCK_CHUNK_DELAY_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// MockProviderEnabled reports whether the test-only mock provider should
+// handle every request (PROVIDER_OVERRIDE=mock)
+func MockProviderEnabled() bool {
+	return os.Getenv("PROVIDER_OVERRIDE") == "mock"
+}
+
+// StreamMockResponse deterministically streams a canned response through the
+// normal SSE framing: a start frame, word-by-word chunks (with a thinking
+// block first for thinking models), and an end frame. It lets the full
+// ClientHandler flow — auth, rate limiting, classification, streaming,
+// persistence — run end to end without provider API keys. Only reachable
+// when PROVIDER_OVERRIDE=mock.
+func StreamMockResponse(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, prompt string, model string, displayName string, clientID int, previousMessages []models.ChatMessage, profileContext string, isThinkingModel bool) error {
+	startTime := time.Now()
+	delay := mockChunkDelay()
+
+	logger.GetDailyLogger().Info("Mock provider streaming for client %d (model %s, delay %v)", clientID, model, delay)
+
+	// Send start chunk with model display name, matching the real providers
+	startResponse := models.Response{
+		Message: displayName,
+		Type:    "start",
+		Model:   displayName,
+	}
+	if ModeDowngradedFromContext(ctx) {
+		startResponse.ModeDowngraded = true
+		startResponse.DowngradeNotice = DowngradeNotice
+	}
+	if startMsg, err := models.FormatSSEMessage(startResponse); err == nil {
+		fmt.Fprint(w, startMsg)
+		flusher.Flush()
+	}
+
+	text := os.Getenv("MOCK_RESPONSE_TEXT")
+	if text == "" {
+		text = defaultMockResponseText
+	}
+
+	// Thinking models get a canned thinking block first so clients can
+	// exercise their thinking UI
+	chunks := []string{}
+	if isThinkingModel {
+		chunks = append(chunks, "◁think▷"+defaultMockThinkingText+"◁/think▷")
+	}
+	for _, word := range strings.Fields(text) {
+		chunks = append(chunks, word+" ")
+	}
+
+	for _, chunk := range chunks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if streamAuthExpired(ctx) {
+			sendAuthExpiredEndFrame(w, flusher, clientID)
+			return nil
+		}
+
+		chunkResponse := models.Response{
+			Message: chunk,
+			Type:    "chunk",
+		}
+		msg, err := models.FormatSSEMessage(chunkResponse)
+		if err != nil {
+			return fmt.Errorf("error formatting chunk: %v", err)
+		}
+		if _, err := fmt.Fprint(w, msg); err != nil {
+			return fmt.Errorf("error sending chunk: %v", err)
+		}
+		flusher.Flush()
+
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	// Send completion signal using structured format (matching Gemini)
+	finalResponse := models.Response{
+		Type:         "end",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		FinishReason: normalizeFinishReason("stop"),
+	}
+	msg, _ := models.FormatSSEMessage(finalResponse)
+	fmt.Fprint(w, msg)
+	flusher.Flush()
+
+	logger.GetDailyLogger().Info("Mock streaming completed for client %d: %d chunks in %.2fs", clientID, len(chunks), time.Since(startTime).Seconds())
+	return nil
+}
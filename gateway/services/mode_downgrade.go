@@ -0,0 +1,24 @@
+package services
+
+import "context"
+
+// Carries the "pro allotment exhausted, serving free tier" signal from the
+// rate limiter down to the providers, so the start frame can tell the UI the
+// user silently dropped to cheaper models.
+
+type modeDowngradedContextKey struct{}
+
+// DowngradeNotice is the user-facing message attached to downgraded start frames
+const DowngradeNotice = "You've used all your max requests for today; continuing with free models."
+
+// WithModeDowngraded marks the request as served in downgraded (free) mode
+func WithModeDowngraded(ctx context.Context, downgraded bool) context.Context {
+	return context.WithValue(ctx, modeDowngradedContextKey{}, downgraded)
+}
+
+// ModeDowngradedFromContext reports whether this request was downgraded to
+// free mode after the pro allotment ran out
+func ModeDowngradedFromContext(ctx context.Context) bool {
+	downgraded, ok := ctx.Value(modeDowngradedContextKey{}).(bool)
+	return ok && downgraded
+}
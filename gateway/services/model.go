@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gateway/middleware"
@@ -78,29 +80,127 @@ type CircuitBreaker struct {
 	halfOpenMaxCalls int
 }
 
-// Global instances
+// Default classifier settings, overridable via environment variables
+const (
+	defaultClassifierTimeoutMS        = 25000
+	defaultClassifierFailureLimit     = 5
+	defaultClassifierRecoverySeconds  = 30
+	defaultClassifierMaxConcurrency   = 32
+	defaultClassifierAcquireTimeoutMS = 100
+)
+
+// Global instances, initialized lazily so environment overrides are respected
 var (
-	// Circuit breaker for classifier service
-	classifierCircuit = &CircuitBreaker{
-		failureThreshold: 5,
-		recoveryTimeout:  30 * time.Second,
-		halfOpenMaxCalls: 3,
-	}
-
-	// Optimized HTTP client for classifier requests
-	classifierClient = &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        50,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-			TLSHandshakeTimeout: 10 * time.Second,
-			DisableKeepAlives:   false,
-			DisableCompression:  false,
-		},
-	}
+	classifierOnce           sync.Once
+	classifierCircuit        *CircuitBreaker
+	classifierClient         *http.Client
+	classifierTimeout        time.Duration
+	classifierSlots          chan struct{}
+	classifierAcquireTimeout time.Duration
 )
 
+// classifierFallbackLogInterval caps how often the fallback reason is logged
+// at normal level, so a classifier outage doesn't flood the logs
+const classifierFallbackLogInterval = 30 * time.Second
+
+// Fallback tracking for classifier outages
+var (
+	classifierFallbackTotal int64
+	classifierFallbackMu    sync.Mutex
+	lastFallbackLogTime     time.Time
+)
+
+// RecordClassifierFallback counts a classifier fallback and logs the reason at
+// most once per interval. Set DEBUG_FALLBACK=true to log every occurrence.
+func RecordClassifierFallback(reason string) {
+	total := atomic.AddInt64(&classifierFallbackTotal, 1)
+
+	if os.Getenv("DEBUG_FALLBACK") == "true" {
+		logger.GetDailyLogger().Info("Classifier fallback (#%d): %s", total, reason)
+		return
+	}
+
+	classifierFallbackMu.Lock()
+	shouldLog := time.Since(lastFallbackLogTime) >= classifierFallbackLogInterval
+	if shouldLog {
+		lastFallbackLogTime = time.Now()
+	}
+	classifierFallbackMu.Unlock()
+
+	if shouldLog {
+		logger.GetDailyLogger().Warn("Classifier fallback active (%d total): %s", total, reason)
+	}
+}
+
+// GetClassifierFallbackCount returns the number of classifier fallbacks since start
+func GetClassifierFallbackCount() int64 {
+	return atomic.LoadInt64(&classifierFallbackTotal)
+}
+
+// parseClassifierEnvInt reads a positive integer setting from the environment,
+// falling back to the default (with a warning) when missing or invalid
+func parseClassifierEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		logger.GetDailyLogger().Warn("Invalid %s value %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// initClassifier constructs the classifier client and circuit breaker from
+// environment configuration
+func initClassifier() {
+	classifierOnce.Do(func() {
+		timeoutMS := parseClassifierEnvInt("CLASSIFIER_TIMEOUT_MS", defaultClassifierTimeoutMS)
+		failureThreshold := parseClassifierEnvInt("CLASSIFIER_FAILURE_THRESHOLD", defaultClassifierFailureLimit)
+		recoverySeconds := parseClassifierEnvInt("CLASSIFIER_RECOVERY_SECONDS", defaultClassifierRecoverySeconds)
+
+		classifierTimeout = time.Duration(timeoutMS) * time.Millisecond
+
+		// Semaphore bounding concurrent classifier calls: a spike shouldn't
+		// overwhelm the classifier before the circuit breaker notices failures
+		maxConcurrency := parseClassifierEnvInt("CLASSIFIER_MAX_CONCURRENCY", defaultClassifierMaxConcurrency)
+		acquireTimeoutMS := parseClassifierEnvInt("CLASSIFIER_ACQUIRE_TIMEOUT_MS", defaultClassifierAcquireTimeoutMS)
+		classifierSlots = make(chan struct{}, maxConcurrency)
+		classifierAcquireTimeout = time.Duration(acquireTimeoutMS) * time.Millisecond
+
+		// Circuit breaker for classifier service
+		classifierCircuit = &CircuitBreaker{
+			failureThreshold: failureThreshold,
+			recoveryTimeout:  time.Duration(recoverySeconds) * time.Second,
+			halfOpenMaxCalls: 3,
+		}
+
+		// Optimized HTTP client for classifier requests, with headroom over the
+		// per-request context timeout
+		classifierClient = &http.Client{
+			Timeout: classifierTimeout + 5*time.Second,
+			Transport: &http.Transport{
+				MaxIdleConns:        50,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+				DisableKeepAlives:   false,
+				DisableCompression:  false,
+			},
+		}
+	})
+}
+
+// InitClassifierService validates classifier configuration at startup and logs
+// the effective settings
+func InitClassifierService() {
+	initClassifier()
+	logger.GetDailyLogger().Info("Classifier configured: timeout=%v, failure_threshold=%d, recovery_timeout=%v, max_concurrency=%d",
+		classifierTimeout, classifierCircuit.failureThreshold, classifierCircuit.recoveryTimeout, cap(classifierSlots))
+}
+
 // getClassifierURL returns the classifier service URL from environment or default
 func getClassifierURL() string {
 	if url := os.Getenv("CLASSIFIER_URL"); url != "" {
@@ -160,10 +260,40 @@ func (cb *CircuitBreaker) setState(state CircuitState) {
 	cb.state = state
 }
 
+// forceState manually overrides the breaker state and resets its counters
+func (cb *CircuitBreaker) forceState(state CircuitState) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = state
+	cb.failureCount = 0
+	cb.successCount = 0
+}
+
+// SetCircuitBreakerState manually forces the classifier circuit breaker into
+// the given state ("open", "closed" or "half-open")
+func SetCircuitBreakerState(state string) error {
+	initClassifier()
+
+	switch state {
+	case "open":
+		classifierCircuit.forceState(Open)
+	case "closed":
+		classifierCircuit.forceState(Closed)
+	case "half-open":
+		classifierCircuit.forceState(HalfOpen)
+	default:
+		return fmt.Errorf("unknown circuit state: %s", state)
+	}
+	return nil
+}
+
 // CallModelService calls the local model service with optimizations and request type
 func CallModelService(prompt string, requestType middleware.RequestType) (ModelResponse, error) {
+	initClassifier()
+
 	// Check circuit breaker
 	if !classifierCircuit.canExecute() {
+		RecordClassifierFallback("circuit breaker is open")
 		return ModelResponse{}, fmt.Errorf("classifier service circuit breaker is open")
 	}
 
@@ -172,6 +302,16 @@ func CallModelService(prompt string, requestType middleware.RequestType) (ModelR
 		classifierCircuit.setState(HalfOpen)
 	}
 
+	// Bound concurrent classifier calls; waiting briefly for a slot is fine,
+	// queueing behind a spike is not — fall back instead to keep latency bounded
+	select {
+	case classifierSlots <- struct{}{}:
+		defer func() { <-classifierSlots }()
+	case <-time.After(classifierAcquireTimeout):
+		RecordClassifierFallback("concurrency limit reached")
+		return ModelResponse{}, fmt.Errorf("classifier concurrency limit reached")
+	}
+
 	// Convert RequestType to string
 	requestTypeStr := "free"
 	if requestType == middleware.MaxRequest {
@@ -190,7 +330,7 @@ func CallModelService(prompt string, requestType middleware.RequestType) (ModelR
 	}
 
 	// Create request with context and timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), classifierTimeout)
 	defer cancel()
 
 	classifierURL := getClassifierURL()
@@ -208,12 +348,14 @@ func CallModelService(prompt string, requestType middleware.RequestType) (ModelR
 	resp, err := classifierClient.Do(req)
 	if err != nil {
 		classifierCircuit.onFailure()
+		RecordClassifierFallback(fmt.Sprintf("request failed: %v", err))
 		return ModelResponse{}, fmt.Errorf("error calling model service: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		classifierCircuit.onFailure()
+		RecordClassifierFallback(fmt.Sprintf("classifier returned status %d", resp.StatusCode))
 		return ModelResponse{}, fmt.Errorf("classifier service returned status %d", resp.StatusCode)
 	}
 
@@ -241,11 +383,14 @@ func CallModelServiceWithFallback(prompt string) (ModelResponse, error) {
 
 // GetCircuitBreakerStats returns circuit breaker statistics for monitoring
 func GetCircuitBreakerStats() map[string]interface{} {
+	initClassifier()
+
 	classifierCircuit.mu.RLock()
 	defer classifierCircuit.mu.RUnlock()
 
 	return map[string]interface{}{
-		"circuit_state": classifierCircuit.state,
-		"failure_count": classifierCircuit.failureCount,
+		"circuit_state":             classifierCircuit.state,
+		"failure_count":             classifierCircuit.failureCount,
+		"classifier_fallback_total": GetClassifierFallbackCount(),
 	}
 }
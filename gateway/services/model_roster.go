@@ -0,0 +1,47 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// The classifier owns the model roster; the gateway keeps a copy so it can
+// answer questions about available models without calling out. The roster is
+// pushed by the classifier through the internal models-updated webhook rather
+// than polled, so the copy only changes when the roster actually does.
+
+// ModelRosterEntry describes one model the classifier can route to
+type ModelRosterEntry struct {
+	Name            string `json:"name"`
+	DisplayName     string `json:"display_name"`
+	Provider        string `json:"provider"`
+	Tier            string `json:"tier"`
+	IsThinkingModel bool   `json:"is_thinking_model"`
+}
+
+var (
+	modelRosterMu        sync.RWMutex
+	modelRoster          []ModelRosterEntry
+	modelRosterUpdatedAt time.Time
+)
+
+// SetModelRoster atomically replaces the cached model roster
+func SetModelRoster(entries []ModelRosterEntry) {
+	modelRosterMu.Lock()
+	defer modelRosterMu.Unlock()
+
+	modelRoster = make([]ModelRosterEntry, len(entries))
+	copy(modelRoster, entries)
+	modelRosterUpdatedAt = time.Now()
+}
+
+// GetModelRoster returns a copy of the cached roster and when it was last
+// updated. An empty roster with a zero time means no update has arrived yet.
+func GetModelRoster() ([]ModelRosterEntry, time.Time) {
+	modelRosterMu.RLock()
+	defer modelRosterMu.RUnlock()
+
+	entries := make([]ModelRosterEntry, len(modelRoster))
+	copy(entries, modelRoster)
+	return entries, modelRosterUpdatedAt
+}
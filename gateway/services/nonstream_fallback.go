@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gateway/models"
+	"gateway/pkg/logger"
+)
+
+// Some proxies strip SSE: the provider replies 200 with a plain JSON body
+// despite stream:true, and the scanner loop sees no data: lines, so the
+// stream silently produces nothing. When the response Content-Type is not
+// text/event-stream the streamers fall back to reading the whole body and
+// emitting it as a single chunk through the normal SSE framing.
+
+// nonStreamFallbackEnabled reports whether the non-streaming fallback is
+// active (NONSTREAM_FALLBACK, default true)
+func nonStreamFallbackEnabled() bool {
+	return os.Getenv("NONSTREAM_FALLBACK") != "false"
+}
+
+// isSSEResponse reports whether the provider honored streaming, judged by
+// the response Content-Type
+func isSSEResponse(resp *http.Response) bool {
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream")
+}
+
+// extractOpenAIStyleContent pulls the message content and finish reason from
+// a non-streaming OpenAI-style completion body (Groq, OpenRouter)
+func extractOpenAIStyleContent(body []byte) (content, finishReason string, err error) {
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", "", fmt.Errorf("error parsing non-streaming response: %v", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", "", fmt.Errorf("non-streaming response contained no choices")
+	}
+	return parsed.Choices[0].Message.Content, parsed.Choices[0].FinishReason, nil
+}
+
+// extractGeminiContent pulls the text and finish reason from a non-streaming
+// Gemini body, which may be a single object or an array of chunk objects
+// (streamGenerateContent without alt=sse returns an array)
+func extractGeminiContent(body []byte) (content, finishReason string, err error) {
+	type geminiChunk struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	var chunks []geminiChunk
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &chunks); err != nil {
+			return "", "", fmt.Errorf("error parsing non-streaming response: %v", err)
+		}
+	} else {
+		var single geminiChunk
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return "", "", fmt.Errorf("error parsing non-streaming response: %v", err)
+		}
+		chunks = []geminiChunk{single}
+	}
+
+	var text strings.Builder
+	for _, chunk := range chunks {
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			text.WriteString(part.Text)
+		}
+		if chunk.Candidates[0].FinishReason != "" {
+			finishReason = chunk.Candidates[0].FinishReason
+		}
+	}
+	if text.Len() == 0 {
+		return "", "", fmt.Errorf("non-streaming response contained no text")
+	}
+	return text.String(), finishReason, nil
+}
+
+// emitNonStreamingContent sends a complete provider response as a single
+// chunk followed by a normal end frame. The start frame has already been
+// sent by the caller.
+func emitNonStreamingContent(w http.ResponseWriter, flusher http.Flusher, provider string, clientID int, content, finishReason string) error {
+	chunkResponse := models.Response{
+		Message: content,
+		Type:    "chunk",
+	}
+	msg, err := models.FormatSSEMessage(chunkResponse)
+	if err != nil {
+		return fmt.Errorf("error formatting chunk: %v", err)
+	}
+	if _, err := fmt.Fprint(w, msg); err != nil {
+		return fmt.Errorf("error sending chunk: %v", err)
+	}
+	flusher.Flush()
+
+	// A complete body is a normal stop unless the provider said otherwise
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	finalResponse := models.Response{
+		Type:         "end",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		FinishReason: normalizeFinishReason(finishReason),
+	}
+	endMsg, _ := models.FormatSSEMessage(finalResponse)
+	fmt.Fprint(w, endMsg)
+	flusher.Flush()
+
+	logger.GetDailyLogger().Info("Non-streaming fallback for %s sent %d bytes as one chunk for client %d", provider, len(content), clientID)
+	return nil
+}
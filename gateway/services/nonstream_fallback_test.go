@@ -0,0 +1,94 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNonStreamFallbackEnabled(t *testing.T) {
+	t.Setenv("NONSTREAM_FALLBACK", "")
+	if !nonStreamFallbackEnabled() {
+		t.Error("fallback should be enabled by default")
+	}
+
+	t.Setenv("NONSTREAM_FALLBACK", "false")
+	if nonStreamFallbackEnabled() {
+		t.Error("NONSTREAM_FALLBACK=false should disable the fallback")
+	}
+}
+
+func TestIsSSEResponse(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/event-stream", true},
+		{"text/event-stream; charset=utf-8", true},
+		{"Text/Event-Stream", true},
+		{"application/json", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.contentType != "" {
+			resp.Header.Set("Content-Type", tt.contentType)
+		}
+		if got := isSSEResponse(resp); got != tt.want {
+			t.Errorf("isSSEResponse(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestExtractOpenAIStyleContent(t *testing.T) {
+	body := []byte(`{"choices":[{"message":{"content":"hello there"},"finish_reason":"stop"}]}`)
+	content, finishReason, err := extractOpenAIStyleContent(body)
+	if err != nil {
+		t.Fatalf("extractOpenAIStyleContent: %v", err)
+	}
+	if content != "hello there" {
+		t.Errorf("content = %q, want %q", content, "hello there")
+	}
+	if finishReason != "stop" {
+		t.Errorf("finishReason = %q, want %q", finishReason, "stop")
+	}
+
+	if _, _, err := extractOpenAIStyleContent([]byte(`{"choices":[]}`)); err == nil {
+		t.Error("a response with no choices should fail")
+	}
+	if _, _, err := extractOpenAIStyleContent([]byte(`not json`)); err == nil {
+		t.Error("malformed JSON should fail")
+	}
+}
+
+func TestExtractGeminiContent(t *testing.T) {
+	single := []byte(`{"candidates":[{"content":{"parts":[{"text":"hello"}]},"finishReason":"STOP"}]}`)
+	content, finishReason, err := extractGeminiContent(single)
+	if err != nil {
+		t.Fatalf("extractGeminiContent(object): %v", err)
+	}
+	if content != "hello" || finishReason != "STOP" {
+		t.Errorf("object form = (%q, %q), want (hello, STOP)", content, finishReason)
+	}
+
+	// A non-streamed Gemini body can also be an array of chunks whose text
+	// parts concatenate in order
+	chunked := []byte(`[
+		{"candidates":[{"content":{"parts":[{"text":"hel"}]}}]},
+		{"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"STOP"}]}
+	]`)
+	content, finishReason, err = extractGeminiContent(chunked)
+	if err != nil {
+		t.Fatalf("extractGeminiContent(array): %v", err)
+	}
+	if content != "hello" || finishReason != "STOP" {
+		t.Errorf("array form = (%q, %q), want (hello, STOP)", content, finishReason)
+	}
+
+	if _, _, err := extractGeminiContent([]byte(`{"candidates":[]}`)); err == nil {
+		t.Error("a response with no text should fail")
+	}
+	if _, _, err := extractGeminiContent([]byte(`not json`)); err == nil {
+		t.Error("malformed JSON should fail")
+	}
+}
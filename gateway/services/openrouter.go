@@ -24,10 +24,14 @@ type OpenRouterMessage struct {
 
 // OpenRouterRequest represents the request to OpenRouter API
 type OpenRouterRequest struct {
-	Model    string                 `json:"model"`
-	Messages []OpenRouterMessage    `json:"messages"`
-	Stream   bool                   `json:"stream"`
-	Options  map[string]interface{} `json:"options,omitempty"`
+	Model      string                 `json:"model"`
+	Messages   []OpenRouterMessage    `json:"messages"`
+	Stream     bool                   `json:"stream"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+	Tools      []models.Tool          `json:"tools,omitempty"`
+	ToolChoice interface{}            `json:"tool_choice,omitempty"`
+	Seed           *int                   `json:"seed,omitempty"`
+	ResponseFormat *models.ResponseFormat `json:"response_format,omitempty"`
 }
 
 // OpenRouterResponse represents the streaming response from OpenRouter API
@@ -39,9 +43,10 @@ type OpenRouterResponse struct {
 	Choices []struct {
 		Index int `json:"index"`
 		Delta struct {
-			Role      string `json:"role"`
-			Content   string `json:"content"`
-			Reasoning string `json:"reasoning"`
+			Role      string                 `json:"role"`
+			Content   string                 `json:"content"`
+			Reasoning string                 `json:"reasoning"`
+			ToolCalls []models.ToolCallDelta `json:"tool_calls,omitempty"`
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
@@ -58,29 +63,10 @@ var (
 	openRouterOnce   sync.Once
 )
 
-// initOpenRouterClient initializes the optimized HTTP client for OpenRouter
+// initOpenRouterClient initializes the OpenRouter HTTP client over the shared transport
 func initOpenRouterClient() {
 	openRouterOnce.Do(func() {
-		openRouterClient = &http.Client{
-			Timeout: 0, // No timeout for streaming
-			Transport: &http.Transport{
-				MaxIdleConns:        20,                // Max idle connections
-				MaxIdleConnsPerHost: 5,                 // Max idle per host
-				MaxConnsPerHost:     10,                // Max total per host
-				IdleConnTimeout:     120 * time.Second, // Keep connections alive longer
-				TLSHandshakeTimeout: 10 * time.Second,
-
-				// Streaming optimizations
-				DisableKeepAlives:  false,
-				DisableCompression: true,      // Disable compression for streaming
-				WriteBufferSize:    32 * 1024, // 32KB write buffer
-				ReadBufferSize:     32 * 1024, // 32KB read buffer
-
-				// Connection timeouts
-				ResponseHeaderTimeout: 30 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		}
+		openRouterClient = providerHTTPClient("OPENROUTER_HTTP_TIMEOUT_SECONDS")
 	})
 }
 
@@ -109,8 +95,8 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 	// Get API key and base URL from environment
 	apiKey, baseURL := getOpenRouterConfig()
 
-	// Get the system prompt
-	systemPrompt := models.Config.GetSystemPrompt("openrouter")
+	// Get the system prompt, rendering any template variables for this request
+	systemPrompt := renderSystemPrompt(ctx, models.Config.GetSystemPrompt("openrouter"))
 
 	// Format messages for OpenRouter
 	messages := []OpenRouterMessage{}
@@ -131,22 +117,11 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		})
 	}
 
-	// 3. Build the conversational history with proper roles
-	// Filter out thinking blocks from previous messages
-	filteredMessages := []models.ChatMessage{}
-	for _, msg := range previousMessages {
-		if !strings.Contains(msg.Content, "◁think▷") && !strings.Contains(msg.Content, "◁/think▷") {
-			filteredMessages = append(filteredMessages, msg)
-		}
-	}
-
-	// Process up to the last 4 messages for context
-	startIdx := 0
-	if len(filteredMessages) > 4 {
-		startIdx = len(filteredMessages) - 4
-	}
+	// 3. Build the conversational history with proper roles, windowed and
+	// trimmed to the context budget
+	historyMessages := assembleHistoryWindow(ctx, previousMessages, prompt, finalSystemPrompt, clientID)
 
-	for _, msg := range filteredMessages[startIdx:] {
+	for _, msg := range historyMessages {
 		// Use clean message content without prefixes
 		messages = append(messages, OpenRouterMessage{
 			Role:    msg.Role,    // Keep original role (user/assistant)
@@ -160,6 +135,15 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		Content: prompt, // The current prompt is the last thing the user said
 	})
 
+	// Optional prompt-assembly debug logging (LOG_ASSEMBLED_PROMPT)
+	if assembledPromptLoggingEnabled() {
+		assembled := make([]assembledMessage, 0, len(messages))
+		for _, msg := range messages {
+			assembled = append(assembled, assembledMessage{role: msg.Role, content: msg.Content})
+		}
+		logAssembledPrompt("openrouter", clientID, assembled)
+	}
+
 	// Create the request body
 	reqBody := OpenRouterRequest{
 		Model:    model,
@@ -171,12 +155,35 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		},
 	}
 
+	// Pass through tool declarations when the client supplied them
+	if toolConfig := GetToolConfigFromContext(ctx); toolConfig != nil {
+		reqBody.Tools = toolConfig.Tools
+		reqBody.ToolChoice = toolConfig.ToolChoice
+	}
+
+	// Pass through the seed and response format when supplied
+	if opts := GetGenerationOptionsFromContext(ctx); opts != nil {
+		reqBody.Seed = opts.Seed
+		reqBody.ResponseFormat = opts.ResponseFormat
+	}
+
+	// JSON-mode responses won't contain think tags, so skip marker processing
+	if jsonModeRequested(ctx) {
+		isThinkingModel = false
+	}
+
 	// Prepare optimized request
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return fmt.Errorf("error marshaling request: %v", err)
 	}
 
+	// Sampled debug logging of the exact provider exchange
+	debugSampled := providerDebugSampled()
+	if debugSampled {
+		logProviderDebugRequest("openrouter", clientID, baseURL+"/chat/completions", jsonData)
+	}
+
 	// Create request with context for cancellation
 	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -208,6 +215,10 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		Type:    "start",
 		Model:   displayName,
 	}
+	if ModeDowngradedFromContext(ctx) {
+		startResponse.ModeDowngraded = true
+		startResponse.DowngradeNotice = DowngradeNotice
+	}
 
 	startMsg, err := models.FormatSSEMessage(startResponse)
 	if err == nil {
@@ -215,6 +226,27 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		flusher.Flush()
 	}
 
+	// A proxy may have stripped SSE despite stream:true; emit the full JSON
+	// body as one chunk rather than silently producing an empty stream
+	if nonStreamFallbackEnabled() && !isSSEResponse(resp) {
+		logger.GetDailyLogger().Warn("OpenRouter returned non-SSE Content-Type %q for client %d, using non-streaming fallback", resp.Header.Get("Content-Type"), clientID)
+		body, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return fmt.Errorf("error reading non-streaming response: %v", readErr)
+		}
+		content, finishReason, extractErr := extractOpenAIStyleContent(body)
+		if extractErr != nil {
+			return extractErr
+		}
+		return emitNonStreamingContent(w, flusher, "OpenRouter", clientID, content, finishReason)
+	}
+
+	// Optionally coalesce tiny writes/flushes (SSE_BATCH_MS / SSE_BATCH_BYTES)
+	if batcher := newSSEBatcher(w, flusher); batcher != nil {
+		w, flusher = batcher, batcher
+		defer batcher.FlushFinal()
+	}
+
 	// Stream processing with optimized buffering
 	scanner := bufio.NewScanner(resp.Body)
 
@@ -223,10 +255,19 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 	scanner.Buffer(buf, 64*1024)
 
 	chunkCount := 0
+	totalTokens := 0
+	transformer := newResponseTransformer()
 	var fullResponse strings.Builder
 	var inReasoning bool = false
+	var streamFinishReason string
 
 	for scanner.Scan() {
+		// Optionally stop streaming once the caller's token has expired
+		if streamAuthExpired(ctx) {
+			sendAuthExpiredEndFrame(w, flusher, clientID)
+			return nil
+		}
+
 		line := scanner.Text()
 		if len(line) == 0 {
 			continue
@@ -268,9 +309,27 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 
 			chunkCount++
 
+			// OpenRouter reports token usage on the final chunk; keep the
+			// last one seen
+			if streamResp.Usage.TotalTokens > 0 {
+				totalTokens = streamResp.Usage.TotalTokens
+			}
+
 			// Extract the response part
 			if len(streamResp.Choices) > 0 {
-				content := streamResp.Choices[0].Delta.Content
+				// Keep the last finish_reason seen for the end frame
+				if reason := streamResp.Choices[0].FinishReason; reason != "" {
+					streamFinishReason = reason
+				}
+
+				// Forward tool-call deltas as distinct frames, not text chunks
+				if toolCalls := streamResp.Choices[0].Delta.ToolCalls; len(toolCalls) > 0 {
+					if err := sendToolCallFrame(w, flusher, toolCalls); err != nil {
+						return err
+					}
+				}
+
+				content := transformer.Transform(streamResp.Choices[0].Delta.Content)
 				reasoning := streamResp.Choices[0].Delta.Reasoning
 
 				// Handle reasoning state transitions and send appropriate markers only for thinking models
@@ -350,10 +409,24 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 		return fmt.Errorf("error reading response: %v", err)
 	}
 
+	// Emit anything the transformer was still buffering
+	if tail := transformer.Flush(); tail != "" {
+		fullResponse.WriteString(tail)
+		tailResponse := models.Response{
+			Message: tail,
+			Type:    "chunk",
+		}
+		if msg, err := models.FormatSSEMessage(tailResponse); err == nil {
+			fmt.Fprint(w, msg)
+			flusher.Flush()
+		}
+	}
+
 	// Send completion signal using structured format (matching Gemini)
 	finalResponse := models.Response{
-		Type:      "end",
-		Timestamp: time.Now().Format(time.RFC3339),
+		Type:         "end",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		FinishReason: normalizeFinishReason(streamFinishReason),
 	}
 
 	msg, _ := models.FormatSSEMessage(finalResponse)
@@ -362,6 +435,12 @@ func StreamOpenRouterResponse(ctx context.Context, w http.ResponseWriter, flushe
 
 	logger.GetDailyLogger().Info("OpenRouter streaming completed for client %d: %d chunks in %.2fs", clientID, chunkCount, time.Since(startTime).Seconds())
 
+	if debugSampled {
+		logProviderDebugResponse("openrouter", clientID, fullResponse.String())
+	}
+
+	recordStreamCost(ctx, model, totalTokens)
+
 	return nil
 }
 
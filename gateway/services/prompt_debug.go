@@ -0,0 +1,46 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gateway/pkg/logger"
+)
+
+// LOG_ASSEMBLED_PROMPT=true logs the final role/content sequence each
+// provider assembles — system prompt, trimmed history, current prompt —
+// before the request is marshaled. Unlike the sampled raw-request logging in
+// provider_debug.go, this is for prompt-engineering: the assembly logic
+// differs per provider and the prompts themselves are the point, so only
+// length is bounded and nothing but API keys is redacted (none appear in the
+// assembled messages).
+
+// assembledPromptContentLimit bounds each logged message's content
+const assembledPromptContentLimit = 2048
+
+// assembledMessage is one entry of a provider's final prompt assembly
+type assembledMessage struct {
+	role    string
+	content string
+}
+
+// assembledPromptLoggingEnabled reports whether prompt-assembly logging is on
+// (LOG_ASSEMBLED_PROMPT=true)
+func assembledPromptLoggingEnabled() bool {
+	return os.Getenv("LOG_ASSEMBLED_PROMPT") == "true"
+}
+
+// logAssembledPrompt logs the assembled messages for one provider call, one
+// line per message with content truncated to the limit
+func logAssembledPrompt(provider string, clientID int, messages []assembledMessage) {
+	var sb strings.Builder
+	for i, msg := range messages {
+		content := msg.content
+		if len(content) > assembledPromptContentLimit {
+			content = fmt.Sprintf("%s... (truncated, %d bytes total)", content[:assembledPromptContentLimit], len(msg.content))
+		}
+		fmt.Fprintf(&sb, "\n  [%d] %s: %s", i, msg.role, content)
+	}
+	logger.GetDailyLogger().Info("Assembled prompt [%s] client %d, %d messages:%s", provider, clientID, len(messages), sb.String())
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+
+	"gateway/models"
+)
+
+// promptVarsContextKey is the context key for per-request prompt variables
+type promptVarsContextKey struct{}
+
+// WithPromptVars returns a context carrying the request's prompt template variables
+func WithPromptVars(ctx context.Context, vars *models.PromptTemplateVars) context.Context {
+	return context.WithValue(ctx, promptVarsContextKey{}, vars)
+}
+
+// GetPromptVarsFromContext returns the request's prompt template variables, or nil
+func GetPromptVarsFromContext(ctx context.Context) *models.PromptTemplateVars {
+	vars, _ := ctx.Value(promptVarsContextKey{}).(*models.PromptTemplateVars)
+	return vars
+}
+
+// renderSystemPrompt applies the request's template variables to a system
+// prompt; prompts without template variables pass through unchanged
+func renderSystemPrompt(ctx context.Context, prompt string) string {
+	vars := GetPromptVarsFromContext(ctx)
+	if vars == nil {
+		return prompt
+	}
+	return models.RenderPromptTemplate(prompt, *vars)
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"gateway/pkg/logger"
+)
+
+// Sampled provider debug logging. Dumping every provider request would flood
+// the logs and persist prompts wholesale, but seeing the exact bytes sent and
+// a preview of what came back is often the only way to diagnose a provider
+// rejection. PROVIDER_DEBUG_SAMPLE_RATE (0..1) logs that fraction of streams
+// in full, with API keys redacted; 0 (the default) disables it.
+
+// providerDebugPreviewLimit bounds the logged response preview
+const providerDebugPreviewLimit = 2048
+
+var (
+	providerDebugOnce sync.Once
+	providerDebugRate float64
+
+	// Gemini carries its API key in the URL query string
+	apiKeyQueryPattern = regexp.MustCompile(`(key=)[^&\s]+`)
+)
+
+func loadProviderDebugRate() {
+	providerDebugOnce.Do(func() {
+		if value := os.Getenv("PROVIDER_DEBUG_SAMPLE_RATE"); value != "" {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 && parsed <= 1 {
+				providerDebugRate = parsed
+			}
+		}
+	})
+}
+
+// providerDebugSampled decides once per stream whether to debug-log it
+func providerDebugSampled() bool {
+	loadProviderDebugRate()
+	return providerDebugRate > 0 && rand.Float64() < providerDebugRate
+}
+
+// redactProviderSecrets strips API keys from a URL or body before logging
+func redactProviderSecrets(s string) string {
+	return apiKeyQueryPattern.ReplaceAllString(s, "${1}REDACTED")
+}
+
+// logProviderDebugRequest logs the exact marshaled request body for a sampled
+// stream. Auth headers are never logged; keys embedded in the URL are redacted.
+func logProviderDebugRequest(provider string, clientID int, url string, body []byte) {
+	logger.GetDailyLogger().Info("Provider debug [%s] client %d request to %s: %s", provider, clientID, redactProviderSecrets(url), redactProviderSecrets(string(body)))
+}
+
+// logProviderDebugResponse logs a bounded preview of the assembled response
+// for a sampled stream
+func logProviderDebugResponse(provider string, clientID int, response string) {
+	preview := response
+	if len(preview) > providerDebugPreviewLimit {
+		preview = preview[:providerDebugPreviewLimit] + "... (truncated)"
+	}
+	logger.GetDailyLogger().Info("Provider debug [%s] client %d response preview (%d bytes total): %s", provider, clientID, len(response), preview)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Rolling-window settings for provider health tracking
+const (
+	providerHealthWindow     = 5 * time.Minute
+	providerHealthMaxSamples = 100
+	providerHealthMinSamples = 3
+)
+
+// providerOutcome records a single attempt against a provider
+type providerOutcome struct {
+	timestamp time.Time
+	success   bool
+	latency   time.Duration
+}
+
+// Per-provider rolling outcome history
+var (
+	providerHealthMu sync.Mutex
+	providerOutcomes = map[string][]providerOutcome{}
+)
+
+// AdaptiveFallbackEnabled reports whether health-based reordering of the
+// fallback list is enabled (ADAPTIVE_FALLBACK=true, default off)
+func AdaptiveFallbackEnabled() bool {
+	return os.Getenv("ADAPTIVE_FALLBACK") == "true"
+}
+
+// RecordProviderResult records the outcome of a provider attempt for the
+// rolling health window
+func RecordProviderResult(provider string, success bool, latency time.Duration) {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+
+	outcomes := pruneOutcomes(providerOutcomes[provider])
+	outcomes = append(outcomes, providerOutcome{
+		timestamp: time.Now(),
+		success:   success,
+		latency:   latency,
+	})
+	if len(outcomes) > providerHealthMaxSamples {
+		outcomes = outcomes[len(outcomes)-providerHealthMaxSamples:]
+	}
+	providerOutcomes[provider] = outcomes
+}
+
+// pruneOutcomes drops samples that have aged out of the rolling window
+func pruneOutcomes(outcomes []providerOutcome) []providerOutcome {
+	cutoff := time.Now().Add(-providerHealthWindow)
+	for i, outcome := range outcomes {
+		if outcome.timestamp.After(cutoff) {
+			return outcomes[i:]
+		}
+	}
+	return nil
+}
+
+// GetProviderHealthScore returns a score for ordering providers: higher means
+// healthier. Providers without enough samples get a neutral score so new or
+// idle providers aren't penalized.
+func GetProviderHealthScore(provider string) float64 {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+
+	outcomes := pruneOutcomes(providerOutcomes[provider])
+	providerOutcomes[provider] = outcomes
+
+	if len(outcomes) < providerHealthMinSamples {
+		return 0.5
+	}
+
+	successes := 0
+	var totalLatency time.Duration
+	for _, outcome := range outcomes {
+		if outcome.success {
+			successes++
+		}
+		totalLatency += outcome.latency
+	}
+
+	successRate := float64(successes) / float64(len(outcomes))
+	avgLatencySeconds := (totalLatency / time.Duration(len(outcomes))).Seconds()
+
+	// Success rate dominates; latency breaks ties between healthy providers
+	return successRate - avgLatencySeconds/1000
+}
+
+// IsProviderUnhealthy reports whether a provider's recent success rate is bad
+// enough to justify demoting the classifier's top choice
+func IsProviderUnhealthy(provider string) bool {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+
+	outcomes := pruneOutcomes(providerOutcomes[provider])
+	providerOutcomes[provider] = outcomes
+
+	if len(outcomes) < providerHealthMinSamples {
+		return false
+	}
+
+	successes := 0
+	for _, outcome := range outcomes {
+		if outcome.success {
+			successes++
+		}
+	}
+	return float64(successes)/float64(len(outcomes)) < 0.5
+}
+
+// GetProviderHealthStats returns per-provider rolling-window stats for metrics
+func GetProviderHealthStats() map[string]interface{} {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+
+	stats := map[string]interface{}{}
+	for provider, outcomes := range providerOutcomes {
+		outcomes = pruneOutcomes(outcomes)
+		providerOutcomes[provider] = outcomes
+		if len(outcomes) == 0 {
+			continue
+		}
+
+		successes := 0
+		var totalLatency time.Duration
+		for _, outcome := range outcomes {
+			if outcome.success {
+				successes++
+			}
+			totalLatency += outcome.latency
+		}
+
+		stats[provider] = map[string]interface{}{
+			"samples":        len(outcomes),
+			"success_rate":   float64(successes) / float64(len(outcomes)),
+			"avg_latency_ms": (totalLatency / time.Duration(len(outcomes))).Milliseconds(),
+		}
+	}
+	return stats
+}
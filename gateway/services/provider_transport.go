@@ -0,0 +1,42 @@
+package services
+
+import (
+	"os"
+	"strconv"
+)
+
+// Connection pool sizing shared by the three provider HTTP clients. The old
+// hard-coded MaxConnsPerHost of 10 serialized provider requests under high
+// concurrency. Note these caps only bound our side of the connection: the
+// providers still enforce their own rate limits, so raising them converts
+// queuing in our transport into 429s from the provider once those limits are
+// hit. Keep PROVIDER_MAX_CONNS_PER_HOST at or below the provider's
+// concurrency allowance if it has one.
+const (
+	defaultProviderMaxIdleConns        = 100
+	defaultProviderMaxIdleConnsPerHost = 20
+	defaultProviderMaxConnsPerHost     = 0 // 0 = no cap; provider rate limits bound us anyway
+)
+
+// providerPoolSize reads a pool-size override from the environment, keeping
+// the default on missing or invalid values
+func providerPoolSize(envKey string, fallback int) int {
+	if value := os.Getenv(envKey); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func providerMaxIdleConns() int {
+	return providerPoolSize("PROVIDER_MAX_IDLE_CONNS", defaultProviderMaxIdleConns)
+}
+
+func providerMaxIdleConnsPerHost() int {
+	return providerPoolSize("PROVIDER_MAX_IDLE_CONNS_PER_HOST", defaultProviderMaxIdleConnsPerHost)
+}
+
+func providerMaxConnsPerHost() int {
+	return providerPoolSize("PROVIDER_MAX_CONNS_PER_HOST", defaultProviderMaxConnsPerHost)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gateway/pkg/logger"
+)
+
+// Disabled provider set, seeded from the DISABLED_PROVIDERS env var
+// (comma-separated) and hot-reloadable via the admin API
+var (
+	disabledProvidersMu   sync.RWMutex
+	disabledProviders     map[string]bool
+	disabledProvidersOnce sync.Once
+)
+
+// initDisabledProviders seeds the disabled set from the environment
+func initDisabledProviders() {
+	disabledProvidersOnce.Do(func() {
+		disabledProviders = normalizeProviderSet(strings.Split(os.Getenv("DISABLED_PROVIDERS"), ","))
+		if len(disabledProviders) > 0 {
+			logger.GetDailyLogger().Warn("Providers disabled via DISABLED_PROVIDERS: %v", providerSetToList(disabledProviders))
+		}
+	})
+}
+
+// normalizeProviderSet trims, lowercases and deduplicates provider names
+func normalizeProviderSet(providers []string) map[string]bool {
+	set := map[string]bool{}
+	for _, provider := range providers {
+		provider = strings.TrimSpace(strings.ToLower(provider))
+		if provider != "" {
+			set[provider] = true
+		}
+	}
+	return set
+}
+
+// providerSetToList returns the set's members in sorted order
+func providerSetToList(set map[string]bool) []string {
+	providers := make([]string, 0, len(set))
+	for provider := range set {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// IsProviderDisabled reports whether a provider is currently disabled
+func IsProviderDisabled(provider string) bool {
+	initDisabledProviders()
+
+	disabledProvidersMu.RLock()
+	defer disabledProvidersMu.RUnlock()
+	return disabledProviders[strings.ToLower(provider)]
+}
+
+// SetDisabledProviders replaces the disabled provider set at runtime
+func SetDisabledProviders(providers []string) {
+	initDisabledProviders()
+
+	disabledProvidersMu.Lock()
+	disabledProviders = normalizeProviderSet(providers)
+	disabledProvidersMu.Unlock()
+}
+
+// GetDisabledProviders returns the currently disabled providers, sorted
+func GetDisabledProviders() []string {
+	initDisabledProviders()
+
+	disabledProvidersMu.RLock()
+	defer disabledProvidersMu.RUnlock()
+	return providerSetToList(disabledProviders)
+}
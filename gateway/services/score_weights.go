@@ -0,0 +1,50 @@
+package services
+
+import (
+	"os"
+	"strconv"
+)
+
+// Optional gateway-side rescoring of the classifier's candidates. The
+// classifier bakes its quality/cost tradeoff into FinalScore at training
+// time; these weights let operators bias toward cost or quality globally
+// without retraining.
+
+// Weights used when rescoring is on but only one of the two is configured
+const (
+	defaultScoreWeightQuality = 1.0
+	defaultScoreWeightCost    = 1.0
+)
+
+// ScoreWeights returns the configured quality and cost weights
+// (SCORE_WEIGHT_QUALITY / SCORE_WEIGHT_COST). ok is false when neither is
+// set, in which case the classifier's own ordering is kept untouched.
+func ScoreWeights() (quality, cost float64, ok bool) {
+	qualityEnv := os.Getenv("SCORE_WEIGHT_QUALITY")
+	costEnv := os.Getenv("SCORE_WEIGHT_COST")
+	if qualityEnv == "" && costEnv == "" {
+		return 0, 0, false
+	}
+
+	quality = defaultScoreWeightQuality
+	if qualityEnv != "" {
+		if parsed, err := strconv.ParseFloat(qualityEnv, 64); err == nil && parsed >= 0 {
+			quality = parsed
+		}
+	}
+
+	cost = defaultScoreWeightCost
+	if costEnv != "" {
+		if parsed, err := strconv.ParseFloat(costEnv, 64); err == nil && parsed >= 0 {
+			cost = parsed
+		}
+	}
+
+	return quality, cost, true
+}
+
+// RescoreModel recomputes a candidate's score from its normalized quality
+// and cost using the configured weights
+func RescoreModel(score ModelScore, qualityWeight, costWeight float64) float64 {
+	return qualityWeight*score.NormalizedQuality - costWeight*score.NormalizedCost
+}
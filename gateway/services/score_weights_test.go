@@ -0,0 +1,51 @@
+package services
+
+import "testing"
+
+func TestScoreWeights(t *testing.T) {
+	t.Setenv("SCORE_WEIGHT_QUALITY", "")
+	t.Setenv("SCORE_WEIGHT_COST", "")
+	if _, _, ok := ScoreWeights(); ok {
+		t.Error("ScoreWeights should report ok=false when neither env var is set")
+	}
+
+	t.Setenv("SCORE_WEIGHT_QUALITY", "2.5")
+	quality, cost, ok := ScoreWeights()
+	if !ok {
+		t.Fatal("ScoreWeights should report ok=true when a weight is set")
+	}
+	if quality != 2.5 {
+		t.Errorf("quality weight = %v, want 2.5", quality)
+	}
+	if cost != defaultScoreWeightCost {
+		t.Errorf("cost weight = %v, want the default %v", cost, defaultScoreWeightCost)
+	}
+
+	t.Setenv("SCORE_WEIGHT_COST", "0.1")
+	_, cost, _ = ScoreWeights()
+	if cost != 0.1 {
+		t.Errorf("cost weight with override = %v, want 0.1", cost)
+	}
+
+	// Negative and malformed values fall back to the defaults
+	t.Setenv("SCORE_WEIGHT_QUALITY", "-1")
+	quality, _, _ = ScoreWeights()
+	if quality != defaultScoreWeightQuality {
+		t.Errorf("quality weight with negative override = %v, want the default %v", quality, defaultScoreWeightQuality)
+	}
+}
+
+func TestRescoreModelOrdering(t *testing.T) {
+	premium := ModelScore{NormalizedQuality: 0.9, NormalizedCost: 0.8}
+	budget := ModelScore{NormalizedQuality: 0.5, NormalizedCost: 0.1}
+
+	// Quality-heavy weights prefer the premium model
+	if RescoreModel(premium, 10, 1) <= RescoreModel(budget, 10, 1) {
+		t.Error("quality-heavy weights should rank the premium model first")
+	}
+
+	// Cost-heavy weights prefer the budget model
+	if RescoreModel(budget, 1, 10) <= RescoreModel(premium, 1, 10) {
+		t.Error("cost-heavy weights should rank the budget model first")
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Optional coalescing of SSE writes. Token-by-token providers trigger a write
+// and a flush per chunk — thousands of tiny syscalls per stream at scale. The
+// batcher suppresses intermediate flushes until either SSE_BATCH_BYTES bytes
+// have accumulated or SSE_BATCH_MS milliseconds have passed since the last
+// real flush, whichever comes first. With neither set (the default) every
+// flush goes straight through, preserving current behavior.
+
+var (
+	sseBatchOnce  sync.Once
+	sseBatchDelay time.Duration
+	sseBatchBytes int
+)
+
+func loadSSEBatchConfig() {
+	sseBatchOnce.Do(func() {
+		if value := os.Getenv("SSE_BATCH_MS"); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+				sseBatchDelay = time.Duration(parsed) * time.Millisecond
+			}
+		}
+		if value := os.Getenv("SSE_BATCH_BYTES"); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+				sseBatchBytes = parsed
+			}
+		}
+	})
+}
+
+// sseBatcher wraps a stream's ResponseWriter and Flusher, counting written
+// bytes and deciding on each Flush call whether to pass it through. The
+// response writer's own buffer holds the pending bytes, so nothing is copied.
+type sseBatcher struct {
+	http.ResponseWriter
+	flusher   http.Flusher
+	maxDelay  time.Duration
+	maxBytes  int
+	pending   int
+	lastFlush time.Time
+}
+
+// newSSEBatcher returns a batcher for the stream, or nil when batching is not
+// configured. Callers must defer FlushFinal so the tail of the stream is
+// never left sitting in the buffer.
+func newSSEBatcher(w http.ResponseWriter, flusher http.Flusher) *sseBatcher {
+	loadSSEBatchConfig()
+	if sseBatchDelay <= 0 && sseBatchBytes <= 0 {
+		return nil
+	}
+	return &sseBatcher{
+		ResponseWriter: w,
+		flusher:        flusher,
+		maxDelay:       sseBatchDelay,
+		maxBytes:       sseBatchBytes,
+		lastFlush:      time.Now(),
+	}
+}
+
+func (b *sseBatcher) Write(p []byte) (int, error) {
+	n, err := b.ResponseWriter.Write(p)
+	b.pending += n
+	return n, err
+}
+
+// Flush passes through only once a threshold is crossed
+func (b *sseBatcher) Flush() {
+	if b.pending == 0 {
+		return
+	}
+	if (b.maxBytes > 0 && b.pending >= b.maxBytes) ||
+		(b.maxDelay > 0 && time.Since(b.lastFlush) >= b.maxDelay) {
+		b.flushNow()
+	}
+}
+
+// FlushFinal unconditionally flushes whatever is still pending
+func (b *sseBatcher) FlushFinal() {
+	if b.pending > 0 {
+		b.flushNow()
+	}
+}
+
+func (b *sseBatcher) flushNow() {
+	b.flusher.Flush()
+	b.pending = 0
+	b.lastFlush = time.Now()
+}
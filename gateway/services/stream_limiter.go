@@ -0,0 +1,86 @@
+package services
+
+import (
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Global cap on concurrent provider streams. Each /v1/complete request holds
+// one provider connection for its whole generation; under a thundering herd
+// that exhausts connection pools and file descriptors for everyone. The cap
+// sheds the excess with a retryable busy error instead. Distinct from
+// per-user rate limits: this protects the process, not fairness.
+
+var (
+	providerStreamSlots chan struct{}
+	providerStreamOnce  sync.Once
+)
+
+// maxGlobalProviderStreams returns the concurrent stream cap
+// (MAX_GLOBAL_PROVIDER_STREAMS, 0 or unset disables the limiter)
+func maxGlobalProviderStreams() int {
+	if value := os.Getenv("MAX_GLOBAL_PROVIDER_STREAMS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// initProviderStreamSlots sizes the semaphore once, on first use
+func initProviderStreamSlots() {
+	providerStreamOnce.Do(func() {
+		if limit := maxGlobalProviderStreams(); limit > 0 {
+			providerStreamSlots = make(chan struct{}, limit)
+		}
+	})
+}
+
+// AcquireProviderStream tries to claim a provider stream slot without
+// blocking, reporting whether the caller may proceed. Always true when the
+// limiter is disabled.
+func AcquireProviderStream() bool {
+	initProviderStreamSlots()
+	if providerStreamSlots == nil {
+		return true
+	}
+
+	select {
+	case providerStreamSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReleaseProviderStream returns a slot claimed by AcquireProviderStream; a
+// no-op when the limiter is disabled
+func ReleaseProviderStream() {
+	if providerStreamSlots == nil {
+		return
+	}
+
+	select {
+	case <-providerStreamSlots:
+	default:
+	}
+}
+
+// GetProviderStreamStats returns current semaphore utilization for metrics so
+// operators can size the cap
+func GetProviderStreamStats() map[string]interface{} {
+	initProviderStreamSlots()
+	if providerStreamSlots == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+
+	inUse := len(providerStreamSlots)
+	capacity := cap(providerStreamSlots)
+	return map[string]interface{}{
+		"enabled":     true,
+		"in_use":      inUse,
+		"capacity":    capacity,
+		"utilization": float64(inUse) / float64(capacity),
+	}
+}
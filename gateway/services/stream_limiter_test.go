@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+)
+
+// withStreamSlots swaps in a test semaphore, restoring the real one when the
+// test finishes. The limiter is normally sized once per process via sync.Once,
+// so tests inject the channel directly.
+func withStreamSlots(t *testing.T, slots chan struct{}) {
+	t.Helper()
+	saved := providerStreamSlots
+	providerStreamSlots = slots
+	t.Cleanup(func() { providerStreamSlots = saved })
+}
+
+func TestAcquireProviderStreamDisabled(t *testing.T) {
+	withStreamSlots(t, nil)
+	t.Setenv("MAX_GLOBAL_PROVIDER_STREAMS", "")
+
+	// With no semaphore the limiter always admits
+	for i := 0; i < 100; i++ {
+		if !AcquireProviderStream() {
+			t.Fatal("disabled limiter rejected a stream")
+		}
+	}
+	ReleaseProviderStream()
+
+	stats := GetProviderStreamStats()
+	if enabled, _ := stats["enabled"].(bool); enabled {
+		t.Error("stats should report the limiter as disabled")
+	}
+}
+
+func TestAcquireProviderStreamCap(t *testing.T) {
+	withStreamSlots(t, make(chan struct{}, 2))
+
+	if !AcquireProviderStream() || !AcquireProviderStream() {
+		t.Fatal("acquires within the cap should succeed")
+	}
+	if AcquireProviderStream() {
+		t.Fatal("acquire beyond the cap should be rejected")
+	}
+
+	ReleaseProviderStream()
+	if !AcquireProviderStream() {
+		t.Error("a released slot should be acquirable again")
+	}
+
+	// Releasing more than was acquired must not block or panic
+	ReleaseProviderStream()
+	ReleaseProviderStream()
+	ReleaseProviderStream()
+}
+
+func TestGetProviderStreamStats(t *testing.T) {
+	withStreamSlots(t, make(chan struct{}, 4))
+
+	AcquireProviderStream()
+	stats := GetProviderStreamStats()
+
+	if enabled, _ := stats["enabled"].(bool); !enabled {
+		t.Fatal("stats should report the limiter as enabled")
+	}
+	if stats["in_use"] != 1 {
+		t.Errorf("in_use = %v, want 1", stats["in_use"])
+	}
+	if stats["capacity"] != 4 {
+		t.Errorf("capacity = %v, want 4", stats["capacity"])
+	}
+	if stats["utilization"] != 0.25 {
+		t.Errorf("utilization = %v, want 0.25", stats["utilization"])
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// thinkingDisabledContextKey is the context key marking requests that should
+// be served without reasoning output
+type thinkingDisabledContextKey struct{}
+
+// WithThinkingDisabled returns a context marking the request to be streamed
+// without thinking: providers are treated as non-thinking models, skipping the
+// Gemini ThinkingConfig and suppressing the reasoning stream
+func WithThinkingDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, thinkingDisabledContextKey{}, true)
+}
+
+// ThinkingDisabled reports whether thinking output was disabled for this
+// request, either by the caller or by tier policy
+func ThinkingDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(thinkingDisabledContextKey{}).(bool)
+	return disabled
+}
+
+// ThinkingDisabledForTier reports whether the tier appears in the
+// comma-separated THINKING_DISABLED_TIERS list, letting operators turn off
+// reasoning latency for specific tiers server-side
+func ThinkingDisabledForTier(tier string) bool {
+	value := os.Getenv("THINKING_DISABLED_TIERS")
+	if value == "" || tier == "" {
+		return false
+	}
+	for _, disabled := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(disabled), tier) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestThinkingDisabledContext(t *testing.T) {
+	if ThinkingDisabled(context.Background()) {
+		t.Error("thinking should be enabled by default")
+	}
+
+	ctx := WithThinkingDisabled(context.Background())
+	if !ThinkingDisabled(ctx) {
+		t.Error("WithThinkingDisabled should mark the request")
+	}
+}
+
+func TestThinkingDisabledForTier(t *testing.T) {
+	t.Setenv("THINKING_DISABLED_TIERS", "")
+	if ThinkingDisabledForTier("free") {
+		t.Error("no tiers should be disabled by default")
+	}
+
+	t.Setenv("THINKING_DISABLED_TIERS", "anonymous, free")
+	tests := []struct {
+		tier string
+		want bool
+	}{
+		{"anonymous", true},
+		{"free", true},
+		{"FREE", true}, // case-insensitive match
+		{"plus", false},
+		{"pro", false},
+		{"", false}, // an empty tier never matches
+	}
+	for _, tt := range tests {
+		if got := ThinkingDisabledForTier(tt.tier); got != tt.want {
+			t.Errorf("ThinkingDisabledForTier(%q) = %v, want %v", tt.tier, got, tt.want)
+		}
+	}
+}
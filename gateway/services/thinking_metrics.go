@@ -0,0 +1,108 @@
+package services
+
+import (
+	"strings"
+	"sync"
+)
+
+// thinkingTokenTotals accumulates per-model token estimates for requests that
+// produced any content
+type thinkingTokenTotals struct {
+	requests       int64
+	thinkingTokens int64
+	answerTokens   int64
+}
+
+// Per-model running totals of thinking vs answer token estimates
+var (
+	thinkingTokensMu      sync.Mutex
+	thinkingTokensByModel = map[string]*thinkingTokenTotals{}
+)
+
+// SplitThinkingTokens estimates how many tokens of a streamed response were
+// spent on thinking (text between ◁think▷ and ◁/think▷ — every provider's
+// native reasoning signal is normalized to these markers) versus the visible
+// answer. Uses the same chars/4 heuristic as the context budget. Unbalanced
+// markers follow the StripThinkingBlocks conventions: an unclosed ◁think▷
+// counts everything after it as thinking, a stray ◁/think▷ counts everything
+// before it.
+func SplitThinkingTokens(content string) (thinkingTokens, answerTokens int) {
+	if !strings.Contains(content, thinkStartMarker) && !strings.Contains(content, thinkEndMarker) {
+		return 0, estimateTokens(content)
+	}
+
+	var thinking, answer strings.Builder
+	depth := 0
+	for {
+		start := strings.Index(content, thinkStartMarker)
+		end := strings.Index(content, thinkEndMarker)
+		switch {
+		case start == -1 && end == -1:
+			if depth == 0 {
+				answer.WriteString(content)
+			} else {
+				thinking.WriteString(content)
+			}
+			return estimateTokens(thinking.String()), estimateTokens(answer.String())
+		case end == -1 || (start != -1 && start < end):
+			// Opening marker comes first
+			if depth == 0 {
+				answer.WriteString(content[:start])
+			} else {
+				thinking.WriteString(content[:start])
+			}
+			depth++
+			content = content[start+len(thinkStartMarker):]
+		default:
+			// Closing marker comes first; at depth 0 it is stray and the
+			// text before it is treated as thinking output
+			thinking.WriteString(content[:end])
+			if depth > 0 {
+				depth--
+			}
+			content = content[end+len(thinkEndMarker):]
+		}
+	}
+}
+
+// RecordThinkingSplit adds one request's thinking/answer token estimates to
+// the model's running totals
+func RecordThinkingSplit(model string, thinkingTokens, answerTokens int) {
+	if thinkingTokens <= 0 && answerTokens <= 0 {
+		return
+	}
+
+	thinkingTokensMu.Lock()
+	defer thinkingTokensMu.Unlock()
+
+	totals, ok := thinkingTokensByModel[model]
+	if !ok {
+		totals = &thinkingTokenTotals{}
+		thinkingTokensByModel[model] = totals
+	}
+	totals.requests++
+	totals.thinkingTokens += int64(thinkingTokens)
+	totals.answerTokens += int64(answerTokens)
+}
+
+// GetThinkingTokenStats returns per-model thinking vs answer token totals for
+// metrics, including the fraction of generated tokens spent on thinking
+func GetThinkingTokenStats() map[string]interface{} {
+	thinkingTokensMu.Lock()
+	defer thinkingTokensMu.Unlock()
+
+	stats := map[string]interface{}{}
+	for model, totals := range thinkingTokensByModel {
+		ratio := 0.0
+		if generated := totals.thinkingTokens + totals.answerTokens; generated > 0 {
+			ratio = float64(totals.thinkingTokens) / float64(generated)
+		}
+		stats[model] = map[string]interface{}{
+			"requests":        totals.requests,
+			"thinking_tokens": totals.thinkingTokens,
+			"answer_tokens":   totals.answerTokens,
+			"thinking_ratio":  ratio,
+		}
+	}
+	return stats
+}
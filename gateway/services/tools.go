@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gateway/models"
+	"gateway/pkg/logger"
+)
+
+// ToolConfig carries the tool declarations and tool choice for a request
+type ToolConfig struct {
+	Tools      []models.Tool
+	ToolChoice interface{}
+}
+
+// toolConfigContextKey is the context key for the per-request tool config
+type toolConfigContextKey struct{}
+
+// WithToolConfig returns a context carrying the request's tool configuration
+func WithToolConfig(ctx context.Context, cfg *ToolConfig) context.Context {
+	return context.WithValue(ctx, toolConfigContextKey{}, cfg)
+}
+
+// GetToolConfigFromContext returns the request's tool configuration, or nil
+// when the client did not supply tools
+func GetToolConfigFromContext(ctx context.Context) *ToolConfig {
+	cfg, _ := ctx.Value(toolConfigContextKey{}).(*ToolConfig)
+	if cfg == nil || len(cfg.Tools) == 0 {
+		return nil
+	}
+	return cfg
+}
+
+// sendToolCallFrame emits the streamed tool-call deltas as a "tool_call" SSE
+// frame so clients can act on them instead of rendering them as text
+func sendToolCallFrame(w http.ResponseWriter, flusher http.Flusher, toolCalls []models.ToolCallDelta) error {
+	toolResponse := models.Response{
+		Type:      "tool_call",
+		ToolCalls: toolCalls,
+	}
+
+	msg, err := models.FormatSSEMessage(toolResponse)
+	if err != nil {
+		return fmt.Errorf("error formatting tool_call frame: %v", err)
+	}
+
+	if _, err := fmt.Fprint(w, msg); err != nil {
+		return fmt.Errorf("error sending tool_call frame: %v", err)
+	}
+	flusher.Flush()
+	return nil
+}
+
+// geminiToolCallDelta converts a Gemini functionCall part into the OpenAI-style
+// delta shape used on the wire, marshaling the args back to a JSON string
+func geminiToolCallDelta(functionCall map[string]interface{}) models.ToolCallDelta {
+	delta := models.ToolCallDelta{Type: "function"}
+
+	if name, ok := functionCall["name"].(string); ok {
+		delta.Function.Name = name
+	}
+	if args, ok := functionCall["args"]; ok {
+		argsJSON, err := json.Marshal(args)
+		if err != nil {
+			logger.GetDailyLogger().Error("Error marshaling Gemini function call args: %v", err)
+		} else {
+			delta.Function.Arguments = string(argsJSON)
+		}
+	}
+
+	return delta
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"os"
+	"strings"
+)
+
+// ResponseTransformer post-processes streamed model output before it reaches
+// the client, e.g. to rewrite links or apply a content policy. Transformers
+// see the raw provider text chunk by chunk, and because a pattern can be
+// split across two chunks they are allowed to buffer: Transform may return
+// less text than it was given (including none), and Flush returns whatever
+// is still held back once the stream ends.
+type ResponseTransformer interface {
+	Transform(chunk string) string
+	Flush() string
+}
+
+// newResponseTransformer builds the transformer selected by the
+// RESPONSE_TRANSFORMER environment variable. Unset or unknown values fall
+// back to the pass-through default, so deployments that don't opt in are
+// unaffected. One transformer is created per stream, since transformers
+// carry buffering state.
+func newResponseTransformer() ResponseTransformer {
+	switch os.Getenv("RESPONSE_TRANSFORMER") {
+	case "link_rewrite":
+		from := os.Getenv("LINK_REWRITE_FROM")
+		to := os.Getenv("LINK_REWRITE_TO")
+		if from != "" {
+			return &linkRewriteTransformer{from: from, to: to}
+		}
+	}
+	return noopTransformer{}
+}
+
+// noopTransformer passes chunks through untouched
+type noopTransformer struct{}
+
+func (noopTransformer) Transform(chunk string) string { return chunk }
+func (noopTransformer) Flush() string                 { return "" }
+
+// linkRewriteTransformer replaces every occurrence of one URL prefix with
+// another (e.g. pointing docs links at a mirror). When a chunk ends in a
+// partial match of the source prefix it holds that tail back until the next
+// chunk resolves it one way or the other.
+type linkRewriteTransformer struct {
+	from string
+	to   string
+	buf  string
+}
+
+func (t *linkRewriteTransformer) Transform(chunk string) string {
+	text := t.buf + chunk
+	t.buf = ""
+
+	// Hold back the longest suffix that could still grow into the source
+	// prefix; everything before it is safe to rewrite and emit
+	start := len(text) - len(t.from) + 1
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(text); i++ {
+		if strings.HasPrefix(t.from, text[i:]) {
+			t.buf = text[i:]
+			text = text[:i]
+			break
+		}
+	}
+
+	return strings.ReplaceAll(text, t.from, t.to)
+}
+
+func (t *linkRewriteTransformer) Flush() string {
+	tail := t.buf
+	t.buf = ""
+	return tail
+}
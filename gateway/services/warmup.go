@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"gateway/pkg/logger"
+)
+
+// warmUpRequestTimeout bounds each per-provider warm-up request
+const warmUpRequestTimeout = 10 * time.Second
+
+// WarmUpProviders primes each provider's HTTP connection pool at startup by
+// issuing a cheap models-list request, so the first user request doesn't pay
+// the TLS handshake cost and misconfigured API keys surface at boot. Gated
+// behind PROVIDER_WARMUP=true; failures are logged but never fatal.
+func WarmUpProviders() {
+	if os.Getenv("PROVIDER_WARMUP") != "true" {
+		return
+	}
+
+	logger.GetDailyLogger().Info("Warming up provider HTTP clients...")
+
+	warmUpGemini()
+	warmUpGroq()
+	warmUpOpenRouter()
+}
+
+// warmUpGemini issues a models-list request against the Gemini API
+func warmUpGemini() {
+	initGeminiClient()
+	apiKey, _, baseURL := getGeminiConfig()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s?key=%s", baseURL, apiKey), nil)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Provider warm-up for gemini failed to build request: %v", err)
+		return
+	}
+
+	doWarmUpRequest("gemini", geminiClient, req)
+}
+
+// warmUpGroq issues a models-list request against the Groq API
+func warmUpGroq() {
+	initGroqClient()
+	apiKey, baseURL := getGroqConfig()
+
+	req, err := http.NewRequest("GET", baseURL+"/models", nil)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Provider warm-up for groq failed to build request: %v", err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	doWarmUpRequest("groq", groqClient, req)
+}
+
+// warmUpOpenRouter issues a models-list request against the OpenRouter API
+func warmUpOpenRouter() {
+	initOpenRouterClient()
+	apiKey, baseURL := getOpenRouterConfig()
+
+	req, err := http.NewRequest("GET", baseURL+"/models", nil)
+	if err != nil {
+		logger.GetDailyLogger().Warn("Provider warm-up for openrouter failed to build request: %v", err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	doWarmUpRequest("openrouter", openRouterClient, req)
+}
+
+// doWarmUpRequest executes a warm-up request and logs the per-provider outcome
+func doWarmUpRequest(provider string, client *http.Client, req *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), warmUpRequestTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		logger.GetDailyLogger().Warn("Provider warm-up failed for %s: %v", provider, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		logger.GetDailyLogger().Warn("Provider warm-up for %s returned status %d - check API key configuration", provider, resp.StatusCode)
+		return
+	}
+
+	logger.GetDailyLogger().Info("Provider warm-up succeeded for %s (status %d)", provider, resp.StatusCode)
+}
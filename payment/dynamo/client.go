@@ -3,10 +3,11 @@ package dynamo
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
+	"payment/logger"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
@@ -30,6 +31,7 @@ type Subscription struct {
 	CreatedAt                           time.Time  `dynamodb:"created_at" json:"created_at"`
 	UpdatedAt                           time.Time  `dynamodb:"updated_at" json:"updated_at"`
 	ExpiresAt                           *time.Time `dynamodb:"expires_at,omitempty" json:"expires_at,omitempty"`
+	TrialEndsAt                         *time.Time `dynamodb:"trial_ends_at,omitempty" json:"trial_ends_at,omitempty"`
 	CustomerID                          string     `dynamodb:"customer_id" json:"customer_id"`
 	Email                               string     `dynamodb:"email" json:"email"`
 	CustomerPortalURL                   string     `dynamodb:"customer_portal_url" json:"customer_portal_url"`
@@ -40,37 +42,37 @@ type Subscription struct {
 // Init initializes the DynamoDB client
 func Init() error {
 	startTime := time.Now()
-	log.Printf("🗄️ DynamoDB initialization started")
+	logger.Infof("🗄️ DynamoDB initialization started")
 
 	// Get table name from environment
 	TableName = os.Getenv("DYNAMO_TABLE")
 	if TableName == "" {
 		TableName = "subscriptions"
-		log.Printf("⚠️ DYNAMO_TABLE not set, using default: %s", TableName)
+		logger.Warnf("⚠️ DYNAMO_TABLE not set, using default: %s", TableName)
 	} else {
-		log.Printf("✅ Using DynamoDB table: %s", TableName)
+		logger.Infof("✅ Using DynamoDB table: %s", TableName)
 	}
 
 	// Get AWS region
 	region := os.Getenv("AWS_REGION")
 	if region == "" {
 		region = "us-east-1"
-		log.Printf("⚠️ AWS_REGION not set, using default: %s", region)
+		logger.Warnf("⚠️ AWS_REGION not set, using default: %s", region)
 	} else {
-		log.Printf("✅ Using AWS region: %s", region)
+		logger.Infof("✅ Using AWS region: %s", region)
 	}
 
 	// Check if we're in development mode
 	if os.Getenv("DEVELOPMENT") == "true" {
-		log.Printf("🔧 Development mode detected - mocking DynamoDB client")
+		logger.Infof("🔧 Development mode detected - mocking DynamoDB client")
 		client = nil // Set to nil to indicate development mode
 		duration := time.Since(startTime)
-		log.Printf("✅ DynamoDB initialization completed in development mode in %v", duration)
+		logger.Infof("✅ DynamoDB initialization completed in development mode in %v", duration)
 		return nil
 	}
 
 	// Try to create AWS config
-	log.Printf("🔐 Loading AWS credentials...")
+	logger.Infof("🔐 Loading AWS credentials...")
 	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
 	if err != nil {
 		// Try with explicit credentials if environment variables are set
@@ -78,7 +80,7 @@ func Init() error {
 		secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
 
 		if accessKey != "" && secretKey != "" {
-			log.Printf("🔑 Using explicit AWS credentials from environment")
+			logger.Infof("🔑 Using explicit AWS credentials from environment")
 			cfg, err = config.LoadDefaultConfig(context.TODO(),
 				config.WithRegion(region),
 				config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
@@ -86,19 +88,28 @@ func Init() error {
 		}
 
 		if err != nil {
-			log.Printf("❌ Failed to load AWS config: %v", err)
+			logger.Errorf("❌ Failed to load AWS config: %v", err)
 			return fmt.Errorf("failed to load AWS config: %w", err)
 		}
 	}
 
-	log.Printf("✅ AWS config loaded successfully")
+	logger.Infof("✅ AWS config loaded successfully")
 
 	// Create DynamoDB client
-	log.Printf("🔌 Creating DynamoDB client...")
-	client = dynamodb.NewFromConfig(cfg)
+	logger.Infof("🔌 Creating DynamoDB client...")
+	if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+		// Point at a local DynamoDB (e.g. dynamodb-local on
+		// http://localhost:8000) for integration testing without AWS
+		logger.Infof("🔌 Using custom DynamoDB endpoint: %s", endpoint)
+		client = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.BaseEndpoint = &endpoint
+		})
+	} else {
+		client = dynamodb.NewFromConfig(cfg)
+	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ DynamoDB client initialized successfully in %v", duration)
+	logger.Infof("✅ DynamoDB client initialized successfully in %v", duration)
 	return nil
 }
 
@@ -107,8 +118,8 @@ func GetSubscription(ctx context.Context, userID string) (*Subscription, error)
 	startTime := time.Now()
 	requestID := fmt.Sprintf("get-%d", startTime.UnixNano())
 
-	log.Printf("🔍 [%s] Getting subscription for user: %s", requestID, userID)
-	log.Printf("🔍 [%s] Table: %s", requestID, TableName)
+	logger.Infof("🔍 [%s] Getting subscription for user: %s", requestID, userID)
+	logger.Infof("🔍 [%s] Table: %s", requestID, TableName)
 
 	// Prepare the query
 	input := &dynamodb.GetItemInput{
@@ -118,45 +129,45 @@ func GetSubscription(ctx context.Context, userID string) (*Subscription, error)
 		},
 	}
 
-	log.Printf("🔍 [%s] Executing DynamoDB GetItem operation...", requestID)
+	logger.Infof("🔍 [%s] Executing DynamoDB GetItem operation...", requestID)
 	result, err := client.GetItem(ctx, input)
 	if err != nil {
-		log.Printf("❌ [%s] DynamoDB GetItem failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] DynamoDB GetItem failed: %v", requestID, err)
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	log.Printf("🔍 [%s] Result: %+v", requestID, result)
+	logger.Infof("🔍 [%s] Result: %+v", requestID, result)
 
 	// Check if item exists
 	if result.Item == nil {
-		log.Printf("📋 [%s] No subscription found for user: %s", requestID, userID)
+		logger.Infof("📋 [%s] No subscription found for user: %s", requestID, userID)
 		duration := time.Since(startTime)
-		log.Printf("✅ [%s] GetSubscription completed (no result) in %v", requestID, duration)
+		logger.Infof("✅ [%s] GetSubscription completed (no result) in %v", requestID, duration)
 		return nil, nil
 	}
 
 	// Unmarshal the result
-	log.Printf("🔄 [%s] Unmarshaling subscription data...", requestID)
+	logger.Infof("🔄 [%s] Unmarshaling subscription data...", requestID)
 	var subscription Subscription
 	err = attributevalue.UnmarshalMapWithOptions(result.Item, &subscription, func(o *attributevalue.DecoderOptions) {
 		o.TagKey = "dynamodb"
 	})
 	if err != nil {
-		log.Printf("❌ [%s] Failed to unmarshal subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to unmarshal subscription: %v", requestID, err)
 		return nil, fmt.Errorf("failed to unmarshal subscription: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Subscription retrieved successfully in %v", requestID, duration)
-	log.Printf("📋 [%s] Subscription details:", requestID)
-	log.Printf("   Tier: %s", subscription.Tier)
-	log.Printf("   Status: %s", subscription.Status)
-	log.Printf("   Variant ID: %d", subscription.VariantID)
-	log.Printf("   SubID: %s", subscription.SubID)
-	log.Printf("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
-	log.Printf("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("✅ [%s] Subscription retrieved successfully in %v", requestID, duration)
+	logger.Infof("📋 [%s] Subscription details:", requestID)
+	logger.Infof("   Tier: %s", subscription.Tier)
+	logger.Infof("   Status: %s", subscription.Status)
+	logger.Infof("   Variant ID: %d", subscription.VariantID)
+	logger.Infof("   SubID: %s", subscription.SubID)
+	logger.Infof("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
+	logger.Infof("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
 	if subscription.ExpiresAt != nil {
-		log.Printf("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
+		logger.Infof("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
 	}
 
 	return &subscription, nil
@@ -167,40 +178,40 @@ func SaveSubscription(ctx context.Context, sub Subscription) error {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("save-%d", startTime.UnixNano())
 
-	log.Printf("💾 [%s] Saving subscription for user: %s", requestID, sub.UserID)
-	log.Printf("💾 [%s] Table: %s", requestID, TableName)
-	log.Printf("💾 [%s] Subscription data:", requestID)
-	log.Printf("   Tier: %s", sub.Tier)
-	log.Printf("   Status: %s", sub.Status)
-	log.Printf("   Variant ID: %d", sub.VariantID)
-	log.Printf("   SubID: %s", sub.SubID)
-	log.Printf("   CustomerID: %s", sub.CustomerID)
-	log.Printf("   Email: %s", sub.Email)
+	logger.Infof("💾 [%s] Saving subscription for user: %s", requestID, sub.UserID)
+	logger.Infof("💾 [%s] Table: %s", requestID, TableName)
+	logger.Infof("💾 [%s] Subscription data:", requestID)
+	logger.Infof("   Tier: %s", sub.Tier)
+	logger.Infof("   Status: %s", sub.Status)
+	logger.Infof("   Variant ID: %d", sub.VariantID)
+	logger.Infof("   SubID: %s", sub.SubID)
+	logger.Infof("   CustomerID: %s", sub.CustomerID)
+	logger.Infof("   Email: %s", sub.Email)
 
 	// Handle development mode
 	if client == nil {
-		log.Printf("🔧 [%s] Development mode - simulating save operation", requestID)
+		logger.Infof("🔧 [%s] Development mode - simulating save operation", requestID)
 		time.Sleep(50 * time.Millisecond) // Simulate database latency
 		duration := time.Since(startTime)
-		log.Printf("✅ [%s] Subscription saved successfully (development mode) in %v", requestID, duration)
+		logger.Infof("✅ [%s] Subscription saved successfully (development mode) in %v", requestID, duration)
 		return nil
 	}
 
 	// Ensure timestamps are set
 	if sub.CreatedAt.IsZero() {
 		sub.CreatedAt = time.Now()
-		log.Printf("📅 [%s] Set creation time: %s", requestID, sub.CreatedAt.Format(time.RFC3339))
+		logger.Infof("📅 [%s] Set creation time: %s", requestID, sub.CreatedAt.Format(time.RFC3339))
 	}
 	sub.UpdatedAt = time.Now()
-	log.Printf("📅 [%s] Set update time: %s", requestID, sub.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("📅 [%s] Set update time: %s", requestID, sub.UpdatedAt.Format(time.RFC3339))
 
 	// Marshal the subscription
-	log.Printf("🔄 [%s] Marshaling subscription data...", requestID)
+	logger.Infof("🔄 [%s] Marshaling subscription data...", requestID)
 	item, err := attributevalue.MarshalMapWithOptions(sub, func(o *attributevalue.EncoderOptions) {
 		o.TagKey = "dynamodb"
 	})
 	if err != nil {
-		log.Printf("❌ [%s] Failed to marshal subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to marshal subscription: %v", requestID, err)
 		return fmt.Errorf("failed to marshal subscription: %w", err)
 	}
 
@@ -210,15 +221,15 @@ func SaveSubscription(ctx context.Context, sub Subscription) error {
 		Item:      item,
 	}
 
-	log.Printf("💾 [%s] Executing DynamoDB PutItem operation...", requestID)
+	logger.Infof("💾 [%s] Executing DynamoDB PutItem operation...", requestID)
 	_, err = client.PutItem(ctx, input)
 	if err != nil {
-		log.Printf("❌ [%s] DynamoDB PutItem failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] DynamoDB PutItem failed: %v", requestID, err)
 		return fmt.Errorf("failed to save subscription: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Subscription saved successfully in %v", requestID, duration)
+	logger.Infof("✅ [%s] Subscription saved successfully in %v", requestID, duration)
 	return nil
 }
 
@@ -227,50 +238,50 @@ func SaveSubscriptionDetailed(ctx context.Context, sub Subscription) error {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("save-detailed-%d", startTime.UnixNano())
 
-	log.Printf("💾 [%s] Saving subscription with detailed logging for user: %s", requestID, sub.UserID)
-	log.Printf("💾 [%s] Table: %s", requestID, TableName)
-	log.Printf("💾 [%s] Complete subscription data:", requestID)
-	log.Printf("   UserID: %s", sub.UserID)
-	log.Printf("   Tier: %s", sub.Tier)
-	log.Printf("   Status: %s", sub.Status)
-	log.Printf("   VariantID: %d", sub.VariantID)
-	log.Printf("   SubID: %s", sub.SubID)
-	log.Printf("   CustomerID: %s", sub.CustomerID)
-	log.Printf("   Email: %s", sub.Email)
-	log.Printf("   CreatedAt: %s", sub.CreatedAt.Format(time.RFC3339))
-	log.Printf("   UpdatedAt: %s", sub.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("💾 [%s] Saving subscription with detailed logging for user: %s", requestID, sub.UserID)
+	logger.Infof("💾 [%s] Table: %s", requestID, TableName)
+	logger.Infof("💾 [%s] Complete subscription data:", requestID)
+	logger.Infof("   UserID: %s", sub.UserID)
+	logger.Infof("   Tier: %s", sub.Tier)
+	logger.Infof("   Status: %s", sub.Status)
+	logger.Infof("   VariantID: %d", sub.VariantID)
+	logger.Infof("   SubID: %s", sub.SubID)
+	logger.Infof("   CustomerID: %s", sub.CustomerID)
+	logger.Infof("   Email: %s", sub.Email)
+	logger.Infof("   CreatedAt: %s", sub.CreatedAt.Format(time.RFC3339))
+	logger.Infof("   UpdatedAt: %s", sub.UpdatedAt.Format(time.RFC3339))
 	if sub.ExpiresAt != nil {
-		log.Printf("   ExpiresAt: %s", sub.ExpiresAt.Format(time.RFC3339))
+		logger.Infof("   ExpiresAt: %s", sub.ExpiresAt.Format(time.RFC3339))
 	}
-	log.Printf("   CustomerPortalURL: %s", sub.CustomerPortalURL)
-	log.Printf("   UpdatePaymentMethodURL: %s", sub.UpdatePaymentMethodURL)
-	log.Printf("   CustomerPortalUpdateSubscriptionURL: %s", sub.CustomerPortalUpdateSubscriptionURL)
+	logger.Infof("   CustomerPortalURL: %s", sub.CustomerPortalURL)
+	logger.Infof("   UpdatePaymentMethodURL: %s", sub.UpdatePaymentMethodURL)
+	logger.Infof("   CustomerPortalUpdateSubscriptionURL: %s", sub.CustomerPortalUpdateSubscriptionURL)
 
 	// Handle development mode
 	if client == nil {
-		log.Printf("🔧 [%s] Development mode - simulating detailed save operation", requestID)
+		logger.Infof("🔧 [%s] Development mode - simulating detailed save operation", requestID)
 		time.Sleep(75 * time.Millisecond) // Simulate database latency
 		duration := time.Since(startTime)
-		log.Printf("✅ [%s] Detailed subscription saved successfully (development mode) in %v", requestID, duration)
+		logger.Infof("✅ [%s] Detailed subscription saved successfully (development mode) in %v", requestID, duration)
 		return nil
 	}
 
 	// Ensure timestamps are set
 	if sub.CreatedAt.IsZero() {
 		sub.CreatedAt = time.Now()
-		log.Printf("📅 [%s] Set creation time: %s", requestID, sub.CreatedAt.Format(time.RFC3339))
+		logger.Infof("📅 [%s] Set creation time: %s", requestID, sub.CreatedAt.Format(time.RFC3339))
 	}
 	sub.UpdatedAt = time.Now()
-	log.Printf("📅 [%s] Updated modification time: %s", requestID, sub.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("📅 [%s] Updated modification time: %s", requestID, sub.UpdatedAt.Format(time.RFC3339))
 
 	// Create DynamoDB item manually to ensure correct field names
-	log.Printf("🔄 [%s] Creating DynamoDB item manually...", requestID)
+	logger.Infof("🔄 [%s] Creating DynamoDB item manually...", requestID)
 
 	// Debug: Log the subscription object before creating item
-	log.Printf("🔍 [%s] Pre-item creation subscription object:", requestID)
-	log.Printf("   UserID: '%s' (empty: %t)", sub.UserID, sub.UserID == "")
-	log.Printf("   Tier: '%s'", sub.Tier)
-	log.Printf("   Status: '%s'", sub.Status)
+	logger.Infof("🔍 [%s] Pre-item creation subscription object:", requestID)
+	logger.Infof("   UserID: '%s' (empty: %t)", sub.UserID, sub.UserID == "")
+	logger.Infof("   Tier: '%s'", sub.Tier)
+	logger.Infof("   Status: '%s'", sub.Status)
 
 	// Create the DynamoDB item manually with correct field names
 	item := map[string]types.AttributeValue{
@@ -294,14 +305,14 @@ func SaveSubscriptionDetailed(ctx context.Context, sub Subscription) error {
 	}
 
 	// Log the created item for debugging
-	log.Printf("🔍 [%s] Created DynamoDB item with %d attributes", requestID, len(item))
+	logger.Infof("🔍 [%s] Created DynamoDB item with %d attributes", requestID, len(item))
 
 	// Debug: Check if user_id is in the created item
 	if userIDAttr, exists := item["user_id"]; exists {
-		log.Printf("✅ [%s] DynamoDB item contains user_id: %+v", requestID, userIDAttr)
+		logger.Infof("✅ [%s] DynamoDB item contains user_id: %+v", requestID, userIDAttr)
 	} else {
-		log.Printf("❌ [%s] CRITICAL: DynamoDB item is missing user_id field!", requestID)
-		log.Printf("❌ [%s] All DynamoDB item fields: %+v", requestID, item)
+		logger.Errorf("❌ [%s] CRITICAL: DynamoDB item is missing user_id field!", requestID)
+		logger.Errorf("❌ [%s] All DynamoDB item fields: %+v", requestID, item)
 	}
 
 	// Prepare the put item input
@@ -310,15 +321,15 @@ func SaveSubscriptionDetailed(ctx context.Context, sub Subscription) error {
 		Item:      item,
 	}
 
-	log.Printf("💾 [%s] Executing DynamoDB PutItem operation with detailed data...", requestID)
+	logger.Infof("💾 [%s] Executing DynamoDB PutItem operation with detailed data...", requestID)
 	_, err := client.PutItem(ctx, input)
 	if err != nil {
-		log.Printf("❌ [%s] DynamoDB PutItem failed for detailed save: %v", requestID, err)
+		logger.Errorf("❌ [%s] DynamoDB PutItem failed for detailed save: %v", requestID, err)
 		return fmt.Errorf("failed to save subscription: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Detailed subscription saved successfully in %v", requestID, duration)
+	logger.Infof("✅ [%s] Detailed subscription saved successfully in %v", requestID, duration)
 	return nil
 }
 
@@ -327,15 +338,15 @@ func DeleteSubscription(ctx context.Context, userID string) error {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("delete-%d", startTime.UnixNano())
 
-	log.Printf("🗑️ [%s] Deleting subscription for user: %s", requestID, userID)
-	log.Printf("🗑️ [%s] Table: %s", requestID, TableName)
+	logger.Infof("🗑️ [%s] Deleting subscription for user: %s", requestID, userID)
+	logger.Infof("🗑️ [%s] Table: %s", requestID, TableName)
 
 	// Handle development mode
 	if client == nil {
-		log.Printf("🔧 [%s] Development mode - simulating delete operation", requestID)
+		logger.Infof("🔧 [%s] Development mode - simulating delete operation", requestID)
 		time.Sleep(30 * time.Millisecond) // Simulate database latency
 		duration := time.Since(startTime)
-		log.Printf("✅ [%s] Subscription deleted successfully (development mode) in %v", requestID, duration)
+		logger.Infof("✅ [%s] Subscription deleted successfully (development mode) in %v", requestID, duration)
 		return nil
 	}
 
@@ -347,15 +358,15 @@ func DeleteSubscription(ctx context.Context, userID string) error {
 		},
 	}
 
-	log.Printf("🗑️ [%s] Executing DynamoDB DeleteItem operation...", requestID)
+	logger.Infof("🗑️ [%s] Executing DynamoDB DeleteItem operation...", requestID)
 	_, err := client.DeleteItem(ctx, input)
 	if err != nil {
-		log.Printf("❌ [%s] DynamoDB DeleteItem failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] DynamoDB DeleteItem failed: %v", requestID, err)
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Subscription deleted successfully in %v", requestID, duration)
+	logger.Infof("✅ [%s] Subscription deleted successfully in %v", requestID, duration)
 	return nil
 }
 
@@ -364,12 +375,12 @@ func ListSubscriptions(ctx context.Context, limit int32) ([]Subscription, error)
 	startTime := time.Now()
 	requestID := fmt.Sprintf("list-%d", startTime.UnixNano())
 
-	log.Printf("📋 [%s] Listing subscriptions with limit: %d", requestID, limit)
-	log.Printf("📋 [%s] Table: %s", requestID, TableName)
+	logger.Infof("📋 [%s] Listing subscriptions with limit: %d", requestID, limit)
+	logger.Infof("📋 [%s] Table: %s", requestID, TableName)
 
 	// Handle development mode
 	if client == nil {
-		log.Printf("🔧 [%s] Development mode - returning mock subscription list", requestID)
+		logger.Infof("🔧 [%s] Development mode - returning mock subscription list", requestID)
 		mockSubs := []Subscription{
 			{
 				UserID:     "dev-user-1",
@@ -395,7 +406,7 @@ func ListSubscriptions(ctx context.Context, limit int32) ([]Subscription, error)
 			},
 		}
 		duration := time.Since(startTime)
-		log.Printf("✅ [%s] Returned %d mock subscriptions in %v", requestID, len(mockSubs), duration)
+		logger.Infof("✅ [%s] Returned %d mock subscriptions in %v", requestID, len(mockSubs), duration)
 		return mockSubs, nil
 	}
 
@@ -405,15 +416,15 @@ func ListSubscriptions(ctx context.Context, limit int32) ([]Subscription, error)
 		Limit:     aws.Int32(limit),
 	}
 
-	log.Printf("📋 [%s] Executing DynamoDB Scan operation...", requestID)
+	logger.Infof("📋 [%s] Executing DynamoDB Scan operation...", requestID)
 	result, err := client.Scan(ctx, input)
 	if err != nil {
-		log.Printf("❌ [%s] DynamoDB Scan failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] DynamoDB Scan failed: %v", requestID, err)
 		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
 
 	// Unmarshal the results
-	log.Printf("🔄 [%s] Unmarshaling %d subscription records...", requestID, len(result.Items))
+	logger.Infof("🔄 [%s] Unmarshaling %d subscription records...", requestID, len(result.Items))
 	var subscriptions []Subscription
 	for i, item := range result.Items {
 		var sub Subscription
@@ -421,14 +432,14 @@ func ListSubscriptions(ctx context.Context, limit int32) ([]Subscription, error)
 			o.TagKey = "dynamodb"
 		})
 		if err != nil {
-			log.Printf("❌ [%s] Failed to unmarshal subscription %d: %v", requestID, i, err)
+			logger.Errorf("❌ [%s] Failed to unmarshal subscription %d: %v", requestID, i, err)
 			continue
 		}
 		subscriptions = append(subscriptions, sub)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Listed %d subscriptions successfully in %v", requestID, len(subscriptions), duration)
+	logger.Infof("✅ [%s] Listed %d subscriptions successfully in %v", requestID, len(subscriptions), duration)
 
 	// Log summary of subscriptions
 	tierCounts := make(map[string]int)
@@ -438,13 +449,13 @@ func ListSubscriptions(ctx context.Context, limit int32) ([]Subscription, error)
 		statusCounts[sub.Status]++
 	}
 
-	log.Printf("📊 [%s] Subscription summary:", requestID)
-	log.Printf("   Total: %d", len(subscriptions))
+	logger.Infof("📊 [%s] Subscription summary:", requestID)
+	logger.Infof("   Total: %d", len(subscriptions))
 	for tier, count := range tierCounts {
-		log.Printf("   %s tier: %d", tier, count)
+		logger.Infof("   %s tier: %d", tier, count)
 	}
 	for status, count := range statusCounts {
-		log.Printf("   %s status: %d", status, count)
+		logger.Infof("   %s status: %d", status, count)
 	}
 
 	return subscriptions, nil
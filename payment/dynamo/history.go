@@ -0,0 +1,140 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"payment/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// defaultHistoryTableName is used when DYNAMO_HISTORY_TABLE is not set
+const defaultHistoryTableName = "subscription_history"
+
+// defaultHistoryLimit caps how many entries a single history read returns
+const defaultHistoryLimit = 50
+
+// SubscriptionHistoryEntry is one row in the subscription-change log. A row is
+// appended whenever a webhook changes a user's tier or status, so support can
+// answer "when did I get downgraded?" without digging through provider logs.
+type SubscriptionHistoryEntry struct {
+	UserID    string    `dynamodb:"user_id" json:"user_id"`
+	Timestamp time.Time `dynamodb:"timestamp" json:"timestamp"`
+	Event     string    `dynamodb:"event" json:"event"`
+	OldTier   string    `dynamodb:"old_tier" json:"old_tier"`
+	NewTier   string    `dynamodb:"new_tier" json:"new_tier"`
+	OldStatus string    `dynamodb:"old_status" json:"old_status"`
+	NewStatus string    `dynamodb:"new_status" json:"new_status"`
+}
+
+// HistoryTableName returns the subscription history table name
+// (DYNAMO_HISTORY_TABLE)
+func HistoryTableName() string {
+	if name := os.Getenv("DYNAMO_HISTORY_TABLE"); name != "" {
+		return name
+	}
+	return defaultHistoryTableName
+}
+
+// AppendSubscriptionHistory appends a change entry to the history table.
+// History is an audit trail, so failures here should be logged by the caller
+// but must not fail the webhook that triggered them.
+func AppendSubscriptionHistory(ctx context.Context, entry SubscriptionHistoryEntry) error {
+	startTime := time.Now()
+	requestID := fmt.Sprintf("history-append-%d", startTime.UnixNano())
+
+	logger.Infof("📜 [%s] Appending subscription history for user: %s", requestID, entry.UserID)
+	logger.Infof("📜 [%s] Change: %s → %s (%s → %s) via %s", requestID,
+		entry.OldTier, entry.NewTier, entry.OldStatus, entry.NewStatus, entry.Event)
+
+	// Handle development mode
+	if client == nil {
+		logger.Infof("🔧 [%s] Development mode - simulating history append", requestID)
+		return nil
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	item, err := attributevalue.MarshalMapWithOptions(entry, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "dynamodb"
+	})
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to marshal history entry: %v", requestID, err)
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(HistoryTableName()),
+		Item:      item,
+	}
+
+	_, err = client.PutItem(ctx, input)
+	if err != nil {
+		logger.Errorf("❌ [%s] DynamoDB PutItem failed for history entry: %v", requestID, err)
+		return fmt.Errorf("failed to append subscription history: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Infof("✅ [%s] Subscription history appended in %v", requestID, duration)
+	return nil
+}
+
+// GetSubscriptionHistory returns the most recent history entries for a user,
+// newest first
+func GetSubscriptionHistory(ctx context.Context, userID string, limit int32) ([]SubscriptionHistoryEntry, error) {
+	startTime := time.Now()
+	requestID := fmt.Sprintf("history-get-%d", startTime.UnixNano())
+
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+
+	logger.Infof("📜 [%s] Getting subscription history for user: %s (limit %d)", requestID, userID, limit)
+
+	// Handle development mode
+	if client == nil {
+		logger.Infof("🔧 [%s] Development mode - returning empty history", requestID)
+		return []SubscriptionHistoryEntry{}, nil
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(HistoryTableName()),
+		KeyConditionExpression: aws.String("user_id = :uid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false), // newest first
+		Limit:            aws.Int32(limit),
+	}
+
+	result, err := client.Query(ctx, input)
+	if err != nil {
+		logger.Errorf("❌ [%s] DynamoDB Query failed for history: %v", requestID, err)
+		return nil, fmt.Errorf("failed to get subscription history: %w", err)
+	}
+
+	entries := make([]SubscriptionHistoryEntry, 0, len(result.Items))
+	for i, item := range result.Items {
+		var entry SubscriptionHistoryEntry
+		err = attributevalue.UnmarshalMapWithOptions(item, &entry, func(o *attributevalue.DecoderOptions) {
+			o.TagKey = "dynamodb"
+		})
+		if err != nil {
+			logger.Errorf("❌ [%s] Failed to unmarshal history entry %d: %v", requestID, i, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	duration := time.Since(startTime)
+	logger.Infof("✅ [%s] Retrieved %d history entries in %v", requestID, len(entries), duration)
+	return entries, nil
+}
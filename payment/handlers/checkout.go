@@ -2,14 +2,15 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"payment/dynamo"
 	"payment/firebase"
+	"payment/logger"
 	"payment/lsz"
 
 	"github.com/gin-gonic/gin"
@@ -17,8 +18,11 @@ import (
 
 // CheckoutRequest represents the request body for creating a checkout
 type CheckoutRequest struct {
-	Tier      string `json:"tier" binding:"required"` // "plus" or "pro"
-	VariantID int    `json:"variant_id"`              // Optional, will be determined from tier if not provided
+	Tier         string `json:"tier" binding:"required"` // "plus" or "pro"
+	VariantID    int    `json:"variant_id"`              // Optional, will be determined from tier if not provided
+	DiscountCode string `json:"discount_code"`           // Optional promo code to pre-fill at checkout
+	RedirectURL  string `json:"redirect_url"`            // Optional post-purchase redirect, must match the host allow-list
+	ReceiptURL   string `json:"receipt_url"`             // Optional receipt link, must match the host allow-list
 }
 
 // CheckoutResponse represents the response for checkout creation
@@ -27,110 +31,220 @@ type CheckoutResponse struct {
 	Message     string `json:"message,omitempty"`
 }
 
+// CheckoutPreviewHandler handles GET /api/checkout/preview?tier=pro. It runs
+// the checkout in LemonSqueezy preview mode and returns the computed price,
+// tax, and discount so the frontend can show "You'll be charged $X" before
+// redirecting to a real checkout.
+func CheckoutPreviewHandler(c *gin.Context) {
+	startTime := time.Now()
+	requestID := fmt.Sprintf("preview-%d", startTime.UnixNano())
+
+	logger.Infof("💳 [%s] Checkout preview request started", requestID)
+	logger.Infof("💳 [%s] Client IP: %s", requestID, c.ClientIP())
+
+	// Extract Firebase ID token from Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return
+	}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		return
+	}
+	idToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	// Verify the Firebase ID token
+	uid, err := firebase.VerifyIDToken(context.Background(), idToken)
+	if err != nil {
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, uid)
+
+	// Get user record to get email
+	userRecord, err := firebase.GetUserRecord(context.Background(), uid)
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to get user record: %v", requestID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user information"})
+		return
+	}
+
+	// Validate tier
+	tier := c.Query("tier")
+	if tier != "plus" && tier != "pro" {
+		logger.Errorf("❌ [%s] Invalid tier specified: %s", requestID, tier)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tier. Must be 'plus' or 'pro'"})
+		return
+	}
+
+	variantID := lsz.GetTierVariantID(tier)
+	if variantID == 0 {
+		logger.Errorf("❌ [%s] Failed to determine variant ID for tier: %s", requestID, tier)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tier or variant ID not configured"})
+		return
+	}
+
+	// Run the checkout in preview mode
+	logger.Infof("🍋 [%s] Requesting LemonSqueezy checkout preview for tier %s (variant %d)...", requestID, tier, variantID)
+	previewResp, err := lsz.CreateCheckoutPreview(variantID, uid, userRecord.Email)
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to get checkout preview: %v", requestID, err)
+		if errors.Is(err, lsz.ErrProviderTimeout) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Payment provider timed out, please try again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get checkout preview"})
+		return
+	}
+
+	preview := previewResp.Data.Attributes.Preview
+	if preview == nil {
+		logger.Errorf("❌ [%s] Preview response missing pricing info", requestID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider returned no pricing info"})
+		return
+	}
+
+	duration := time.Since(startTime)
+	logger.Infof("✅ [%s] Checkout preview sent in %v (total %s)", requestID, duration, preview.TotalFormatted)
+
+	c.JSON(http.StatusOK, gin.H{
+		"tier":       tier,
+		"variant_id": variantID,
+		"preview":    preview,
+	})
+}
+
 // CreateCheckoutHandler handles POST /api/checkout
 func CreateCheckoutHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("checkout-%d", startTime.UnixNano())
 
-	log.Printf("💳 [%s] Create checkout request started", requestID)
-	log.Printf("💳 [%s] Client IP: %s", requestID, c.ClientIP())
-	log.Printf("💳 [%s] Headers: %+v", requestID, c.Request.Header)
+	logger.Infof("💳 [%s] Create checkout request started", requestID)
+	logger.Infof("💳 [%s] Client IP: %s", requestID, c.ClientIP())
+	logger.Infof("💳 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
 
 	// Extract Firebase ID token from Authorization header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		log.Printf("❌ [%s] No authorization header provided", requestID)
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 		return
 	}
 
 	// Check if the header has the Bearer prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("❌ [%s] Invalid authorization header format", requestID)
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 		return
 	}
 
 	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-	log.Printf("🔐 [%s] Authorization header present", requestID)
+	logger.Infof("🔐 [%s] Authorization header present", requestID)
 
 	// Verify the Firebase ID token
-	log.Printf("🔥 [%s] Verifying Firebase token...", requestID)
+	logger.Infof("🔥 [%s] Verifying Firebase token...", requestID)
 	uid, err := firebase.VerifyIDToken(context.Background(), idToken)
 	if err != nil {
-		log.Printf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 		return
 	}
 
-	log.Printf("✅ [%s] Firebase token verified for user: %s", requestID, uid)
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, uid)
 
 	// Get user record to get email
-	log.Printf("👤 [%s] Fetching user record from Firebase...", requestID)
+	logger.Infof("👤 [%s] Fetching user record from Firebase...", requestID)
 	userRecord, err := firebase.GetUserRecord(context.Background(), uid)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get user record: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get user record: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user information"})
 		return
 	}
 
-	log.Printf("✅ [%s] User record fetched - Email: %s", requestID, userRecord.Email)
+	logger.Infof("✅ [%s] User record fetched - Email: %s", requestID, userRecord.Email)
 
 	// Parse request body
-	log.Printf("📝 [%s] Parsing request body...", requestID)
+	logger.Infof("📝 [%s] Parsing request body...", requestID)
 	var req CheckoutRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("❌ [%s] Invalid request body: %v", requestID, err)
+		logger.Errorf("❌ [%s] Invalid request body: %v", requestID, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Request body parsed:", requestID)
-	log.Printf("   Tier: %s", req.Tier)
-	log.Printf("   Variant ID: %d", req.VariantID)
+	logger.Infof("✅ [%s] Request body parsed:", requestID)
+	logger.Infof("   Tier: %s", req.Tier)
+	logger.Infof("   Variant ID: %d", req.VariantID)
 
 	// Validate tier
 	if req.Tier != "plus" && req.Tier != "pro" {
-		log.Printf("❌ [%s] Invalid tier specified: %s", requestID, req.Tier)
+		logger.Errorf("❌ [%s] Invalid tier specified: %s", requestID, req.Tier)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tier. Must be 'plus' or 'pro'"})
 		return
 	}
 
-	log.Printf("✅ [%s] Tier validation passed: %s", requestID, req.Tier)
+	logger.Infof("✅ [%s] Tier validation passed: %s", requestID, req.Tier)
+
+	// Validate discount code format before it goes anywhere near the provider
+	if err := lsz.ValidateDiscountCode(req.DiscountCode); err != nil {
+		logger.Errorf("❌ [%s] Invalid discount code format: %s", requestID, req.DiscountCode)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discount code format"})
+		return
+	}
+	if req.DiscountCode != "" {
+		logger.Infof("🏷️ [%s] Discount code provided: %s", requestID, req.DiscountCode)
+	}
+
+	// Validate redirect/receipt overrides against the host allow-list so a
+	// checkout can't bounce users to an arbitrary site
+	if err := lsz.ValidateRedirectURL(req.RedirectURL); err != nil {
+		logger.Errorf("❌ [%s] Rejected redirect URL %q: %v", requestID, req.RedirectURL, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redirect URL", "details": err.Error()})
+		return
+	}
+	if err := lsz.ValidateRedirectURL(req.ReceiptURL); err != nil {
+		logger.Errorf("❌ [%s] Rejected receipt URL %q: %v", requestID, req.ReceiptURL, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt URL", "details": err.Error()})
+		return
+	}
 
 	// Determine variant ID if not provided
 	variantID := req.VariantID
 	if variantID == 0 {
-		log.Printf("🔍 [%s] No variant ID provided, determining from tier...", requestID)
+		logger.Infof("🔍 [%s] No variant ID provided, determining from tier...", requestID)
 		variantID = lsz.GetTierVariantID(req.Tier)
 		if variantID == 0 {
-			log.Printf("❌ [%s] Failed to determine variant ID for tier: %s", requestID, req.Tier)
+			logger.Errorf("❌ [%s] Failed to determine variant ID for tier: %s", requestID, req.Tier)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tier or variant ID not configured"})
 			return
 		}
-		log.Printf("✅ [%s] Variant ID determined: %d", requestID, variantID)
+		logger.Infof("✅ [%s] Variant ID determined: %d", requestID, variantID)
 	} else {
-		log.Printf("✅ [%s] Using provided variant ID: %d", requestID, variantID)
+		logger.Infof("✅ [%s] Using provided variant ID: %d", requestID, variantID)
 	}
 
 	// Check if user already has an active subscription
-	log.Printf("🔍 [%s] Checking for existing subscription...", requestID)
+	logger.Infof("🔍 [%s] Checking for existing subscription...", requestID)
 	existingSub, err := dynamo.GetSubscription(context.Background(), uid)
 	// if err != nil {
-	// 	log.Printf("❌ [%s] Failed to check existing subscription: %v", requestID, err)
+	// 	logger.Errorf("❌ [%s] Failed to check existing subscription: %v", requestID, err)
 	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing subscription"})
 	// 	return
 	// }
 
 	if existingSub != nil {
-		log.Printf("🔍 [%s] Existing subscription found:", requestID)
-		log.Printf("   Current Tier: %s", existingSub.Tier)
-		log.Printf("   Current Status: %s", existingSub.Status)
-		log.Printf("   Variant ID: %d", existingSub.VariantID)
+		logger.Infof("🔍 [%s] Existing subscription found:", requestID)
+		logger.Infof("   Current Tier: %s", existingSub.Tier)
+		logger.Infof("   Current Status: %s", existingSub.Status)
+		logger.Infof("   Variant ID: %d", existingSub.VariantID)
 
 		// Check if user already has the same tier subscription
 		if existingSub.Tier == req.Tier && lsz.IsValidSubscriptionStatus(existingSub.Status) {
-			log.Printf("❌ [%s] User already has active subscription to tier: %s", requestID, req.Tier)
+			logger.Errorf("❌ [%s] User already has active subscription to tier: %s", requestID, req.Tier)
 			c.JSON(http.StatusConflict, gin.H{
 				"error":        "Already subscribed to this tier",
 				"current_tier": existingSub.Tier,
@@ -139,26 +253,34 @@ func CreateCheckoutHandler(c *gin.Context) {
 			return
 		}
 
-		log.Printf("✅ [%s] User can upgrade/change subscription from %s to %s", requestID, existingSub.Tier, req.Tier)
+		logger.Infof("✅ [%s] User can upgrade/change subscription from %s to %s", requestID, existingSub.Tier, req.Tier)
 	} else {
-		log.Printf("✅ [%s] No existing subscription found, proceeding with new checkout", requestID)
+		logger.Infof("✅ [%s] No existing subscription found, proceeding with new checkout", requestID)
 	}
 
 	// Create checkout session with LemonSqueezy
-	log.Printf("🍋 [%s] Creating LemonSqueezy checkout session...", requestID)
-	log.Printf("   Variant ID: %d", variantID)
-	log.Printf("   User ID: %s", uid)
-	log.Printf("   Email: %s", userRecord.Email)
+	logger.Infof("🍋 [%s] Creating LemonSqueezy checkout session...", requestID)
+	logger.Infof("   Variant ID: %d", variantID)
+	logger.Infof("   User ID: %s", uid)
+	logger.Infof("   Email: %s", userRecord.Email)
 
-	checkoutResp, err := lsz.CreateCheckout(variantID, uid, userRecord.Email)
+	checkoutResp, err := lsz.CreateCheckout(variantID, uid, userRecord.Email, req.DiscountCode, req.RedirectURL, req.ReceiptURL)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to create checkout session: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to create checkout session: %v", requestID, err)
+		if errors.Is(err, lsz.ErrProviderTimeout) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Payment provider timed out, please try again"})
+			return
+		}
+		if lsz.IsDiscountError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired discount code", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkout session", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] LemonSqueezy checkout session created successfully", requestID)
-	log.Printf("   Checkout URL: %s", checkoutResp.Data.Attributes.URL)
+	logger.Infof("✅ [%s] LemonSqueezy checkout session created successfully", requestID)
+	logger.Infof("   Checkout URL: %s", checkoutResp.Data.Attributes.URL)
 
 	// Return checkout URL
 	response := CheckoutResponse{
@@ -167,7 +289,7 @@ func CreateCheckoutHandler(c *gin.Context) {
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Checkout response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Checkout response sent in %v", requestID, duration)
 
 	c.JSON(http.StatusOK, response)
 }
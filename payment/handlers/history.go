@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"payment/dynamo"
+	"payment/firebase"
+	"payment/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSubscriptionHistoryHandler handles GET /api/subscription/history. It
+// returns the authenticated user's billing-state timeline: every tier or
+// status change recorded from webhooks, newest first.
+func GetSubscriptionHistoryHandler(c *gin.Context) {
+	startTime := time.Now()
+	requestID := fmt.Sprintf("history-%d", startTime.UnixNano())
+
+	logger.Infof("📜 [%s] Subscription history request started", requestID)
+	logger.Infof("📜 [%s] Client IP: %s", requestID, c.ClientIP())
+
+	// Extract Firebase ID token from Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return
+	}
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		return
+	}
+	idToken := strings.TrimPrefix(authHeader, "Bearer ")
+
+	// Verify the Firebase ID token — users can only see their own history
+	uid, err := firebase.VerifyIDToken(context.Background(), idToken)
+	if err != nil {
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, uid)
+
+	entries, err := dynamo.GetSubscriptionHistory(context.Background(), uid, 0)
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to get subscription history: %v", requestID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription history"})
+		return
+	}
+
+	duration := time.Since(startTime)
+	logger.Infof("✅ [%s] Subscription history sent (%d entries) in %v", requestID, len(entries), duration)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": uid,
+		"history": entries,
+	})
+}
@@ -3,13 +3,13 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"payment/dynamo"
 	"payment/firebase"
+	"payment/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,7 +17,7 @@ import (
 // HealthCheckHandler handles GET /health
 func HealthCheckHandler(c *gin.Context) {
 	requestID := fmt.Sprintf("health-%d", time.Now().UnixNano())
-	log.Printf("💗 [%s] Health check requested from %s", requestID, c.ClientIP())
+	logger.Infof("💗 [%s] Health check requested from %s", requestID, c.ClientIP())
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "healthy",
@@ -26,7 +26,7 @@ func HealthCheckHandler(c *gin.Context) {
 		"version":   "1.0.0",
 	})
 
-	log.Printf("✅ [%s] Health check completed successfully", requestID)
+	logger.Infof("✅ [%s] Health check completed successfully", requestID)
 }
 
 // GetUserTierHandler handles GET /api/tier
@@ -34,51 +34,51 @@ func GetUserTierHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("tier-%d", startTime.UnixNano())
 
-	log.Printf("🎫 [%s] Get user tier request started", requestID)
-	log.Printf("🎫 [%s] Client IP: %s", requestID, c.ClientIP())
-	log.Printf("🎫 [%s] Headers: %+v", requestID, c.Request.Header)
+	logger.Infof("🎫 [%s] Get user tier request started", requestID)
+	logger.Infof("🎫 [%s] Client IP: %s", requestID, c.ClientIP())
+	logger.Infof("🎫 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
 
 	// Get the authorization header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		log.Printf("❌ [%s] No authorization header provided", requestID)
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 		return
 	}
 
 	// Check if the header has the Bearer prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("❌ [%s] Invalid authorization header format", requestID)
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 		return
 	}
 
 	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-	log.Printf("🔐 [%s] Authorization header present", requestID)
+	logger.Infof("🔐 [%s] Authorization header present", requestID)
 
 	// Verify the Firebase token
-	log.Printf("🔥 [%s] Verifying Firebase token...", requestID)
+	logger.Infof("🔥 [%s] Verifying Firebase token...", requestID)
 	userID, err := firebase.VerifyIDToken(context.Background(), idToken)
 	if err != nil {
-		log.Printf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Firebase token verified for user: %s", requestID, userID)
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, userID)
 
 	// Get user subscription
-	log.Printf("🗄️ [%s] Fetching subscription for user %s", requestID, userID)
+	logger.Infof("🗄️ [%s] Fetching subscription for user %s", requestID, userID)
 	ctx := context.Background()
 	subscription, err := dynamo.GetSubscription(ctx, userID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription", "details": err.Error()})
 		return
 	}
 
 	if subscription == nil {
-		log.Printf("📋 [%s] No subscription found for user %s, returning free tier", requestID, userID)
+		logger.Infof("📋 [%s] No subscription found for user %s, returning free tier", requestID, userID)
 		duration := time.Since(startTime)
 		c.JSON(http.StatusOK, gin.H{
 			"tier":            "free",
@@ -86,18 +86,18 @@ func GetUserTierHandler(c *gin.Context) {
 			"request_id":      requestID,
 			"processing_time": duration.String(),
 		})
-		log.Printf("✅ [%s] Tier response sent (free) in %v", requestID, duration)
+		logger.Infof("✅ [%s] Tier response sent (free) in %v", requestID, duration)
 		return
 	}
 
-	log.Printf("📋 [%s] Subscription found for user %s:", requestID, userID)
-	log.Printf("   Tier: %s", subscription.Tier)
-	log.Printf("   Status: %s", subscription.Status)
-	log.Printf("   Variant ID: %d", subscription.VariantID)
-	log.Printf("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
-	log.Printf("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("📋 [%s] Subscription found for user %s:", requestID, userID)
+	logger.Infof("   Tier: %s", subscription.Tier)
+	logger.Infof("   Status: %s", subscription.Status)
+	logger.Infof("   Variant ID: %d", subscription.VariantID)
+	logger.Infof("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
+	logger.Infof("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
 	if subscription.ExpiresAt != nil {
-		log.Printf("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
+		logger.Infof("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
 	}
 
 	duration := time.Since(startTime)
@@ -116,7 +116,7 @@ func GetUserTierHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, response)
-	log.Printf("✅ [%s] Tier response sent (%s) in %v", requestID, subscription.Tier, duration)
+	logger.Infof("✅ [%s] Tier response sent (%s) in %v", requestID, subscription.Tier, duration)
 }
 
 // GetSubscriptionDetailsHandler handles GET /api/subscription
@@ -124,51 +124,51 @@ func GetSubscriptionDetailsHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("subscription-%d", startTime.UnixNano())
 
-	log.Printf("📄 [%s] Get subscription details request started", requestID)
-	log.Printf("📄 [%s] Client IP: %s", requestID, c.ClientIP())
-	log.Printf("📄 [%s] Headers: %+v", requestID, c.Request.Header)
+	logger.Infof("📄 [%s] Get subscription details request started", requestID)
+	logger.Infof("📄 [%s] Client IP: %s", requestID, c.ClientIP())
+	logger.Infof("📄 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
 
 	// Get the authorization header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		log.Printf("❌ [%s] No authorization header provided", requestID)
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 		return
 	}
 
 	// Check if the header has the Bearer prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("❌ [%s] Invalid authorization header format", requestID)
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 		return
 	}
 
 	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-	log.Printf("🔐 [%s] Authorization header present", requestID)
+	logger.Infof("🔐 [%s] Authorization header present", requestID)
 
 	// Verify the Firebase token
-	log.Printf("🔥 [%s] Verifying Firebase token...", requestID)
+	logger.Infof("🔥 [%s] Verifying Firebase token...", requestID)
 	userID, err := firebase.VerifyIDToken(context.Background(), idToken)
 	if err != nil {
-		log.Printf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Firebase token verified for user: %s", requestID, userID)
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, userID)
 
 	// Get user subscription
-	log.Printf("🗄️ [%s] Fetching subscription details for user %s", requestID, userID)
+	logger.Infof("🗄️ [%s] Fetching subscription details for user %s", requestID, userID)
 	ctx := context.Background()
 	subscription, err := dynamo.GetSubscription(ctx, userID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription", "details": err.Error()})
 		return
 	}
 
 	if subscription == nil {
-		log.Printf("📋 [%s] No subscription found for user %s", requestID, userID)
+		logger.Infof("📋 [%s] No subscription found for user %s", requestID, userID)
 		duration := time.Since(startTime)
 		c.JSON(http.StatusOK, gin.H{
 			"subscription":    nil,
@@ -176,22 +176,22 @@ func GetSubscriptionDetailsHandler(c *gin.Context) {
 			"request_id":      requestID,
 			"processing_time": duration.String(),
 		})
-		log.Printf("✅ [%s] No subscription response sent in %v", requestID, duration)
+		logger.Infof("✅ [%s] No subscription response sent in %v", requestID, duration)
 		return
 	}
 
-	log.Printf("📋 [%s] Full subscription details for user %s:", requestID, userID)
-	log.Printf("   UserID: %s", subscription.UserID)
-	log.Printf("   Tier: %s", subscription.Tier)
-	log.Printf("   Status: %s", subscription.Status)
-	log.Printf("   Variant ID: %d", subscription.VariantID)
-	log.Printf("   SubID: %s", subscription.SubID)
-	log.Printf("   CustomerID: %s", subscription.CustomerID)
-	log.Printf("   Email: %s", subscription.Email)
-	log.Printf("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
-	log.Printf("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
+	logger.Infof("📋 [%s] Full subscription details for user %s:", requestID, userID)
+	logger.Infof("   UserID: %s", subscription.UserID)
+	logger.Infof("   Tier: %s", subscription.Tier)
+	logger.Infof("   Status: %s", subscription.Status)
+	logger.Infof("   Variant ID: %d", subscription.VariantID)
+	logger.Infof("   SubID: %s", subscription.SubID)
+	logger.Infof("   CustomerID: %s", subscription.CustomerID)
+	logger.Infof("   Email: %s", subscription.Email)
+	logger.Infof("   Created: %s", subscription.CreatedAt.Format(time.RFC3339))
+	logger.Infof("   Updated: %s", subscription.UpdatedAt.Format(time.RFC3339))
 	if subscription.ExpiresAt != nil {
-		log.Printf("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
+		logger.Infof("   Expires: %s", subscription.ExpiresAt.Format(time.RFC3339))
 	}
 
 	duration := time.Since(startTime)
@@ -215,8 +215,20 @@ func GetSubscriptionDetailsHandler(c *gin.Context) {
 		response["subscription"].(gin.H)["expires_at"] = subscription.ExpiresAt.Format(time.RFC3339)
 	}
 
+	// Trial state for the frontend ("X days left in trial"). A trial whose
+	// end has passed is not reported as on trial even if the status-flipping
+	// webhook hasn't arrived yet.
+	onTrial := subscription.Status == "on_trial" || subscription.Status == "trialing"
+	if onTrial && subscription.TrialEndsAt != nil && time.Now().After(*subscription.TrialEndsAt) {
+		onTrial = false
+	}
+	response["subscription"].(gin.H)["on_trial"] = onTrial
+	if subscription.TrialEndsAt != nil {
+		response["subscription"].(gin.H)["trial_ends_at"] = subscription.TrialEndsAt.Format(time.RFC3339)
+	}
+
 	c.JSON(http.StatusOK, response)
-	log.Printf("✅ [%s] Subscription details response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Subscription details response sent in %v", requestID, duration)
 }
 
 // GetSubscriptionURLsHandler handles GET /api/subscription/urls
@@ -224,51 +236,51 @@ func GetSubscriptionURLsHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("urls-%d", startTime.UnixNano())
 
-	log.Printf("🔗 [%s] Get subscription URLs request started", requestID)
-	log.Printf("🔗 [%s] Client IP: %s", requestID, c.ClientIP())
-	log.Printf("🔗 [%s] Headers: %+v", requestID, c.Request.Header)
+	logger.Infof("🔗 [%s] Get subscription URLs request started", requestID)
+	logger.Infof("🔗 [%s] Client IP: %s", requestID, c.ClientIP())
+	logger.Infof("🔗 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
 
 	// Get the authorization header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		log.Printf("❌ [%s] No authorization header provided", requestID)
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 		return
 	}
 
 	// Check if the header has the Bearer prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("❌ [%s] Invalid authorization header format", requestID)
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 		return
 	}
 
 	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-	log.Printf("🔐 [%s] Authorization header present", requestID)
+	logger.Infof("🔐 [%s] Authorization header present", requestID)
 
 	// Verify the Firebase token
-	log.Printf("🔥 [%s] Verifying Firebase token...", requestID)
+	logger.Infof("🔥 [%s] Verifying Firebase token...", requestID)
 	userID, err := firebase.VerifyIDToken(context.Background(), idToken)
 	if err != nil {
-		log.Printf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Firebase token verified for user: %s", requestID, userID)
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, userID)
 
 	// Get user subscription
-	log.Printf("🗄️ [%s] Fetching subscription URLs for user %s", requestID, userID)
+	logger.Infof("🗄️ [%s] Fetching subscription URLs for user %s", requestID, userID)
 	ctx := context.Background()
 	subscription, err := dynamo.GetSubscription(ctx, userID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription", "details": err.Error()})
 		return
 	}
 
 	if subscription == nil {
-		log.Printf("📋 [%s] No subscription found for user %s", requestID, userID)
+		logger.Infof("📋 [%s] No subscription found for user %s", requestID, userID)
 		duration := time.Since(startTime)
 		c.JSON(http.StatusOK, gin.H{
 			"urls":            nil,
@@ -276,14 +288,14 @@ func GetSubscriptionURLsHandler(c *gin.Context) {
 			"request_id":      requestID,
 			"processing_time": duration.String(),
 		})
-		log.Printf("✅ [%s] No subscription URLs response sent in %v", requestID, duration)
+		logger.Infof("✅ [%s] No subscription URLs response sent in %v", requestID, duration)
 		return
 	}
 
-	log.Printf("🔗 [%s] Subscription URLs for user %s:", requestID, userID)
-	log.Printf("   CustomerPortalURL: %s", subscription.CustomerPortalURL)
-	log.Printf("   UpdatePaymentMethodURL: %s", subscription.UpdatePaymentMethodURL)
-	log.Printf("   CustomerPortalUpdateSubscriptionURL: %s", subscription.CustomerPortalUpdateSubscriptionURL)
+	logger.Infof("🔗 [%s] Subscription URLs for user %s:", requestID, userID)
+	logger.Infof("   CustomerPortalURL: %s", subscription.CustomerPortalURL)
+	logger.Infof("   UpdatePaymentMethodURL: %s", subscription.UpdatePaymentMethodURL)
+	logger.Infof("   CustomerPortalUpdateSubscriptionURL: %s", subscription.CustomerPortalUpdateSubscriptionURL)
 
 	duration := time.Since(startTime)
 	response := gin.H{
@@ -297,7 +309,7 @@ func GetSubscriptionURLsHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, response)
-	log.Printf("✅ [%s] Subscription URLs response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Subscription URLs response sent in %v", requestID, duration)
 }
 
 // CancelSubscriptionHandler handles POST /api/cancel-subscription
@@ -305,69 +317,69 @@ func CancelSubscriptionHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("cancel-%d", startTime.UnixNano())
 
-	log.Printf("❌ [%s] Cancel subscription request started", requestID)
-	log.Printf("❌ [%s] Client IP: %s", requestID, c.ClientIP())
-	log.Printf("❌ [%s] Headers: %+v", requestID, c.Request.Header)
+	logger.Infof("🚫 [%s] Cancel subscription request started", requestID)
+	logger.Infof("🚫 [%s] Client IP: %s", requestID, c.ClientIP())
+	logger.Infof("🚫 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
 
 	// Get the authorization header
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		log.Printf("❌ [%s] No authorization header provided", requestID)
+		logger.Errorf("❌ [%s] No authorization header provided", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 		return
 	}
 
 	// Check if the header has the Bearer prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Printf("❌ [%s] Invalid authorization header format", requestID)
+		logger.Errorf("❌ [%s] Invalid authorization header format", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 		return
 	}
 
 	idToken := strings.TrimPrefix(authHeader, "Bearer ")
-	log.Printf("🔐 [%s] Authorization header present", requestID)
+	logger.Infof("🔐 [%s] Authorization header present", requestID)
 
 	// Verify the Firebase token
-	log.Printf("🔥 [%s] Verifying Firebase token...", requestID)
+	logger.Infof("🔥 [%s] Verifying Firebase token...", requestID)
 	userID, err := firebase.VerifyIDToken(context.Background(), idToken)
 	if err != nil {
-		log.Printf("❌ [%s] Firebase token verification failed: %v", requestID, err)
+		logger.Errorf("❌ [%s] Firebase token verification failed: %v", requestID, err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Firebase token verified for user: %s", requestID, userID)
+	logger.Infof("✅ [%s] Firebase token verified for user: %s", requestID, userID)
 
 	// Get user subscription
-	log.Printf("🗄️ [%s] Fetching subscription for cancellation: %s", requestID, userID)
+	logger.Infof("🗄️ [%s] Fetching subscription for cancellation: %s", requestID, userID)
 	ctx := context.Background()
 	subscription, err := dynamo.GetSubscription(ctx, userID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get subscription from database: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription", "details": err.Error()})
 		return
 	}
 
 	if subscription == nil {
-		log.Printf("❌ [%s] No subscription found for user %s", requestID, userID)
+		logger.Errorf("❌ [%s] No subscription found for user %s", requestID, userID)
 		duration := time.Since(startTime)
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":           "No active subscription found",
 			"request_id":      requestID,
 			"processing_time": duration.String(),
 		})
-		log.Printf("❌ [%s] No subscription found response sent in %v", requestID, duration)
+		logger.Errorf("❌ [%s] No subscription found response sent in %v", requestID, duration)
 		return
 	}
 
-	log.Printf("❌ [%s] Found subscription to cancel:", requestID)
-	log.Printf("   Tier: %s", subscription.Tier)
-	log.Printf("   Status: %s", subscription.Status)
-	log.Printf("   SubID: %s", subscription.SubID)
+	logger.Infof("🚫 [%s] Found subscription to cancel:", requestID)
+	logger.Infof("   Tier: %s", subscription.Tier)
+	logger.Infof("   Status: %s", subscription.Status)
+	logger.Infof("   SubID: %s", subscription.SubID)
 
 	// Check if already cancelled
 	if subscription.Status == "cancelled" {
-		log.Printf("⚠️ [%s] Subscription already cancelled for user %s", requestID, userID)
+		logger.Warnf("⚠️ [%s] Subscription already cancelled for user %s", requestID, userID)
 		duration := time.Since(startTime)
 		c.JSON(http.StatusOK, gin.H{
 			"message":         "Subscription is already cancelled",
@@ -375,23 +387,23 @@ func CancelSubscriptionHandler(c *gin.Context) {
 			"request_id":      requestID,
 			"processing_time": duration.String(),
 		})
-		log.Printf("⚠️ [%s] Already cancelled response sent in %v", requestID, duration)
+		logger.Warnf("⚠️ [%s] Already cancelled response sent in %v", requestID, duration)
 		return
 	}
 
 	// Update subscription status to cancelled
-	log.Printf("❌ [%s] Marking subscription as cancelled in database", requestID)
+	logger.Infof("🚫 [%s] Marking subscription as cancelled in database", requestID)
 	subscription.Status = "cancelled"
 	subscription.UpdatedAt = time.Now()
 
 	err = dynamo.SaveSubscriptionDetailed(ctx, *subscription)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to update subscription status: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to update subscription status: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel subscription", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Subscription cancelled successfully for user %s", requestID, userID)
+	logger.Infof("✅ [%s] Subscription cancelled successfully for user %s", requestID, userID)
 
 	duration := time.Since(startTime)
 	response := gin.H{
@@ -403,5 +415,5 @@ func CancelSubscriptionHandler(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, response)
-	log.Printf("✅ [%s] Cancellation response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Cancellation response sent in %v", requestID, duration)
 }
@@ -3,9 +3,9 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"payment/dynamo"
+	"payment/logger"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -28,10 +28,10 @@ func GetSubscriptionStatusHandler(c *gin.Context) {
 	userID := c.Param("user_id")
 	requestID := fmt.Sprintf("subscription-status-%d", startTime.UnixNano())
 
-	log.Printf("📊 [%s] Get subscription status request for user: %s", requestID, userID)
+	logger.Infof("📊 [%s] Get subscription status request for user: %s", requestID, userID)
 
 	if userID == "" {
-		log.Printf("❌ [%s] User ID is required", requestID)
+		logger.Errorf("❌ [%s] User ID is required", requestID)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
 		return
 	}
@@ -39,21 +39,21 @@ func GetSubscriptionStatusHandler(c *gin.Context) {
 	// Get subscription from DynamoDB
 	subscription, err := dynamo.GetSubscription(context.Background(), userID)
 	if err != nil {
-		log.Printf("❌ [%s] Error getting subscription for user %s: %v", requestID, userID, err)
+		logger.Errorf("❌ [%s] Error getting subscription for user %s: %v", requestID, userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
 	// If subscription not found, return 404
 	if subscription == nil {
-		log.Printf("❌ [%s] Subscription not found for user: %s", requestID, userID)
+		logger.Errorf("❌ [%s] Subscription not found for user: %s", requestID, userID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
 		return
 	}
 
 	// Check if subscription is expired
 	if subscription.ExpiresAt != nil && time.Now().After(*subscription.ExpiresAt) {
-		log.Printf("⏰ [%s] Subscription expired for user: %s", requestID, userID)
+		logger.Infof("⏰ [%s] Subscription expired for user: %s", requestID, userID)
 		subscription.Status = "expired"
 		subscription.Tier = "free"
 	}
@@ -70,7 +70,7 @@ func GetSubscriptionStatusHandler(c *gin.Context) {
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Subscription status response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Subscription status response sent in %v", requestID, duration)
 
 	c.JSON(http.StatusOK, response)
 }
@@ -81,10 +81,10 @@ func GetUserManagementURLHandler(c *gin.Context) {
 	userID := c.Param("user_id")
 	requestID := fmt.Sprintf("management-url-%d", startTime.UnixNano())
 
-	log.Printf("🔗 [%s] Get management URL request for user: %s", requestID, userID)
+	logger.Infof("🔗 [%s] Get management URL request for user: %s", requestID, userID)
 
 	if userID == "" {
-		log.Printf("❌ [%s] User ID is required", requestID)
+		logger.Errorf("❌ [%s] User ID is required", requestID)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
 		return
 	}
@@ -92,30 +92,30 @@ func GetUserManagementURLHandler(c *gin.Context) {
 	// Get subscription from DynamoDB
 	subscription, err := dynamo.GetSubscription(context.Background(), userID)
 	if err != nil {
-		log.Printf("❌ [%s] Error getting subscription for user %s: %v", requestID, userID, err)
+		logger.Errorf("❌ [%s] Error getting subscription for user %s: %v", requestID, userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	log.Printf("🔍 [%s] Subscription: %+v", requestID, subscription)
+	logger.Infof("🔍 [%s] Subscription: %+v", requestID, subscription)
 
 	// If no subscription found, return error
 	if subscription == nil {
-		log.Printf("❌ [%s] No subscription found for user: %s", requestID, userID)
+		logger.Errorf("❌ [%s] No subscription found for user: %s", requestID, userID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "No subscription found"})
 		return
 	}
 
 	// Check if subscription is active
 	if subscription.Status != "active" {
-		log.Printf("❌ [%s] Subscription not active for user: %s, status: %s", requestID, userID, subscription.Status)
+		logger.Errorf("❌ [%s] Subscription not active for user: %s, status: %s", requestID, userID, subscription.Status)
 		c.JSON(http.StatusNotFound, gin.H{"error": "No active subscription found"})
 		return
 	}
 
 	// Check if subscription has a LemonSqueezy subscription ID
 	if subscription.SubID == "" {
-		log.Printf("❌ [%s] Subscription missing LemonSqueezy ID for user: %s", requestID, userID)
+		logger.Errorf("❌ [%s] Subscription missing LemonSqueezy ID for user: %s", requestID, userID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Management URL not available - subscription created outside of LemonSqueezy"})
 		return
 	}
@@ -123,7 +123,7 @@ func GetUserManagementURLHandler(c *gin.Context) {
 	// Use the customer portal URL stored in the subscription
 	managementURL := subscription.CustomerPortalURL
 	if managementURL == "" {
-		log.Printf("❌ [%s] No customer portal URL found for subscription %s", requestID, subscription.SubID)
+		logger.Errorf("❌ [%s] No customer portal URL found for subscription %s", requestID, subscription.SubID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Management URL not available"})
 		return
 	}
@@ -133,7 +133,7 @@ func GetUserManagementURLHandler(c *gin.Context) {
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Management URL response sent in %v", requestID, duration)
+	logger.Infof("✅ [%s] Management URL response sent in %v", requestID, duration)
 
 	c.JSON(http.StatusOK, response)
 }
@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"payment/dynamo"
+	"payment/logger"
 	"payment/lsz"
+	"payment/redis"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,34 +22,35 @@ func WebhookHandler(c *gin.Context) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("webhook-%d", startTime.UnixNano())
 
-	log.Printf("🪝 [%s] Webhook request started", requestID)
-	log.Printf("🪝 [%s] Method: %s, URL: %s", requestID, c.Request.Method, c.Request.URL.String())
-	log.Printf("🪝 [%s] Headers: %+v", requestID, c.Request.Header)
-	log.Printf("🪝 [%s] Remote Address: %s", requestID, c.ClientIP())
+	logger.Infof("🪝 [%s] Webhook request started", requestID)
+	logger.Infof("🪝 [%s] Method: %s, URL: %s", requestID, c.Request.Method, c.Request.URL.String())
+	logger.Infof("🪝 [%s] Headers: %+v", requestID, logger.RedactHeaders(c.Request.Header))
+	logger.Infof("🪝 [%s] Remote Address: %s", requestID, c.ClientIP())
 
 	// Read the request body
-	log.Printf("🪝 [%s] Reading request body...", requestID)
+	logger.Infof("🪝 [%s] Reading request body...", requestID)
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to read request body: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to read request body: %v", requestID, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 		return
 	}
 
 	bodySize := len(body)
-	log.Printf("🪝 [%s] Request body read successfully (%d bytes)", requestID, bodySize)
+	logger.Infof("🪝 [%s] Request body read successfully (%d bytes)", requestID, bodySize)
 	if bodySize > 0 {
-		// Log first 200 characters of body for debugging (be careful with sensitive data)
+		// Log first 200 characters of body for debugging, with secret- and
+		// PII-bearing fields masked
 		preview := string(body)
 		if len(preview) > 200 {
 			preview = preview[:200] + "..."
 		}
-		log.Printf("🪝 [%s] Body preview: %s", requestID, preview)
+		logger.Infof("🪝 [%s] Body preview: %s", requestID, logger.RedactSecretFields(preview))
 	}
 
 	// Get the signature from headers
 	signature := c.GetHeader("X-Signature")
-	log.Printf("🪝 [%s] X-Signature header: %s", requestID,
+	logger.Infof("🪝 [%s] X-Signature header: %s", requestID,
 		func() string {
 			if signature == "" {
 				return "❌ Not provided"
@@ -56,44 +59,44 @@ func WebhookHandler(c *gin.Context) {
 		}())
 
 	// Verify webhook signature (if configured)
-	log.Printf("🪝 [%s] Verifying webhook signature...", requestID)
+	logger.Infof("🪝 [%s] Verifying webhook signature...", requestID)
 	if !lsz.VerifyWebhookSignature(body, signature) {
-		log.Printf("❌ [%s] Invalid webhook signature verification failed", requestID)
-		log.Printf("❌ [%s] Request rejected due to signature mismatch", requestID)
+		logger.Errorf("❌ [%s] Invalid webhook signature verification failed", requestID)
+		logger.Errorf("❌ [%s] Request rejected due to signature mismatch", requestID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
 		return
 	}
-	log.Printf("✅ [%s] Webhook signature verified successfully", requestID)
+	logger.Infof("✅ [%s] Webhook signature verified successfully", requestID)
 
 	// Parse the webhook payload
-	log.Printf("🪝 [%s] Parsing webhook payload...", requestID)
+	logger.Infof("🪝 [%s] Parsing webhook payload...", requestID)
 	var payload lsz.WebhookPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("❌ [%s] Failed to parse webhook payload: %v", requestID, err)
-		log.Printf("❌ [%s] Invalid JSON in request body", requestID)
+		logger.Errorf("❌ [%s] Failed to parse webhook payload: %v", requestID, err)
+		logger.Errorf("❌ [%s] Invalid JSON in request body", requestID)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload", "details": err.Error()})
 		return
 	}
 
-	log.Printf("✅ [%s] Webhook payload parsed successfully", requestID)
-	log.Printf("🪝 [%s] Event: %s", requestID, payload.Meta.EventName)
-	log.Printf("🪝 [%s] Subscription ID: %s", requestID, payload.Data.ID)
-	log.Printf("🪝 [%s] Customer ID: %d", requestID, payload.Data.Attributes.CustomerID)
-	log.Printf("🪝 [%s] User Email: %s", requestID, payload.Data.Attributes.UserEmail)
-	log.Printf("🪝 [%s] Status: %s", requestID, payload.Data.Attributes.Status)
-	log.Printf("🪝 [%s] Variant ID: %d", requestID, payload.Data.Attributes.VariantID)
+	logger.Infof("✅ [%s] Webhook payload parsed successfully", requestID)
+	logger.Infof("🪝 [%s] Event: %s", requestID, payload.Meta.EventName)
+	logger.Infof("🪝 [%s] Subscription ID: %s", requestID, payload.Data.ID)
+	logger.Infof("🪝 [%s] Customer ID: %d", requestID, payload.Data.Attributes.CustomerID)
+	logger.Infof("🪝 [%s] User Email: %s", requestID, payload.Data.Attributes.UserEmail)
+	logger.Infof("🪝 [%s] Status: %s", requestID, payload.Data.Attributes.Status)
+	logger.Infof("🪝 [%s] Variant ID: %d", requestID, payload.Data.Attributes.VariantID)
 
 	// Process the webhook event
-	log.Printf("🪝 [%s] Processing webhook event...", requestID)
+	logger.Infof("🪝 [%s] Processing webhook event...", requestID)
 	err = processWebhookEvent(payload, requestID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to process webhook: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to process webhook: %v", requestID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook", "details": err.Error()})
 		return
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Webhook processed successfully in %v", requestID, duration)
+	logger.Infof("✅ [%s] Webhook processed successfully in %v", requestID, duration)
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Webhook processed successfully",
 		"request_id":      requestID,
@@ -102,59 +105,86 @@ func WebhookHandler(c *gin.Context) {
 }
 
 // processWebhookEvent processes different types of webhook events
+// defaultWebhookMaxAge bounds how old an event may be before it is rejected
+const defaultWebhookMaxAge = 24 * time.Hour
+
+// webhookMaxAge returns the maximum accepted age for webhook events; a value
+// of 0 disables the check
+func webhookMaxAge() time.Duration {
+	if value := os.Getenv("WEBHOOK_MAX_AGE"); value != "" {
+		if maxAge, err := time.ParseDuration(value); err == nil && maxAge >= 0 {
+			return maxAge
+		}
+		logger.Warnf("⚠️ Invalid WEBHOOK_MAX_AGE value '%s', using default %s", value, defaultWebhookMaxAge)
+	}
+	return defaultWebhookMaxAge
+}
+
 func processWebhookEvent(payload lsz.WebhookPayload, requestID string) error {
-	log.Printf("🔄 [%s] Processing event: %s", requestID, payload.Meta.EventName)
+	logger.Infof("🔄 [%s] Processing event: %s", requestID, payload.Meta.EventName)
+
+	// Reject events that are too old to act on — a replayed or long-delayed
+	// webhook could otherwise overwrite a newer subscription state. The check
+	// is skipped when the payload carries no updated_at timestamp.
+	if maxAge := webhookMaxAge(); maxAge > 0 && payload.Data.Attributes.UpdatedAt != "" {
+		if updatedAt, err := time.Parse(time.RFC3339, payload.Data.Attributes.UpdatedAt); err != nil {
+			logger.Warnf("⚠️ [%s] Could not parse updated_at '%s', skipping age check: %v", requestID, payload.Data.Attributes.UpdatedAt, err)
+		} else if age := time.Since(updatedAt); age > maxAge {
+			logger.Errorf("❌ [%s] Rejecting stale webhook event %s: updated_at %s is %s old (max %s)", requestID, payload.Meta.EventName, updatedAt.Format(time.RFC3339), age.Round(time.Second), maxAge)
+			return fmt.Errorf("webhook event is too old: %s (max %s)", age.Round(time.Second), maxAge)
+		}
+	}
 
 	// Extract user ID from custom data
-	log.Printf("🔍 [%s] Extracting user ID from custom data...", requestID)
+	logger.Infof("🔍 [%s] Extracting user ID from custom data...", requestID)
 	userID := ""
 	if customData, ok := payload.Data.Attributes.CustomData["user_id"]; ok {
 		if uid, ok := customData.(string); ok {
 			userID = uid
-			log.Printf("✅ [%s] User ID found in attributes custom data: %s", requestID, userID)
+			logger.Infof("✅ [%s] User ID found in attributes custom data: %s", requestID, userID)
 		}
 	}
 
 	// If no user ID found, try to get from custom field
 	if userID == "" && payload.Meta.CustomData != nil {
-		log.Printf("🔍 [%s] Trying to get user ID from meta custom data...", requestID)
+		logger.Infof("🔍 [%s] Trying to get user ID from meta custom data...", requestID)
 		if customUserID, ok := payload.Meta.CustomData["user_id"]; ok {
 			if uid, ok := customUserID.(string); ok {
 				userID = uid
-				log.Printf("✅ [%s] User ID found in meta custom data: %s", requestID, userID)
+				logger.Infof("✅ [%s] User ID found in meta custom data: %s", requestID, userID)
 			}
 		}
 	}
 
 	if userID == "" {
-		log.Printf("❌ [%s] No user_id found in webhook payload", requestID)
-		log.Printf("❌ [%s] Attributes custom data: %+v", requestID, payload.Data.Attributes.CustomData)
-		log.Printf("❌ [%s] Meta custom data: %+v", requestID, payload.Meta.CustomData)
+		logger.Errorf("❌ [%s] No user_id found in webhook payload", requestID)
+		logger.Errorf("❌ [%s] Attributes custom data: %+v", requestID, payload.Data.Attributes.CustomData)
+		logger.Errorf("❌ [%s] Meta custom data: %+v", requestID, payload.Meta.CustomData)
 		return fmt.Errorf("no user_id found in webhook payload")
 	}
 
 	// Get the tier from variant ID
-	log.Printf("🔍 [%s] Getting tier for variant ID: %d", requestID, payload.Data.Attributes.VariantID)
+	logger.Infof("🔍 [%s] Getting tier for variant ID: %d", requestID, payload.Data.Attributes.VariantID)
 	tier := lsz.GetVariantTier(payload.Data.Attributes.VariantID)
-	log.Printf("🎫 [%s] Variant %d mapped to tier: %s", requestID, payload.Data.Attributes.VariantID, tier)
+	logger.Infof("🎫 [%s] Variant %d mapped to tier: %s", requestID, payload.Data.Attributes.VariantID, tier)
 
 	if tier == "free" {
-		log.Printf("❌ [%s] Invalid variant ID: %d (mapped to free tier)", requestID, payload.Data.Attributes.VariantID)
+		logger.Errorf("❌ [%s] Invalid variant ID: %d (mapped to free tier)", requestID, payload.Data.Attributes.VariantID)
 		return fmt.Errorf("invalid variant ID: %d", payload.Data.Attributes.VariantID)
 	}
 
 	// Create subscription object
-	log.Printf("🏗️ [%s] Creating subscription object for user %s", requestID, userID)
+	logger.Infof("🏗️ [%s] Creating subscription object for user %s", requestID, userID)
 
 	// Debug: Log all the values being used to create the subscription
-	log.Printf("🔍 [%s] Subscription creation data:", requestID)
-	log.Printf("   UserID: '%s' (length: %d)", userID, len(userID))
-	log.Printf("   Tier: '%s'", tier)
-	log.Printf("   VariantID: %d", payload.Data.Attributes.VariantID)
-	log.Printf("   Status: '%s'", payload.Data.Attributes.Status)
-	log.Printf("   SubID: '%s'", payload.Data.ID)
-	log.Printf("   CustomerID: %d", payload.Data.Attributes.CustomerID)
-	log.Printf("   Email: '%s'", payload.Data.Attributes.UserEmail)
+	logger.Infof("🔍 [%s] Subscription creation data:", requestID)
+	logger.Infof("   UserID: '%s' (length: %d)", userID, len(userID))
+	logger.Infof("   Tier: '%s'", tier)
+	logger.Infof("   VariantID: %d", payload.Data.Attributes.VariantID)
+	logger.Infof("   Status: '%s'", payload.Data.Attributes.Status)
+	logger.Infof("   SubID: '%s'", payload.Data.ID)
+	logger.Infof("   CustomerID: %d", payload.Data.Attributes.CustomerID)
+	logger.Infof("   Email: '%s'", payload.Data.Attributes.UserEmail)
 
 	subscription := dynamo.Subscription{
 		UserID:                              userID,
@@ -172,331 +202,461 @@ func processWebhookEvent(payload lsz.WebhookPayload, requestID string) error {
 	}
 
 	// Debug: Verify the subscription object was created correctly
-	log.Printf("🔍 [%s] Created subscription object verification:", requestID)
-	log.Printf("   subscription.UserID: '%s' (length: %d)", subscription.UserID, len(subscription.UserID))
-	log.Printf("   subscription.Tier: '%s'", subscription.Tier)
-	log.Printf("   subscription.Status: '%s'", subscription.Status)
-
-	log.Printf("🏗️ [%s] Subscription object created:", requestID)
-	log.Printf("   UserID: %s", userID)
-	log.Printf("   Tier: %s", tier)
-	log.Printf("   Status: %s", subscription.Status)
-	log.Printf("   CustomerID: %s", subscription.CustomerID)
-	log.Printf("   Email: %s", subscription.Email)
-	log.Printf("   CustomerPortalURL: %s", subscription.CustomerPortalURL)
-	log.Printf("   UpdatePaymentMethodURL: %s", subscription.UpdatePaymentMethodURL)
-	log.Printf("   CustomerPortalUpdateSubscriptionURL: %s", subscription.CustomerPortalUpdateSubscriptionURL)
+	logger.Infof("🔍 [%s] Created subscription object verification:", requestID)
+	logger.Infof("   subscription.UserID: '%s' (length: %d)", subscription.UserID, len(subscription.UserID))
+	logger.Infof("   subscription.Tier: '%s'", subscription.Tier)
+	logger.Infof("   subscription.Status: '%s'", subscription.Status)
+
+	logger.Infof("🏗️ [%s] Subscription object created:", requestID)
+	logger.Infof("   UserID: %s", userID)
+	logger.Infof("   Tier: %s", tier)
+	logger.Infof("   Status: %s", subscription.Status)
+	logger.Infof("   CustomerID: %s", subscription.CustomerID)
+	logger.Infof("   Email: %s", subscription.Email)
+	logger.Infof("   CustomerPortalURL: %s", subscription.CustomerPortalURL)
+	logger.Infof("   UpdatePaymentMethodURL: %s", subscription.UpdatePaymentMethodURL)
+	logger.Infof("   CustomerPortalUpdateSubscriptionURL: %s", subscription.CustomerPortalUpdateSubscriptionURL)
 
 	// Parse dates if available
 	if payload.Data.Attributes.EndsAt != nil {
-		log.Printf("📅 [%s] Parsing ends_at date: %s", requestID, *payload.Data.Attributes.EndsAt)
+		logger.Infof("📅 [%s] Parsing ends_at date: %s", requestID, *payload.Data.Attributes.EndsAt)
 		if endsAt, err := time.Parse(time.RFC3339, *payload.Data.Attributes.EndsAt); err == nil {
 			subscription.ExpiresAt = &endsAt
-			log.Printf("✅ [%s] Expires at: %s", requestID, endsAt.Format(time.RFC3339))
+			logger.Infof("✅ [%s] Expires at: %s", requestID, endsAt.Format(time.RFC3339))
 		} else {
-			log.Printf("⚠️ [%s] Failed to parse ends_at date: %v", requestID, err)
+			logger.Warnf("⚠️ [%s] Failed to parse ends_at date: %v", requestID, err)
 		}
 	} else {
-		log.Printf("📅 [%s] No ends_at date provided", requestID)
+		logger.Infof("📅 [%s] No ends_at date provided", requestID)
 	}
 
+	// Keep the trial end so the UI can show time remaining in the trial
+	if payload.Data.Attributes.TrialEndsAt != nil {
+		logger.Infof("📅 [%s] Parsing trial_ends_at date: %s", requestID, *payload.Data.Attributes.TrialEndsAt)
+		if trialEndsAt, err := time.Parse(time.RFC3339, *payload.Data.Attributes.TrialEndsAt); err == nil {
+			subscription.TrialEndsAt = &trialEndsAt
+			logger.Infof("✅ [%s] Trial ends at: %s", requestID, trialEndsAt.Format(time.RFC3339))
+		} else {
+			logger.Warnf("⚠️ [%s] Failed to parse trial_ends_at date: %v", requestID, err)
+		}
+	}
+
+	// Capture the billing state before the handlers overwrite the row; the
+	// history entry itself is only appended once the handler succeeds, so
+	// unknown events and failed updates leave no phantom transitions
+	oldTier, oldStatus := currentSubscriptionState(subscription.UserID, requestID)
+
 	// Process different event types
-	log.Printf("🔀 [%s] Routing to event handler for: %s", requestID, payload.Meta.EventName)
+	logger.Infof("🔀 [%s] Routing to event handler for: %s", requestID, payload.Meta.EventName)
+	var handleErr error
 	switch payload.Meta.EventName {
 	case "subscription_created":
-		log.Printf("🆕 [%s] Handling subscription_created event", requestID)
-		return handleSubscriptionCreated(subscription, requestID)
+		logger.Infof("🆕 [%s] Handling subscription_created event", requestID)
+		handleErr = handleSubscriptionCreated(subscription, requestID)
 	case "subscription_updated":
-		log.Printf("🔄 [%s] Handling subscription_updated event", requestID)
-		return handleSubscriptionUpdated(subscription, requestID)
+		logger.Infof("🔄 [%s] Handling subscription_updated event", requestID)
+		handleErr = handleSubscriptionUpdated(subscription, requestID)
 	case "subscription_cancelled":
-		log.Printf("❌ [%s] Handling subscription_cancelled event", requestID)
-		return handleSubscriptionCancelled(subscription, requestID)
+		logger.Infof("🚫 [%s] Handling subscription_cancelled event", requestID)
+		handleErr = handleSubscriptionCancelled(subscription, requestID)
 	case "subscription_plan_changed":
-		log.Printf("🔄 [%s] Handling subscription_plan_changed event", requestID)
-		return handleSubscriptionPlanChanged(subscription, requestID)
+		logger.Infof("🔄 [%s] Handling subscription_plan_changed event", requestID)
+		handleErr = handleSubscriptionPlanChanged(subscription, requestID)
 	case "subscription_resumed":
-		log.Printf("▶️ [%s] Handling subscription_resumed event", requestID)
-		return handleSubscriptionResumed(subscription, requestID)
+		logger.Infof("▶️ [%s] Handling subscription_resumed event", requestID)
+		handleErr = handleSubscriptionResumed(subscription, requestID)
 	case "subscription_expired":
-		log.Printf("⏰ [%s] Handling subscription_expired event", requestID)
-		return handleSubscriptionExpired(subscription, requestID)
+		logger.Infof("⏰ [%s] Handling subscription_expired event", requestID)
+		handleErr = handleSubscriptionExpired(subscription, requestID)
 	case "subscription_paused":
-		log.Printf("⏸️ [%s] Handling subscription_paused event", requestID)
-		return handleSubscriptionPaused(subscription, requestID)
+		logger.Infof("⏸️ [%s] Handling subscription_paused event", requestID)
+		handleErr = handleSubscriptionPaused(subscription, requestID)
 	case "subscription_unpaused":
-		log.Printf("▶️ [%s] Handling subscription_unpaused event", requestID)
-		return handleSubscriptionUnpaused(subscription, requestID)
+		logger.Infof("▶️ [%s] Handling subscription_unpaused event", requestID)
+		handleErr = handleSubscriptionUnpaused(subscription, requestID)
+	case "subscription_payment_failed":
+		logger.Infof("💸 [%s] Handling subscription_payment_failed event", requestID)
+		handleErr = handleSubscriptionPaymentFailed(subscription, requestID)
 	default:
 		// Log unknown event type but don't fail
-		log.Printf("⚠️ [%s] Unknown webhook event type: %s", requestID, payload.Meta.EventName)
-		log.Printf("⚠️ [%s] Event will be ignored (not an error)", requestID)
+		logger.Warnf("⚠️ [%s] Unknown webhook event type: %s", requestID, payload.Meta.EventName)
+		logger.Warnf("⚠️ [%s] Event will be ignored (not an error)", requestID)
 		return nil
 	}
+	if handleErr != nil {
+		return handleErr
+	}
+
+	// Record the billing-state transition now that it has actually been
+	// persisted. A history failure never fails the webhook — the
+	// subscription row is the source of truth.
+	recordSubscriptionChange(oldTier, oldStatus, subscription, payload.Meta.EventName, requestID)
+
+	// The gateway caches tiers in the shared Redis; drop this user's entry so
+	// the change takes effect immediately instead of after the cache TTL
+	invalidateGatewayTierCache(userID, requestID)
+
+	return nil
+}
+
+// gatewayTierCachePrefix matches the gateway's tier-cache key namespace;
+// keep the two in sync
+const gatewayTierCachePrefix = "tier_cache:"
+
+// invalidateGatewayTierCache deletes the gateway's cached tier for a user
+// from the shared Redis, best-effort — the cache TTL bounds staleness if
+// Redis is unavailable
+func invalidateGatewayTierCache(userID, requestID string) {
+	client := redis.GetClient()
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := client.Del(ctx, gatewayTierCachePrefix+userID).Err(); err != nil {
+		logger.Warnf("⚠️ [%s] Failed to invalidate gateway tier cache for user %s: %v", requestID, userID, err)
+		return
+	}
+	logger.Infof("🧹 [%s] Invalidated gateway tier cache for user %s", requestID, userID)
+}
+
+// currentSubscriptionState reads the user's stored tier and status so the
+// transition can be recorded after the row is overwritten. Users without a
+// subscription row are on the free tier.
+func currentSubscriptionState(userID, requestID string) (oldTier, oldStatus string) {
+	oldTier = "free"
+	existing, err := dynamo.GetSubscription(context.Background(), userID)
+	if err != nil {
+		logger.Warnf("⚠️ [%s] Could not load existing subscription for history: %v", requestID, err)
+	} else if existing != nil {
+		oldTier = existing.Tier
+		oldStatus = existing.Status
+	}
+	return oldTier, oldStatus
+}
+
+// recordSubscriptionChange appends a history entry when a persisted webhook
+// update changed the user's tier or status compared to the captured state
+func recordSubscriptionChange(oldTier, oldStatus string, sub dynamo.Subscription, event, requestID string) {
+	if oldTier == sub.Tier && oldStatus == sub.Status {
+		logger.Infof("📜 [%s] No tier/status change for user %s, skipping history entry", requestID, sub.UserID)
+		return
+	}
+
+	entry := dynamo.SubscriptionHistoryEntry{
+		UserID:    sub.UserID,
+		Timestamp: time.Now(),
+		Event:     event,
+		OldTier:   oldTier,
+		NewTier:   sub.Tier,
+		OldStatus: oldStatus,
+		NewStatus: sub.Status,
+	}
+	if err := dynamo.AppendSubscriptionHistory(context.Background(), entry); err != nil {
+		logger.Warnf("⚠️ [%s] Failed to append subscription history: %v", requestID, err)
+	}
 }
 
 // handleSubscriptionCreated handles new subscription creation
 func handleSubscriptionCreated(sub dynamo.Subscription, requestID string) error {
-	log.Printf("🆕 [%s] Creating new subscription for user %s", requestID, sub.UserID)
+	logger.Infof("🆕 [%s] Creating new subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Set created time for new subscription
 	sub.CreatedAt = time.Now()
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("🆕 [%s] Saving new subscription to database...", requestID)
+	logger.Infof("🆕 [%s] Saving new subscription to database...", requestID)
 	err := dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save new subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save new subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] New subscription created successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] New subscription created successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionUpdated handles subscription updates
 func handleSubscriptionUpdated(sub dynamo.Subscription, requestID string) error {
-	log.Printf("🔄 [%s] Updating subscription for user %s", requestID, sub.UserID)
+	logger.Infof("🔄 [%s] Updating subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("🔄 [%s] Saving updated subscription to database...", requestID)
+	logger.Infof("🔄 [%s] Saving updated subscription to database...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save updated subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save updated subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription updated successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription updated successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionCancelled handles subscription cancellation
 func handleSubscriptionCancelled(sub dynamo.Subscription, requestID string) error {
-	log.Printf("❌ [%s] Cancelling subscription for user %s", requestID, sub.UserID)
+	logger.Errorf("❌ [%s] Cancelling subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.Status = "cancelled"
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("❌ [%s] Setting status to cancelled and saving...", requestID)
+	logger.Errorf("❌ [%s] Setting status to cancelled and saving...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save cancelled subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save cancelled subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription cancelled successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription cancelled successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionResumed handles subscription resumption
 func handleSubscriptionResumed(sub dynamo.Subscription, requestID string) error {
-	log.Printf("▶️ [%s] Resuming subscription for user %s", requestID, sub.UserID)
+	logger.Infof("▶️ [%s] Resuming subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.Status = "active"
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("▶️ [%s] Setting status to active and saving...", requestID)
+	logger.Infof("▶️ [%s] Setting status to active and saving...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save resumed subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save resumed subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription resumed successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription resumed successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionExpired handles subscription expiration
 func handleSubscriptionExpired(sub dynamo.Subscription, requestID string) error {
-	log.Printf("⏰ [%s] Expiring subscription for user %s", requestID, sub.UserID)
+	logger.Infof("⏰ [%s] Expiring subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.Status = "expired"
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("⏰ [%s] Setting status to expired and saving...", requestID)
+	logger.Infof("⏰ [%s] Setting status to expired and saving...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save expired subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save expired subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription expired successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription expired successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionPaused handles subscription pausing
 func handleSubscriptionPaused(sub dynamo.Subscription, requestID string) error {
-	log.Printf("⏸️ [%s] Pausing subscription for user %s", requestID, sub.UserID)
+	logger.Infof("⏸️ [%s] Pausing subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.Status = "paused"
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("⏸️ [%s] Setting status to paused and saving...", requestID)
+	logger.Infof("⏸️ [%s] Setting status to paused and saving...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save paused subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save paused subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription paused successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription paused successfully for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionUnpaused handles subscription unpausing
 func handleSubscriptionUnpaused(sub dynamo.Subscription, requestID string) error {
-	log.Printf("▶️ [%s] Unpausing subscription for user %s", requestID, sub.UserID)
+	logger.Infof("▶️ [%s] Unpausing subscription for user %s", requestID, sub.UserID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	sub.Status = "active"
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("▶️ [%s] Setting status to active and saving...", requestID)
+	logger.Infof("▶️ [%s] Setting status to active and saving...", requestID)
+	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to save unpaused subscription: %v", requestID, err)
+		return err
+	}
+
+	logger.Infof("✅ [%s] Subscription unpaused successfully for user %s", requestID, sub.UserID)
+	return nil
+}
+
+// handleSubscriptionPaymentFailed handles a failed renewal payment by moving
+// the subscription into past_due, which acts as the grace period marker
+func handleSubscriptionPaymentFailed(sub dynamo.Subscription, requestID string) error {
+	logger.Infof("💸 [%s] Payment failed for subscription user %s", requestID, sub.UserID)
+	ctx := context.Background()
+
+	// Get existing subscription to preserve created_at
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
+	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
+	if err != nil {
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		return err
+	}
+
+	if existing != nil {
+		sub.CreatedAt = existing.CreatedAt
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+	} else {
+		sub.CreatedAt = time.Now()
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+	}
+
+	sub.Status = "past_due"
+	sub.UpdatedAt = time.Now()
+
+	logger.Infof("💸 [%s] Setting status to past_due and saving...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save unpaused subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save past_due subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription unpaused successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription marked past_due for user %s", requestID, sub.UserID)
 	return nil
 }
 
 // handleSubscriptionPlanChanged handles subscription plan changes
 func handleSubscriptionPlanChanged(sub dynamo.Subscription, requestID string) error {
-	log.Printf("🔄 [%s] Plan changed for subscription user %s", requestID, sub.UserID)
-	log.Printf("🔄 [%s] New tier: %s, Variant ID: %d", requestID, sub.Tier, sub.VariantID)
+	logger.Infof("🔄 [%s] Plan changed for subscription user %s", requestID, sub.UserID)
+	logger.Infof("🔄 [%s] New tier: %s, Variant ID: %d", requestID, sub.Tier, sub.VariantID)
 	ctx := context.Background()
 
 	// Get existing subscription to preserve created_at
-	log.Printf("🔍 [%s] Fetching existing subscription data...", requestID)
+	logger.Infof("🔍 [%s] Fetching existing subscription data...", requestID)
 	existing, err := dynamo.GetSubscription(ctx, sub.UserID)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to get existing subscription: %v", requestID, err)
 		return err
 	}
 
 	if existing != nil {
 		sub.CreatedAt = existing.CreatedAt
-		log.Printf("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
-		log.Printf("🔄 [%s] Plan change: %s -> %s", requestID, existing.Tier, sub.Tier)
+		logger.Infof("✅ [%s] Preserved original creation date: %s", requestID, existing.CreatedAt.Format(time.RFC3339))
+		logger.Infof("🔄 [%s] Plan change: %s -> %s", requestID, existing.Tier, sub.Tier)
 	} else {
 		sub.CreatedAt = time.Now()
-		log.Printf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
+		logger.Warnf("⚠️ [%s] No existing subscription found, using current time as creation date", requestID)
 	}
 
 	// Keep the current status (usually "active" when plan changes)
 	sub.UpdatedAt = time.Now()
 
-	log.Printf("🔄 [%s] Saving plan change to database...", requestID)
+	logger.Infof("🔄 [%s] Saving plan change to database...", requestID)
 	err = dynamo.SaveSubscriptionDetailed(ctx, sub)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to save plan changed subscription: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to save plan changed subscription: %v", requestID, err)
 		return err
 	}
 
-	log.Printf("✅ [%s] Subscription plan changed successfully for user %s", requestID, sub.UserID)
+	logger.Infof("✅ [%s] Subscription plan changed successfully for user %s", requestID, sub.UserID)
 	return nil
 }
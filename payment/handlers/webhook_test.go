@@ -0,0 +1,17 @@
+package handlers
+
+import "testing"
+
+func TestGatewayTierCachePrefix(t *testing.T) {
+	// Must match the gateway's tierCachePrefix or invalidation silently
+	// deletes the wrong keys
+	if gatewayTierCachePrefix != "tier_cache:" {
+		t.Errorf("gatewayTierCachePrefix = %q, out of sync with the gateway", gatewayTierCachePrefix)
+	}
+}
+
+func TestInvalidateGatewayTierCacheWithoutRedis(t *testing.T) {
+	// Without an initialized Redis client the invalidation is a no-op; it
+	// must never panic or fail the webhook
+	invalidateGatewayTierCache("user-1", "test-request")
+}
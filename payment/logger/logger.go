@@ -0,0 +1,116 @@
+// Package logger provides leveled logging for the payment service, following
+// the gateway's logger conventions. Verbosity is controlled with the
+// LOG_LEVEL environment variable (DEBUG, INFO, WARN, ERROR; default INFO).
+package logger
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Level represents the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var (
+	levelOnce     sync.Once
+	currentLevel  Level
+	levelsByName  = map[string]Level{"DEBUG": LevelDebug, "INFO": LevelInfo, "WARN": LevelWarn, "ERROR": LevelError}
+	levelPrefixes = map[Level]string{LevelDebug: "[DEBUG] ", LevelInfo: "[INFO] ", LevelWarn: "[WARN] ", LevelError: "[ERROR] "}
+)
+
+func init() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+}
+
+// configuredLevel resolves LOG_LEVEL once; unknown values fall back to INFO
+func configuredLevel() Level {
+	levelOnce.Do(func() {
+		name := strings.ToUpper(strings.TrimSpace(os.Getenv("LOG_LEVEL")))
+		if level, ok := levelsByName[name]; ok {
+			currentLevel = level
+		} else {
+			currentLevel = LevelInfo
+		}
+	})
+	return currentLevel
+}
+
+func logf(level Level, format string, args ...interface{}) {
+	if level < configuredLevel() {
+		return
+	}
+	log.Printf(levelPrefixes[level]+format, args...)
+}
+
+// Debugf logs at DEBUG level
+func Debugf(format string, args ...interface{}) {
+	logf(LevelDebug, format, args...)
+}
+
+// Infof logs at INFO level
+func Infof(format string, args ...interface{}) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf logs at WARN level
+func Warnf(format string, args ...interface{}) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf logs at ERROR level
+func Errorf(format string, args ...interface{}) {
+	logf(LevelError, format, args...)
+}
+
+// Fatalf logs at ERROR level and exits
+func Fatalf(format string, args ...interface{}) {
+	log.Fatalf(levelPrefixes[LevelError]+format, args...)
+}
+
+// sensitiveHeaders lists headers whose values must never reach the logs
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Signature",
+	"X-Admin-Key",
+	"X-Api-Key",
+}
+
+// secretFieldPattern matches known secret- or PII-bearing JSON fields in
+// logged payload previews
+var secretFieldPattern = regexp.MustCompile(`(?i)"(authorization|signature|token|api_key|secret|password|user_email|email|card_brand|card_last_four)"\s*:\s*"[^"]*"`)
+
+// RedactSecretFields masks the values of known secret- and PII-bearing fields
+// in a JSON body preview before it is logged
+func RedactSecretFields(preview string) string {
+	return secretFieldPattern.ReplaceAllStringFunc(preview, func(match string) string {
+		return match[:strings.Index(match, ":")] + `: "[REDACTED]"`
+	})
+}
+
+// RedactHeaders returns a copy of the headers safe for logging, with token-
+// and signature-bearing values replaced by a placeholder
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		redacted[name] = values
+	}
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "[REDACTED]")
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactSecretFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		preview string
+		leaked  string
+	}{
+		{"authorization", `{"authorization": "Bearer abc123"}`, "abc123"},
+		{"signature", `{"signature": "deadbeef"}`, "deadbeef"},
+		{"token", `{"token": "tok_1"}`, "tok_1"},
+		{"api_key", `{"api_key": "sk_live_x"}`, "sk_live_x"},
+		{"secret", `{"secret": "hunter2"}`, "hunter2"},
+		{"password", `{"password": "p4ss"}`, "p4ss"},
+		{"user_email", `{"user_email": "a@b.com"}`, "a@b.com"},
+		{"email", `{"email": "c@d.com"}`, "c@d.com"},
+		{"card_brand", `{"card_brand": "visa"}`, "visa"},
+		{"card_last_four", `{"card_last_four": "4242"}`, "4242"},
+		{"mixed case key", `{"Signature": "cafef00d"}`, "cafef00d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactSecretFields(tt.preview)
+			if strings.Contains(got, tt.leaked) {
+				t.Errorf("RedactSecretFields(%q) = %q, still contains the secret", tt.preview, got)
+			}
+			if !strings.Contains(got, "[REDACTED]") {
+				t.Errorf("RedactSecretFields(%q) = %q, missing placeholder", tt.preview, got)
+			}
+		})
+	}
+
+	// Non-sensitive fields pass through untouched
+	benign := `{"tier": "pro", "status": "active"}`
+	if got := RedactSecretFields(benign); got != benign {
+		t.Errorf("RedactSecretFields(%q) = %q, want unchanged", benign, got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer abc123")
+	headers.Set("Cookie", "session=secret")
+	headers.Set("X-Signature", "deadbeef")
+	headers.Set("X-Admin-Key", "admin-key")
+	headers.Set("X-Api-Key", "api-key")
+	headers.Set("Content-Type", "application/json")
+
+	redacted := RedactHeaders(headers)
+
+	for _, name := range []string{"Authorization", "Cookie", "X-Signature", "X-Admin-Key", "X-Api-Key"} {
+		if got := redacted.Get(name); got != "[REDACTED]" {
+			t.Errorf("%s = %q after redaction, want [REDACTED]", name, got)
+		}
+	}
+	if got := redacted.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q after redaction, want unchanged", got)
+	}
+
+	// The original header map must not be mutated
+	if got := headers.Get("Authorization"); got != "Bearer abc123" {
+		t.Errorf("original Authorization = %q, redaction mutated the input", got)
+	}
+}
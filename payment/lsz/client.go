@@ -6,13 +6,19 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"payment/logger"
 )
 
 // LemonSqueezy API configuration
@@ -22,22 +28,191 @@ const (
 	// Variant IDs for subscription tiers
 	PlusVariantID = 890080 // Plus tier
 	ProVariantID  = 890081 // Pro tier
+
+	// defaultHTTPTimeout bounds each call to the LemonSqueezy API
+	defaultHTTPTimeout = 30 * time.Second
 )
 
+// ErrProviderTimeout distinguishes a slow payment provider from other
+// checkout failures so handlers can surface a clearer message
+var ErrProviderTimeout = errors.New("payment provider timeout")
+
+// httpTimeout returns the per-request timeout for LemonSqueezy calls
+// (LSZ_HTTP_TIMEOUT_MS)
+func httpTimeout() time.Duration {
+	if v := os.Getenv("LSZ_HTTP_TIMEOUT_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return defaultHTTPTimeout
+}
+
+// isTimeoutErr reports whether the error is a timeout rather than another
+// connection-level failure
+func isTimeoutErr(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// LSZError is a LemonSqueezy API error parsed from the JSON:API errors
+// envelope. It carries a cleaned-up message and the HTTP status for the
+// caller; the raw body is kept for logging only.
+type LSZError struct {
+	StatusCode int
+	Details    []string
+	RawBody    string
+}
+
+func (e *LSZError) Error() string {
+	if len(e.Details) > 0 {
+		return fmt.Sprintf("LemonSqueezy error (status %d): %s", e.StatusCode, strings.Join(e.Details, "; "))
+	}
+	return fmt.Sprintf("LemonSqueezy error (status %d)", e.StatusCode)
+}
+
+// jsonAPIErrorEnvelope matches the error body LemonSqueezy returns
+type jsonAPIErrorEnvelope struct {
+	Errors []struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+		Source struct {
+			Pointer string `json:"pointer"`
+		} `json:"source"`
+	} `json:"errors"`
+}
+
+// newLSZError builds an LSZError from a non-2xx response body, falling back
+// to a status-only message when the envelope doesn't parse
+func newLSZError(statusCode int, body []byte) *LSZError {
+	lszErr := &LSZError{StatusCode: statusCode, RawBody: string(body)}
+
+	var envelope jsonAPIErrorEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return lszErr
+	}
+
+	for _, apiError := range envelope.Errors {
+		detail := apiError.Detail
+		if detail == "" {
+			detail = apiError.Title
+		}
+		if detail == "" {
+			continue
+		}
+		if apiError.Source.Pointer != "" {
+			detail = fmt.Sprintf("%s (%s)", detail, apiError.Source.Pointer)
+		}
+		lszErr.Details = append(lszErr.Details, detail)
+	}
+
+	return lszErr
+}
+
+// Client is a shared LemonSqueezy API client with authentication baked in,
+// so each operation doesn't rebuild requests and headers by hand
+type Client struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient builds a client from the environment (LSZ_API_KEY required,
+// LSZ_API_BASE_URL optional)
+func NewClient() (*Client, error) {
+	apiKey := os.Getenv("LSZ_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("LSZ_API_KEY environment variable not set")
+	}
+
+	baseURL := os.Getenv("LSZ_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+
+	return &Client{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: httpTimeout(),
+		},
+	}, nil
+}
+
+// isIdempotentMethod reports whether a request with the given method can be
+// safely re-sent: a retried POST /checkouts could create a duplicate checkout
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// do sends an authenticated JSON:API request and returns the status code and
+// response body. Connection-level failures are retried once; timeouts are
+// not (the caller has already waited a full timeout) and HTTP error statuses
+// are returned for the caller to interpret.
+func (c *Client) do(requestID, method, path string, body []byte) (int, []byte, error) {
+	url := c.baseURL + path
+	logger.Infof("🌐 [%s] %s %s", requestID, method, url)
+
+	// Each attempt needs its own request: the body reader of a sent request
+	// is consumed and cannot be reused
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/vnd.api+json")
+		req.Header.Set("Content-Type", "application/vnd.api+json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil && !isTimeoutErr(err) && isIdempotentMethod(method) {
+		logger.Warnf("⚠️ [%s] HTTP request failed, retrying once: %v", requestID, err)
+		if req, err = newRequest(); err != nil {
+			return 0, nil, err
+		}
+		resp, err = c.httpClient.Do(req)
+	}
+	if err != nil {
+		if isTimeoutErr(err) {
+			logger.Errorf("❌ [%s] LemonSqueezy did not respond within %v", requestID, httpTimeout())
+			return 0, nil, fmt.Errorf("%w: no response within %v", ErrProviderTimeout, httpTimeout())
+		}
+		logger.Errorf("❌ [%s] HTTP request failed: %v", requestID, err)
+		return 0, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	logger.Infof("📥 [%s] Response received - Status: %d %s", requestID, resp.StatusCode, resp.Status)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp.StatusCode, respBody, nil
+}
+
 // GetTierVariantID returns the variant ID for a given tier
 func GetTierVariantID(tier string) int {
 	requestID := fmt.Sprintf("variant-%d", time.Now().UnixNano())
-	log.Printf("🍋 [%s] Getting variant ID for tier: %s", requestID, tier)
+	logger.Infof("🍋 [%s] Getting variant ID for tier: %s", requestID, tier)
 
 	switch strings.ToLower(tier) {
 	case "plus":
-		log.Printf("✅ [%s] Mapped tier '%s' to variant ID: %d", requestID, tier, PlusVariantID)
+		logger.Infof("✅ [%s] Mapped tier '%s' to variant ID: %d", requestID, tier, PlusVariantID)
 		return PlusVariantID
 	case "pro":
-		log.Printf("✅ [%s] Mapped tier '%s' to variant ID: %d", requestID, tier, ProVariantID)
+		logger.Infof("✅ [%s] Mapped tier '%s' to variant ID: %d", requestID, tier, ProVariantID)
 		return ProVariantID
 	default:
-		log.Printf("❌ [%s] Unknown tier: %s", requestID, tier)
+		logger.Errorf("❌ [%s] Unknown tier: %s", requestID, tier)
 		return 0
 	}
 }
@@ -45,17 +220,17 @@ func GetTierVariantID(tier string) int {
 // GetVariantTier returns the tier for a given variant ID
 func GetVariantTier(variantID int) string {
 	requestID := fmt.Sprintf("tier-%d", time.Now().UnixNano())
-	log.Printf("🍋 [%s] Getting tier for variant ID: %d", requestID, variantID)
+	logger.Infof("🍋 [%s] Getting tier for variant ID: %d", requestID, variantID)
 
 	switch variantID {
 	case PlusVariantID:
-		log.Printf("✅ [%s] Mapped variant ID %d to tier: plus", requestID, variantID)
+		logger.Infof("✅ [%s] Mapped variant ID %d to tier: plus", requestID, variantID)
 		return "plus"
 	case ProVariantID:
-		log.Printf("✅ [%s] Mapped variant ID %d to tier: pro", requestID, variantID)
+		logger.Infof("✅ [%s] Mapped variant ID %d to tier: pro", requestID, variantID)
 		return "pro"
 	default:
-		log.Printf("❌ [%s] Unknown variant ID: %d, defaulting to free", requestID, variantID)
+		logger.Errorf("❌ [%s] Unknown variant ID: %d, defaulting to free", requestID, variantID)
 		return "free"
 	}
 }
@@ -63,18 +238,18 @@ func GetVariantTier(variantID int) string {
 // IsValidSubscriptionStatus checks if a subscription status is considered active
 func IsValidSubscriptionStatus(status string) bool {
 	requestID := fmt.Sprintf("status-%d", time.Now().UnixNano())
-	log.Printf("🔍 [%s] Checking if status is valid: %s", requestID, status)
+	logger.Infof("🔍 [%s] Checking if status is valid: %s", requestID, status)
 
-	validStatuses := []string{"active", "trialing", "past_due"}
+	validStatuses := []string{"active", "trialing", "on_trial", "past_due"}
 
 	for _, validStatus := range validStatuses {
 		if status == validStatus {
-			log.Printf("✅ [%s] Status '%s' is valid", requestID, status)
+			logger.Infof("✅ [%s] Status '%s' is valid", requestID, status)
 			return true
 		}
 	}
 
-	log.Printf("❌ [%s] Status '%s' is not valid (not in: %v)", requestID, status, validStatuses)
+	logger.Errorf("❌ [%s] Status '%s' is not valid (not in: %v)", requestID, status, validStatuses)
 	return false
 }
 
@@ -163,11 +338,27 @@ type CheckoutResponseData struct {
 }
 
 type CheckoutResponseAttributes struct {
-	StoreID   int    `json:"store_id"`
-	VariantID int    `json:"variant_id"`
-	URL       string `json:"url"`
-	TestMode  bool   `json:"test_mode"`
-	ExpiresAt string `json:"expires_at"`
+	StoreID   int                  `json:"store_id"`
+	VariantID int                  `json:"variant_id"`
+	URL       string               `json:"url"`
+	TestMode  bool                 `json:"test_mode"`
+	ExpiresAt string               `json:"expires_at"`
+	Preview   *CheckoutPreviewInfo `json:"preview,omitempty"`
+}
+
+// CheckoutPreviewInfo carries the pricing LemonSqueezy computes when a
+// checkout is created with Preview set; amounts are in cents
+type CheckoutPreviewInfo struct {
+	Currency               string  `json:"currency"`
+	CurrencyRate           float64 `json:"currency_rate"`
+	Subtotal               int     `json:"subtotal"`
+	DiscountTotal          int     `json:"discount_total"`
+	Tax                    int     `json:"tax"`
+	Total                  int     `json:"total"`
+	SubtotalFormatted      string  `json:"subtotal_formatted"`
+	DiscountTotalFormatted string  `json:"discount_total_formatted"`
+	TaxFormatted           string  `json:"tax_formatted"`
+	TotalFormatted         string  `json:"total_formatted"`
 }
 
 type CheckoutResponseMeta struct {
@@ -192,16 +383,17 @@ type WebhookData struct {
 }
 
 type WebhookDataAttributes struct {
-	StoreID    int                    `json:"store_id"`
-	CustomerID int                    `json:"customer_id"`
-	VariantID  int                    `json:"variant_id"`
-	UserEmail  string                 `json:"user_email"`
-	Status     string                 `json:"status"`
-	EndsAt     *string                `json:"ends_at"`
-	CreatedAt  string                 `json:"created_at"`
-	UpdatedAt  string                 `json:"updated_at"`
-	CustomData map[string]interface{} `json:"custom_data"`
-	URLs       WebhookURLs            `json:"urls"`
+	StoreID     int                    `json:"store_id"`
+	CustomerID  int                    `json:"customer_id"`
+	VariantID   int                    `json:"variant_id"`
+	UserEmail   string                 `json:"user_email"`
+	Status      string                 `json:"status"`
+	EndsAt      *string                `json:"ends_at"`
+	TrialEndsAt *string                `json:"trial_ends_at"`
+	CreatedAt   string                 `json:"created_at"`
+	UpdatedAt   string                 `json:"updated_at"`
+	CustomData  map[string]interface{} `json:"custom_data"`
+	URLs        WebhookURLs            `json:"urls"`
 }
 
 type WebhookURLs struct {
@@ -210,55 +402,151 @@ type WebhookURLs struct {
 	CustomerPortalUpdateSubscription string `json:"customer_portal_update_subscription"`
 }
 
-// CreateCheckout creates a checkout session with LemonSqueezy
-func CreateCheckout(variantID int, userID, email string) (*CheckoutResponse, error) {
+// discountCodePattern is deliberately conservative: LemonSqueezy codes are
+// short alphanumeric strings, so anything else is rejected before it reaches
+// the API
+var discountCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateDiscountCode checks a discount code's format before it is sent to
+// LemonSqueezy; empty codes are fine (no discount)
+func ValidateDiscountCode(code string) error {
+	if code == "" {
+		return nil
+	}
+	if !discountCodePattern.MatchString(code) {
+		return fmt.Errorf("invalid discount code format")
+	}
+	return nil
+}
+
+// IsDiscountError reports whether a LemonSqueezy error complains about the
+// discount code, so callers can surface it distinctly from other failures
+func IsDiscountError(err error) bool {
+	var lszErr *LSZError
+	if !errors.As(err, &lszErr) {
+		return false
+	}
+	for _, detail := range lszErr.Details {
+		if strings.Contains(strings.ToLower(detail), "discount") {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultAllowedRedirectHosts are the hosts checkout redirect/receipt URLs
+// may point at when the caller does not configure LSZ_ALLOWED_REDIRECT_HOSTS
+const defaultAllowedRedirectHosts = "mayura.rocks,localhost:3000"
+
+// allowedRedirectHosts returns the host allow-list for per-request redirect
+// URL overrides (LSZ_ALLOWED_REDIRECT_HOSTS, comma-separated)
+func allowedRedirectHosts() []string {
+	raw := os.Getenv("LSZ_ALLOWED_REDIRECT_HOSTS")
+	if raw == "" {
+		raw = defaultAllowedRedirectHosts
+	}
+	var hosts []string
+	for _, h := range strings.Split(raw, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// ValidateRedirectURL checks that a per-request redirect or receipt URL
+// points at an allowed host, so checkouts can't be turned into open
+// redirects; empty means "use the env default" and is always fine
+func ValidateRedirectURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid redirect URL")
+	}
+	for _, host := range allowedRedirectHosts() {
+		if strings.EqualFold(u.Host, host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("redirect URL host %q is not allowed", u.Host)
+}
+
+// CreateCheckout creates a checkout session with LemonSqueezy. discountCode
+// pre-fills the promo code field; redirectURL and receiptURL override the
+// env defaults when set (callers must validate them with ValidateRedirectURL)
+func CreateCheckout(variantID int, userID, email, discountCode, redirectURL, receiptURL string) (*CheckoutResponse, error) {
+	return createCheckout(variantID, userID, email, discountCode, redirectURL, receiptURL, false)
+}
+
+// CreateCheckoutPreview runs the same checkout call in preview mode:
+// LemonSqueezy computes the price, tax, and discount for the variant without
+// creating a live checkout, so the frontend can show the charge up front
+func CreateCheckoutPreview(variantID int, userID, email string) (*CheckoutResponse, error) {
+	return createCheckout(variantID, userID, email, "", "", "", true)
+}
+
+func createCheckout(variantID int, userID, email, discountCode, overrideRedirectURL, overrideReceiptURL string, preview bool) (*CheckoutResponse, error) {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("checkout-%d", startTime.UnixNano())
 
-	log.Printf("🍋 [%s] Creating LemonSqueezy checkout session", requestID)
-	log.Printf("🍋 [%s] Variant ID: %d", requestID, variantID)
-	log.Printf("🍋 [%s] User ID: %s", requestID, userID)
-	log.Printf("🍋 [%s] Email: %s", requestID, email)
+	logger.Infof("🍋 [%s] Creating LemonSqueezy checkout session", requestID)
+	logger.Infof("🍋 [%s] Variant ID: %d", requestID, variantID)
+	logger.Infof("🍋 [%s] User ID: %s", requestID, userID)
+	logger.Infof("🍋 [%s] Email: %s", requestID, email)
 
-	// Get API key
-	apiKey := os.Getenv("LSZ_API_KEY")
-	if apiKey == "" {
-		log.Printf("❌ [%s] LSZ_API_KEY environment variable not set", requestID)
-		return nil, fmt.Errorf("LSZ_API_KEY environment variable not set")
+	// Build the shared API client (loads and validates LSZ_API_KEY)
+	client, err := NewClient()
+	if err != nil {
+		logger.Errorf("❌ [%s] %v", requestID, err)
+		return nil, err
 	}
-	log.Printf("🔐 [%s] API key loaded successfully", requestID)
+	logger.Infof("🔐 [%s] API key loaded successfully", requestID)
 
 	// Get store ID
 	storeID := os.Getenv("LSZ_STORE_ID")
 	if storeID == "" {
-		log.Printf("❌ [%s] LSZ_STORE_ID environment variable not set", requestID)
+		logger.Errorf("❌ [%s] LSZ_STORE_ID environment variable not set", requestID)
 		return nil, fmt.Errorf("LSZ_STORE_ID environment variable not set")
 	}
-	log.Printf("🏪 [%s] Store ID: %s", requestID, storeID)
+	logger.Infof("🏪 [%s] Store ID: %s", requestID, storeID)
 
 	// Get environment configuration
 	redirectURL := os.Getenv("LSZ_REDIRECT_URL")
 	if redirectURL == "" {
 		redirectURL = "https://mayura.rocks/dashboard"
-		log.Printf("⚠️ [%s] LSZ_REDIRECT_URL not set, using default: %s", requestID, redirectURL)
+		logger.Warnf("⚠️ [%s] LSZ_REDIRECT_URL not set, using default: %s", requestID, redirectURL)
 	} else {
-		log.Printf("🔗 [%s] Redirect URL: %s", requestID, redirectURL)
+		logger.Infof("🔗 [%s] Redirect URL: %s", requestID, redirectURL)
 	}
 
 	receiptURL := os.Getenv("LSZ_RECEIPT_URL")
 	if receiptURL == "" {
 		receiptURL = "https://mayura.rocks/receipt"
-		log.Printf("⚠️ [%s] LSZ_RECEIPT_URL not set, using default: %s", requestID, receiptURL)
+		logger.Warnf("⚠️ [%s] LSZ_RECEIPT_URL not set, using default: %s", requestID, receiptURL)
 	} else {
-		log.Printf("🧾 [%s] Receipt URL: %s", requestID, receiptURL)
+		logger.Infof("🧾 [%s] Receipt URL: %s", requestID, receiptURL)
+	}
+
+	// Per-request overrides (already validated against the host allow-list)
+	// win over the env defaults, so web and mobile checkouts can each land
+	// back on their own surface
+	if overrideRedirectURL != "" {
+		redirectURL = overrideRedirectURL
+		logger.Infof("🔗 [%s] Redirect URL overridden per request: %s", requestID, redirectURL)
+	}
+	if overrideReceiptURL != "" {
+		receiptURL = overrideReceiptURL
+		logger.Infof("🧾 [%s] Receipt URL overridden per request: %s", requestID, receiptURL)
 	}
 
 	// Check if we're in test mode
 	testMode := os.Getenv("LSZ_TEST_MODE") == "true" || os.Getenv("DEVELOPMENT") == "true"
-	log.Printf("🧪 [%s] Test mode: %v", requestID, testMode)
+	logger.Infof("🧪 [%s] Test mode: %v", requestID, testMode)
 
 	// Create the checkout request payload matching LemonSqueezy API exactly
-	log.Printf("🏗️ [%s] Building checkout request payload...", requestID)
+	logger.Infof("🏗️ [%s] Building checkout request payload...", requestID)
 	checkout := CheckoutRequest{
 		Data: CheckoutData{
 			Type: "checkouts",
@@ -280,12 +568,13 @@ func CreateCheckout(variantID int, userID, email string) (*CheckoutResponse, err
 					ButtonColor:         "#7047EB",
 				},
 				CheckoutData: CheckoutCustomData{
-					Email: email,
+					Email:        email,
+					DiscountCode: discountCode,
 					Custom: map[string]interface{}{
 						"user_id": userID,
 					},
 				},
-				Preview:  false,
+				Preview:  preview,
 				TestMode: testMode,
 			},
 			Relationships: CheckoutRelationships{
@@ -306,67 +595,30 @@ func CreateCheckout(variantID int, userID, email string) (*CheckoutResponse, err
 	}
 
 	// Marshal the request
-	log.Printf("🔄 [%s] Marshaling checkout request...", requestID)
+	logger.Infof("🔄 [%s] Marshaling checkout request...", requestID)
 	reqBody, err := json.Marshal(checkout)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to marshal checkout request: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to marshal checkout request: %v", requestID, err)
 		return nil, fmt.Errorf("failed to marshal checkout request: %w", err)
 	}
 
-	log.Printf("✅ [%s] Request payload created (%d bytes)", requestID, len(reqBody))
+	logger.Infof("✅ [%s] Request payload created (%d bytes)", requestID, len(reqBody))
 	// Log first 500 characters of request for debugging
 	reqPreview := string(reqBody)
 	if len(reqPreview) > 500 {
 		reqPreview = reqPreview[:500] + "..."
 	}
-	log.Printf("🔍 [%s] Request preview: %s", requestID, reqPreview)
+	logger.Infof("🔍 [%s] Request preview: %s", requestID, reqPreview)
 
-	// Create HTTP request
-	url := fmt.Sprintf("%s/checkouts", BaseURL)
-	log.Printf("🌐 [%s] Creating HTTP POST request to: %s", requestID, url)
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		log.Printf("❌ [%s] Failed to create HTTP request: %v", requestID, err)
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Accept", "application/vnd.api+json")
-	req.Header.Set("Content-Type", "application/vnd.api+json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-
-	log.Printf("🔧 [%s] HTTP headers set:", requestID)
-	log.Printf("   Accept: application/vnd.api+json")
-	log.Printf("   Content-Type: application/vnd.api+json")
-	log.Printf("   Authorization: Bearer [REDACTED]")
-
-	// Make the request
-	log.Printf("📤 [%s] Sending HTTP request to LemonSqueezy...", requestID)
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("❌ [%s] HTTP request failed: %v", requestID, err)
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	log.Printf("📥 [%s] Response received - Status: %d %s", requestID, resp.StatusCode, resp.Status)
-	log.Printf("📥 [%s] Response headers: %+v", requestID, resp.Header)
-
-	// Read response body
-	log.Printf("📖 [%s] Reading response body...", requestID)
-	respBody, err := io.ReadAll(resp.Body)
+	// Make the request through the shared client
+	logger.Infof("📤 [%s] Sending HTTP request to LemonSqueezy...", requestID)
+	statusCode, respBody, err := client.do(requestID, "POST", "/checkouts", reqBody)
 	if err != nil {
-		log.Printf("❌ [%s] Failed to read response body: %v", requestID, err)
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	respBodySize := len(respBody)
-	log.Printf("📖 [%s] Response body read (%d bytes)", requestID, respBodySize)
+	logger.Infof("📖 [%s] Response body read (%d bytes)", requestID, respBodySize)
 
 	// Log response preview for debugging
 	if respBodySize > 0 {
@@ -374,33 +626,35 @@ func CreateCheckout(variantID int, userID, email string) (*CheckoutResponse, err
 		if len(respPreview) > 500 {
 			respPreview = respPreview[:500] + "..."
 		}
-		log.Printf("🔍 [%s] Response preview: %s", requestID, respPreview)
+		logger.Infof("🔍 [%s] Response preview: %s", requestID, respPreview)
 	}
 
-	// Check for errors
-	if resp.StatusCode != http.StatusCreated {
-		log.Printf("❌ [%s] API returned error status: %d", requestID, resp.StatusCode)
-		log.Printf("❌ [%s] Full error response: %s", requestID, string(respBody))
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	// Check for errors; the raw body goes to the logs, the caller gets the
+	// parsed JSON:API details
+	if statusCode != http.StatusCreated {
+		lszErr := newLSZError(statusCode, respBody)
+		logger.Errorf("❌ [%s] API returned error status: %d", requestID, statusCode)
+		logger.Errorf("❌ [%s] Full error response: %s", requestID, lszErr.RawBody)
+		return nil, lszErr
 	}
 
 	// Parse response
-	log.Printf("🔄 [%s] Parsing checkout response...", requestID)
+	logger.Infof("🔄 [%s] Parsing checkout response...", requestID)
 	var checkoutResp CheckoutResponse
 	if err := json.Unmarshal(respBody, &checkoutResp); err != nil {
-		log.Printf("❌ [%s] Failed to parse response JSON: %v", requestID, err)
+		logger.Errorf("❌ [%s] Failed to parse response JSON: %v", requestID, err)
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [%s] Checkout created successfully in %v", requestID, duration)
-	log.Printf("✅ [%s] Checkout details:", requestID)
-	log.Printf("   ID: %s", checkoutResp.Data.ID)
-	log.Printf("   URL: %s", checkoutResp.Data.Attributes.URL)
-	log.Printf("   Store ID: %d", checkoutResp.Data.Attributes.StoreID)
-	log.Printf("   Variant ID: %d", checkoutResp.Data.Attributes.VariantID)
-	log.Printf("   Test Mode: %v", checkoutResp.Data.Attributes.TestMode)
-	log.Printf("   Expires At: %s", checkoutResp.Data.Attributes.ExpiresAt)
+	logger.Infof("✅ [%s] Checkout created successfully in %v", requestID, duration)
+	logger.Infof("✅ [%s] Checkout details:", requestID)
+	logger.Infof("   ID: %s", checkoutResp.Data.ID)
+	logger.Infof("   URL: %s", checkoutResp.Data.Attributes.URL)
+	logger.Infof("   Store ID: %d", checkoutResp.Data.Attributes.StoreID)
+	logger.Infof("   Variant ID: %d", checkoutResp.Data.Attributes.VariantID)
+	logger.Infof("   Test Mode: %v", checkoutResp.Data.Attributes.TestMode)
+	logger.Infof("   Expires At: %s", checkoutResp.Data.Attributes.ExpiresAt)
 
 	return &checkoutResp, nil
 }
@@ -415,9 +669,9 @@ func VerifyWebhookSignature(payload []byte, signature string) bool {
 	startTime := time.Now()
 	requestID := fmt.Sprintf("verify-%d", startTime.UnixNano())
 
-	log.Printf("🔐 [%s] Verifying webhook signature", requestID)
-	log.Printf("🔐 [%s] Payload size: %d bytes", requestID, len(payload))
-	log.Printf("🔐 [%s] Signature: %s", requestID,
+	logger.Infof("🔐 [%s] Verifying webhook signature", requestID)
+	logger.Infof("🔐 [%s] Payload size: %d bytes", requestID, len(payload))
+	logger.Infof("🔐 [%s] Signature: %s", requestID,
 		func() string {
 			if signature == "" {
 				return "❌ Empty"
@@ -428,42 +682,43 @@ func VerifyWebhookSignature(payload []byte, signature string) bool {
 	// Get webhook secret
 	secret := os.Getenv("LSZ_WEBHOOK_SECRET")
 	if secret == "" {
-		log.Printf("⚠️ [%s] LSZ_WEBHOOK_SECRET not set, skipping signature verification", requestID)
+		logger.Warnf("⚠️ [%s] LSZ_WEBHOOK_SECRET not set, skipping signature verification", requestID)
 		// In development, we might not have a secret set
 		if os.Getenv("DEVELOPMENT") == "true" {
-			log.Printf("🔧 [%s] Development mode - allowing request without signature", requestID)
+			logger.Infof("🔧 [%s] Development mode - allowing request without signature", requestID)
 			return true
 		}
-		log.Printf("❌ [%s] Webhook secret not configured in production", requestID)
+		logger.Errorf("❌ [%s] Webhook secret not configured in production", requestID)
 		return false
 	}
 
-	log.Printf("🔑 [%s] Webhook secret loaded successfully", requestID)
+	logger.Infof("🔑 [%s] Webhook secret loaded successfully", requestID)
 
 	// If no signature provided, reject
 	if signature == "" {
-		log.Printf("❌ [%s] No signature provided in request", requestID)
+		logger.Errorf("❌ [%s] No signature provided in request", requestID)
 		return false
 	}
 
 	// Compute expected signature
-	log.Printf("🔄 [%s] Computing expected HMAC signature...", requestID)
+	logger.Infof("🔄 [%s] Computing expected HMAC signature...", requestID)
 	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
 	expectedSignature := hex.EncodeToString(mac.Sum(nil))
 
-	log.Printf("🔍 [%s] Expected signature: %s", requestID, expectedSignature)
-	log.Printf("🔍 [%s] Received signature: %s", requestID, signature)
+	// Never log the signature values themselves: the expected one is an HMAC
+	// oracle and the received one may be a near-miss forgery attempt
+	logger.Infof("🔍 [%s] Comparing signatures (expected %d chars, received %d chars)", requestID, len(expectedSignature), len(signature))
 
 	// Compare signatures
 	isValid := hmac.Equal([]byte(expectedSignature), []byte(signature))
 
 	duration := time.Since(startTime)
 	if isValid {
-		log.Printf("✅ [%s] Webhook signature verification successful in %v", requestID, duration)
+		logger.Infof("✅ [%s] Webhook signature verification successful in %v", requestID, duration)
 	} else {
-		log.Printf("❌ [%s] Webhook signature verification failed in %v", requestID, duration)
-		log.Printf("❌ [%s] Signatures do not match!", requestID)
+		logger.Errorf("❌ [%s] Webhook signature verification failed in %v", requestID, duration)
+		logger.Errorf("❌ [%s] Signatures do not match!", requestID)
 	}
 
 	return isValid
@@ -472,7 +727,7 @@ func VerifyWebhookSignature(payload []byte, signature string) bool {
 // GetStoreID returns the store ID from environment
 func GetStoreID() string {
 	storeID := os.Getenv("LSZ_STORE_ID")
-	log.Printf("🏪 Getting store ID from environment: %s",
+	logger.Infof("🏪 Getting store ID from environment: %s",
 		func() string {
 			if storeID == "" {
 				return "❌ Not set"
@@ -484,13 +739,13 @@ func GetStoreID() string {
 
 // LogAPIConfiguration logs the current API configuration (safely)
 func LogAPIConfiguration() {
-	log.Println("🍋 LemonSqueezy API Configuration:")
-	log.Printf("  Base URL: %s", BaseURL)
-	log.Printf("  Plus Variant ID: %d", PlusVariantID)
-	log.Printf("  Pro Variant ID: %d", ProVariantID)
+	logger.Infof("🍋 LemonSqueezy API Configuration:")
+	logger.Infof("  Base URL: %s", BaseURL)
+	logger.Infof("  Plus Variant ID: %d", PlusVariantID)
+	logger.Infof("  Pro Variant ID: %d", ProVariantID)
 
 	// Log presence of environment variables without exposing values
-	log.Printf("  LSZ_API_KEY: %s",
+	logger.Infof("  LSZ_API_KEY: %s",
 		func() string {
 			if os.Getenv("LSZ_API_KEY") != "" {
 				return "✅ Set"
@@ -498,7 +753,7 @@ func LogAPIConfiguration() {
 			return "❌ Not set"
 		}())
 
-	log.Printf("  LSZ_WEBHOOK_SECRET: %s",
+	logger.Infof("  LSZ_WEBHOOK_SECRET: %s",
 		func() string {
 			if os.Getenv("LSZ_WEBHOOK_SECRET") != "" {
 				return "✅ Set"
@@ -506,7 +761,7 @@ func LogAPIConfiguration() {
 			return "❌ Not set"
 		}())
 
-	log.Printf("  LSZ_STORE_ID: %s",
+	logger.Infof("  LSZ_STORE_ID: %s",
 		func() string {
 			if os.Getenv("LSZ_STORE_ID") != "" {
 				return "✅ Set"
@@ -514,7 +769,7 @@ func LogAPIConfiguration() {
 			return "❌ Not set"
 		}())
 
-	log.Printf("  LSZ_REDIRECT_URL: %s",
+	logger.Infof("  LSZ_REDIRECT_URL: %s",
 		func() string {
 			if url := os.Getenv("LSZ_REDIRECT_URL"); url != "" {
 				return fmt.Sprintf("✅ %s", url)
@@ -522,7 +777,7 @@ func LogAPIConfiguration() {
 			return "⚠️ Using default"
 		}())
 
-	log.Printf("  LSZ_RECEIPT_URL: %s",
+	logger.Infof("  LSZ_RECEIPT_URL: %s",
 		func() string {
 			if url := os.Getenv("LSZ_RECEIPT_URL"); url != "" {
 				return fmt.Sprintf("✅ %s", url)
@@ -530,5 +785,5 @@ func LogAPIConfiguration() {
 			return "⚠️ Using default"
 		}())
 
-	log.Printf("  Test Mode: %v", os.Getenv("LSZ_TEST_MODE") == "true" || os.Getenv("DEVELOPMENT") == "true")
+	logger.Infof("  Test Mode: %v", os.Getenv("LSZ_TEST_MODE") == "true" || os.Getenv("DEVELOPMENT") == "true")
 }
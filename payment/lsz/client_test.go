@@ -0,0 +1,165 @@
+package lsz
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPTimeoutParsing(t *testing.T) {
+	t.Setenv("LSZ_HTTP_TIMEOUT_MS", "")
+	if got := httpTimeout(); got != defaultHTTPTimeout {
+		t.Errorf("default timeout = %v, want %v", got, defaultHTTPTimeout)
+	}
+
+	t.Setenv("LSZ_HTTP_TIMEOUT_MS", "5000")
+	if got := httpTimeout(); got != 5*time.Second {
+		t.Errorf("timeout with override = %v, want 5s", got)
+	}
+
+	t.Setenv("LSZ_HTTP_TIMEOUT_MS", "not-a-number")
+	if got := httpTimeout(); got != defaultHTTPTimeout {
+		t.Errorf("timeout with invalid override = %v, want default %v", got, defaultHTTPTimeout)
+	}
+}
+
+func TestDoTimeoutReturnsProviderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-key",
+		baseURL:    server.URL,
+		httpClient: &http.Client{Timeout: 20 * time.Millisecond},
+	}
+
+	_, _, err := client.do("test", http.MethodGet, "/v1/subscriptions/1", nil)
+	if !errors.Is(err, ErrProviderTimeout) {
+		t.Errorf("do on a slow server returned %v, want ErrProviderTimeout", err)
+	}
+}
+
+func TestDoRetriesOnlyIdempotentRequests(t *testing.T) {
+	if !isIdempotentMethod(http.MethodGet) || !isIdempotentMethod(http.MethodHead) {
+		t.Error("GET and HEAD should be idempotent")
+	}
+	if isIdempotentMethod(http.MethodPost) || isIdempotentMethod(http.MethodPatch) || isIdempotentMethod(http.MethodDelete) {
+		t.Error("POST, PATCH and DELETE must not be retried")
+	}
+
+	// A server that kills every connection forces a transport error on each
+	// attempt, so the attempt counter reveals whether a retry happened
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("response writer does not support hijacking")
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			t.Fatalf("hijack: %v", err)
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := &Client{
+		apiKey:     "test-key",
+		baseURL:    server.URL,
+		httpClient: &http.Client{Timeout: time.Second},
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	if _, _, err := client.do("test", http.MethodGet, "/v1/subscriptions/1", nil); err == nil {
+		t.Fatal("do against a connection-killing server should fail")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("GET attempts = %d, want 2 (one retry)", got)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	if _, _, err := client.do("test", http.MethodPost, "/v1/checkouts", []byte(`{}`)); err == nil {
+		t.Fatal("do against a connection-killing server should fail")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("POST attempts = %d, want 1 (never retried)", got)
+	}
+}
+
+func TestNewLSZError(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		body        string
+		wantDetails []string
+		wantMessage string
+	}{
+		{
+			name:        "detail with source pointer",
+			statusCode:  422,
+			body:        `{"errors":[{"title":"Unprocessable Entity","detail":"The variant id field is required.","source":{"pointer":"/data/relationships/variant"}}]}`,
+			wantDetails: []string{"The variant id field is required. (/data/relationships/variant)"},
+			wantMessage: "LemonSqueezy error (status 422): The variant id field is required. (/data/relationships/variant)",
+		},
+		{
+			name:        "title fallback when detail missing",
+			statusCode:  401,
+			body:        `{"errors":[{"title":"Unauthorized"}]}`,
+			wantDetails: []string{"Unauthorized"},
+			wantMessage: "LemonSqueezy error (status 401): Unauthorized",
+		},
+		{
+			name:       "multiple errors joined",
+			statusCode: 422,
+			body:       `{"errors":[{"detail":"first problem"},{"detail":"second problem"}]}`,
+			wantDetails: []string{
+				"first problem",
+				"second problem",
+			},
+			wantMessage: "LemonSqueezy error (status 422): first problem; second problem",
+		},
+		{
+			name:        "empty error entries skipped",
+			statusCode:  400,
+			body:        `{"errors":[{}]}`,
+			wantDetails: nil,
+			wantMessage: "LemonSqueezy error (status 400)",
+		},
+		{
+			name:        "unparseable body keeps status only",
+			statusCode:  502,
+			body:        `<html>Bad Gateway</html>`,
+			wantDetails: nil,
+			wantMessage: "LemonSqueezy error (status 502)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newLSZError(tt.statusCode, []byte(tt.body))
+			if err.StatusCode != tt.statusCode {
+				t.Errorf("StatusCode = %d, want %d", err.StatusCode, tt.statusCode)
+			}
+			if err.RawBody != tt.body {
+				t.Errorf("RawBody = %q, want the original body", err.RawBody)
+			}
+			if len(err.Details) != len(tt.wantDetails) {
+				t.Fatalf("Details = %v, want %v", err.Details, tt.wantDetails)
+			}
+			for i := range tt.wantDetails {
+				if err.Details[i] != tt.wantDetails[i] {
+					t.Errorf("Details[%d] = %q, want %q", i, err.Details[i], tt.wantDetails[i])
+				}
+			}
+			if got := err.Error(); got != tt.wantMessage {
+				t.Errorf("Error() = %q, want %q", got, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"crypto/subtle"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
 	"time"
 
 	"payment/dynamo"
 	"payment/firebase"
 	"payment/handlers"
+	"payment/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,51 +19,64 @@ import (
 func getEnvWithDefault(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
-		log.Printf("📝 Environment variable %s not set, using default: %s", key, defaultValue)
+		logger.Infof("📝 Environment variable %s not set, using default: %s", key, defaultValue)
 		return defaultValue
 	}
-	log.Printf("📝 Environment variable %s = %s", key, value)
+	logger.Infof("📝 Environment variable %s = %s", key, value)
 	return value
 }
 
 // logEnvironmentConfig logs all relevant environment variables (safely)
 func logEnvironmentConfig() {
-	log.Println("🔧 Payment Service Configuration:")
-	log.Printf("  PORT: %s", getEnvWithDefault("PORT", "8081"))
-	log.Printf("  GIN_MODE: %s", getEnvWithDefault("GIN_MODE", "release"))
-	log.Printf("  DEVELOPMENT: %s", getEnvWithDefault("DEVELOPMENT", "false"))
-	log.Printf("  DYNAMO_TABLE: %s", getEnvWithDefault("DYNAMO_TABLE", "subscriptions"))
-	log.Printf("  AWS_REGION: %s", getEnvWithDefault("AWS_REGION", "us-east-1"))
+	logger.Infof("🔧 Payment Service Configuration:")
+	logger.Infof("  PORT: %s", getEnvWithDefault("PORT", "8081"))
+	logger.Infof("  GIN_MODE: %s", getEnvWithDefault("GIN_MODE", "release"))
+	logger.Infof("  DEVELOPMENT: %s", getEnvWithDefault("DEVELOPMENT", "false"))
+	logger.Infof("  DYNAMO_TABLE: %s", getEnvWithDefault("DYNAMO_TABLE", "subscriptions"))
+	logger.Infof("  AWS_REGION: %s", getEnvWithDefault("AWS_REGION", "us-east-1"))
+	logger.Infof("  DYNAMODB_ENDPOINT: %s", getEnvWithDefault("DYNAMODB_ENDPOINT", "(AWS default)"))
+
+	if os.Getenv("REDIS_URL") != "" {
+		logger.Infof("  REDIS_URL: ✅ Set")
+	} else {
+		logger.Infof("  REDIS_URL: ❌ Not set (gateway tier-cache invalidation disabled)")
+	}
 
 	// Log presence of sensitive variables without exposing values
 	if os.Getenv("LSZ_API_KEY") != "" {
-		log.Println("  LSZ_API_KEY: ✅ Set")
+		logger.Infof("  LSZ_API_KEY: ✅ Set")
 	} else {
-		log.Println("  LSZ_API_KEY: ❌ Not set")
+		logger.Infof("  LSZ_API_KEY: ❌ Not set")
 	}
 
 	if os.Getenv("LSZ_WEBHOOK_SECRET") != "" {
-		log.Println("  LSZ_WEBHOOK_SECRET: ✅ Set")
+		logger.Infof("  LSZ_WEBHOOK_SECRET: ✅ Set")
 	} else {
-		log.Println("  LSZ_WEBHOOK_SECRET: ❌ Not set")
+		logger.Infof("  LSZ_WEBHOOK_SECRET: ❌ Not set")
 	}
 
 	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
-		log.Println("  AWS_ACCESS_KEY_ID: ✅ Set")
+		logger.Infof("  AWS_ACCESS_KEY_ID: ✅ Set")
+	} else {
+		logger.Infof("  AWS_ACCESS_KEY_ID: ❌ Not set")
+	}
+
+	if os.Getenv("INTERNAL_API_TOKEN") != "" {
+		logger.Infof("  INTERNAL_API_TOKEN: ✅ Set")
 	} else {
-		log.Println("  AWS_ACCESS_KEY_ID: ❌ Not set")
+		logger.Infof("  INTERNAL_API_TOKEN: ❌ Not set (internal endpoints unauthenticated)")
 	}
 
 	if os.Getenv("FIREBASE_SERVICE_ACCOUNT_PATH") != "" {
-		log.Printf("  FIREBASE_SERVICE_ACCOUNT_PATH: %s", os.Getenv("FIREBASE_SERVICE_ACCOUNT_PATH"))
+		logger.Infof("  FIREBASE_SERVICE_ACCOUNT_PATH: %s", os.Getenv("FIREBASE_SERVICE_ACCOUNT_PATH"))
 	} else {
-		log.Println("  FIREBASE_SERVICE_ACCOUNT_PATH: ❌ Not set")
+		logger.Infof("  FIREBASE_SERVICE_ACCOUNT_PATH: ❌ Not set")
 	}
 
 	if os.Getenv("FIREBASE_SERVICE_ACCOUNT_JSON") != "" {
-		log.Println("  FIREBASE_SERVICE_ACCOUNT_JSON: ✅ Set")
+		logger.Infof("  FIREBASE_SERVICE_ACCOUNT_JSON: ✅ Set")
 	} else {
-		log.Println("  FIREBASE_SERVICE_ACCOUNT_JSON: ❌ Not set")
+		logger.Infof("  FIREBASE_SERVICE_ACCOUNT_JSON: ❌ Not set")
 	}
 }
 
@@ -72,7 +87,7 @@ func setupCORS() gin.HandlerFunc {
 		origin := c.GetHeader("Origin")
 		method := c.Request.Method
 
-		log.Printf("🌐 CORS Request: %s %s from origin: %s", method, c.Request.URL.Path, origin)
+		logger.Infof("🌐 CORS Request: %s %s from origin: %s", method, c.Request.URL.Path, origin)
 
 		// List of allowed origins
 		allowedOrigins := []string{
@@ -93,10 +108,10 @@ func setupCORS() gin.HandlerFunc {
 		}
 
 		if isAllowed {
-			log.Printf("✅ Origin allowed: %s", origin)
+			logger.Infof("✅ Origin allowed: %s", origin)
 			c.Header("Access-Control-Allow-Origin", origin)
 		} else {
-			log.Printf("⚠️ Origin not in allowed list, using default: %s", origin)
+			logger.Warnf("⚠️ Origin not in allowed list, using default: %s", origin)
 			c.Header("Access-Control-Allow-Origin", allowedOrigins[0])
 		}
 
@@ -107,7 +122,7 @@ func setupCORS() gin.HandlerFunc {
 
 		// Handle preflight requests
 		if c.Request.Method == "OPTIONS" {
-			log.Printf("✈️ CORS Preflight request handled for %s", c.Request.URL.Path)
+			logger.Infof("✈️ CORS Preflight request handled for %s", c.Request.URL.Path)
 			c.AbortWithStatus(204)
 			return
 		}
@@ -115,84 +130,117 @@ func setupCORS() gin.HandlerFunc {
 		c.Next()
 
 		duration := time.Since(startTime)
-		log.Printf("🌐 CORS Response: %s %s completed in %v", method, c.Request.URL.Path, duration)
+		logger.Infof("🌐 CORS Response: %s %s completed in %v", method, c.Request.URL.Path, duration)
+	}
+}
+
+// internalAuthMiddleware guards the service-to-service endpoints with a
+// shared secret the gateway sends in the X-Internal-Token header. These
+// endpoints take an arbitrary user_id, so without this check anyone who can
+// reach the payment service could query any user's subscription. When
+// INTERNAL_API_TOKEN is not set, enforcement is off so existing deployments
+// keep working until both services are configured.
+func internalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("INTERNAL_API_TOKEN")
+		if token == "" {
+			logger.Warnf("⚠️ INTERNAL_API_TOKEN not set, internal endpoint %s is unauthenticated", c.Request.URL.Path)
+			c.Next()
+			return
+		}
+
+		provided := c.GetHeader("X-Internal-Token")
+		if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logger.Errorf("❌ Invalid internal token for %s from %s", c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid internal token"})
+			return
+		}
+
+		c.Next()
 	}
 }
 
 // setupRoutes sets up all the routes
 func setupRoutes(r *gin.Engine) {
-	log.Println("🛣️ Setting up API routes...")
+	logger.Infof("🛣️ Setting up API routes...")
 
 	// Add CORS middleware
 	r.Use(setupCORS())
 
 	// Health check endpoint (no auth required)
 	r.GET("/health", handlers.HealthCheckHandler)
-	log.Println("  ✅ GET /health - Health check endpoint")
+	logger.Infof("  ✅ GET /health - Health check endpoint")
 
 	// API routes
 	api := r.Group("/api")
 	{
 		// Subscription management endpoints (require auth)
 		api.POST("/checkout", handlers.CreateCheckoutHandler)
-		log.Println("  ✅ POST /api/checkout - Create checkout session")
+		logger.Infof("  ✅ POST /api/checkout - Create checkout session")
+
+		api.GET("/checkout/preview", handlers.CheckoutPreviewHandler)
+		logger.Infof("  ✅ GET /api/checkout/preview - Preview checkout pricing")
 
 		api.GET("/tier", handlers.GetUserTierHandler)
-		log.Println("  ✅ GET /api/tier - Get user subscription tier")
+		logger.Infof("  ✅ GET /api/tier - Get user subscription tier")
 
 		api.GET("/subscription", handlers.GetSubscriptionDetailsHandler)
-		log.Println("  ✅ GET /api/subscription - Get subscription details")
+		logger.Infof("  ✅ GET /api/subscription - Get subscription details")
+
+		api.GET("/subscription/history", handlers.GetSubscriptionHistoryHandler)
+		logger.Infof("  ✅ GET /api/subscription/history - Get subscription change history")
+
+		// Internal service-to-service endpoints (gateway only, keyed by
+		// arbitrary user_id, so they require the shared internal token)
+		internal := api.Group("", internalAuthMiddleware())
 
-		api.GET("/subscription/status/:user_id", handlers.GetSubscriptionStatusHandler)
-		log.Println("  ✅ GET /api/subscription/status/:user_id - Get subscription status for user")
+		internal.GET("/subscription/status/:user_id", handlers.GetSubscriptionStatusHandler)
+		logger.Infof("  ✅ GET /api/subscription/status/:user_id - Get subscription status for user (internal)")
 
-		api.GET("/subscription/management/:user_id", handlers.GetUserManagementURLHandler)
-		log.Println("  ✅ GET /api/subscription/management/:user_id - Get subscription management URL")
+		internal.GET("/subscription/management/:user_id", handlers.GetUserManagementURLHandler)
+		logger.Infof("  ✅ GET /api/subscription/management/:user_id - Get subscription management URL (internal)")
 
 		api.GET("/subscription/urls", handlers.GetSubscriptionURLsHandler)
-		log.Println("  ✅ GET /api/subscription/urls - Get subscription management URLs")
+		logger.Infof("  ✅ GET /api/subscription/urls - Get subscription management URLs")
 
 		api.POST("/cancel-subscription", handlers.CancelSubscriptionHandler)
-		log.Println("  ✅ POST /api/cancel-subscription - Cancel subscription")
+		logger.Infof("  ✅ POST /api/cancel-subscription - Cancel subscription")
 
 		// Webhook endpoint (no auth required, signature verified)
 		api.POST("/webhook", handlers.WebhookHandler)
-		log.Println("  ✅ POST /api/webhook - LemonSqueezy webhook handler")
+		logger.Infof("  ✅ POST /api/webhook - LemonSqueezy webhook handler")
 	}
 
-	log.Println("🛣️ All routes configured successfully")
+	logger.Infof("🛣️ All routes configured successfully")
 }
 
 func main() {
-	// Set up logging format
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-
 	// Set Gin mode based on environment
 	ginMode := getEnvWithDefault("GIN_MODE", "release")
 	gin.SetMode(ginMode)
 
-	log.Println("🚀 Payment service starting up...")
-	log.Printf("⏰ Start time: %s", time.Now().Format(time.RFC3339))
+	logger.Infof("🚀 Payment service starting up...")
+	logger.Infof("⏰ Start time: %s", time.Now().Format(time.RFC3339))
 
 	// Log environment configuration
 	logEnvironmentConfig()
 
 	// Initialize Firebase
-	log.Println("🔥 Initializing Firebase...")
+	logger.Infof("🔥 Initializing Firebase...")
 	if err := firebase.InitFirebase(); err != nil {
-		log.Fatalf("❌ Failed to initialize Firebase: %v", err)
+		logger.Fatalf("❌ Failed to initialize Firebase: %v", err)
 	}
-	log.Println("✅ Firebase initialized successfully")
+	logger.Infof("✅ Firebase initialized successfully")
 
 	// Initialize DynamoDB
-	log.Println("🗄️ Initializing DynamoDB...")
+	logger.Infof("🗄️ Initializing DynamoDB...")
 	if err := dynamo.Init(); err != nil {
-		log.Fatalf("❌ Failed to initialize DynamoDB: %v", err)
+		logger.Fatalf("❌ Failed to initialize DynamoDB: %v", err)
 	}
-	log.Println("✅ DynamoDB initialized successfully")
+	logger.Infof("✅ DynamoDB initialized successfully")
 
 	// Create Gin router
-	log.Println("🌐 Creating Gin router...")
+	logger.Infof("🌐 Creating Gin router...")
 	r := gin.Default()
 
 	// Add request logging middleware
@@ -216,9 +264,9 @@ func main() {
 
 	// Add recovery middleware
 	r.Use(gin.RecoveryWithWriter(os.Stdout, func(c *gin.Context, recovered interface{}) {
-		log.Printf("💥 PANIC RECOVERED: %v", recovered)
-		log.Printf("   Request: %s %s", c.Request.Method, c.Request.URL.String())
-		log.Printf("   Headers: %+v", c.Request.Header)
+		logger.Errorf("💥 PANIC RECOVERED: %v", recovered)
+		logger.Errorf("   Request: %s %s", c.Request.Method, c.Request.URL.String())
+		logger.Errorf("   Headers: %+v", logger.RedactHeaders(c.Request.Header))
 	}))
 
 	// Setup routes
@@ -228,25 +276,27 @@ func main() {
 	port := getEnvWithDefault("PORT", "8081")
 
 	// Print startup information
-	log.Printf("🌟 Payment service configuration complete!")
-	log.Printf("📡 Server will start on port %s", port)
-	log.Printf("🔧 Environment: %s", ginMode)
-	log.Printf("🗄️ DynamoDB table: %s", dynamo.TableName)
+	logger.Infof("🌟 Payment service configuration complete!")
+	logger.Infof("📡 Server will start on port %s", port)
+	logger.Infof("🔧 Environment: %s", ginMode)
+	logger.Infof("🗄️ DynamoDB table: %s", dynamo.TableName)
 
 	// Print available endpoints
-	log.Println("🛣️ Available endpoints:")
-	log.Println("  📋 GET  /health - Health check")
-	log.Println("  💳 POST /api/checkout - Create checkout session")
-	log.Println("  🎫 GET  /api/tier - Get user subscription tier")
-	log.Println("  📄 GET  /api/subscription - Get subscription details")
-	log.Println("  🔗 GET  /api/subscription/urls - Get subscription management URLs")
-	log.Println("  ❌ POST /api/cancel-subscription - Cancel subscription")
-	log.Println("  🪝 POST /api/webhook - LemonSqueezy webhook handler")
-
-	log.Printf("🚀 Starting HTTP server on :%s...", port)
+	logger.Infof("🛣️ Available endpoints:")
+	logger.Infof("  📋 GET  /health - Health check")
+	logger.Infof("  💳 POST /api/checkout - Create checkout session")
+	logger.Infof("  💳 GET  /api/checkout/preview - Preview checkout pricing")
+	logger.Infof("  🎫 GET  /api/tier - Get user subscription tier")
+	logger.Infof("  📄 GET  /api/subscription - Get subscription details")
+	logger.Infof("  📜 GET  /api/subscription/history - Get subscription change history")
+	logger.Infof("  🔗 GET  /api/subscription/urls - Get subscription management URLs")
+	logger.Infof("  ❌ POST /api/cancel-subscription - Cancel subscription")
+	logger.Infof("  🪝 POST /api/webhook - LemonSqueezy webhook handler")
+
+	logger.Infof("🚀 Starting HTTP server on :%s...", port)
 
 	// Start server
 	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("💥 Failed to start server: %v", err)
+		logger.Fatalf("💥 Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"payment/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	once   sync.Once
+	client *redis.Client
+)
+
+// GetClient returns the shared Redis client, initializing it from REDIS_URL
+// on first use. Returns nil when REDIS_URL is not set or the connection
+// fails; callers treat a nil client as "Redis unavailable" and skip the
+// operation, so the payment service keeps working without Redis.
+func GetClient() *redis.Client {
+	once.Do(func() {
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			logger.Infof("📝 REDIS_URL not set, shared Redis features disabled")
+			return
+		}
+
+		opt, err := redis.ParseURL(redisURL)
+		if err != nil {
+			logger.Errorf("❌ Invalid REDIS_URL: %v", err)
+			return
+		}
+
+		c := redis.NewClient(opt)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := c.Ping(ctx).Err(); err != nil {
+			logger.Errorf("❌ Failed to connect to shared Redis: %v", err)
+			c.Close()
+			return
+		}
+
+		client = c
+		logger.Infof("✅ Connected to shared Redis")
+	})
+	return client
+}